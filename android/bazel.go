@@ -52,6 +52,22 @@ type properties struct {
 	// In USE_BAZEL_ANALYSIS=1 mode, this represents the Bazel target replacing
 	// this Soong module.
 	Bazel_module bazelModuleProperties
+
+	// Tags added to the Bazel target generated for this module by bp2build, e.g. ["manual"] or
+	// ["no-remote"].
+	Bazel_tags []string
+
+	// Overrides the rule class of the Bazel target generated for this module by bp2build, keeping
+	// the attributes produced by the converter. Intended for experimenting with custom rule
+	// implementations.
+	Bazel_rule_override struct {
+		// The rule class to use instead of the one of the converter.
+		Rule_class *string
+
+		// The label of the .bzl file loading the rule class, e.g.
+		// "//build/bazel/rules:my_filegroup.bzl". Leave unset for native rules.
+		Bzl_load_location *string
+	}
 }
 
 // namespacedVariableProperties is a map from a string representing a Soong
@@ -725,6 +741,10 @@ func (b *BazelModuleBase) shouldConvertWithBp2build(ctx BazelConversionContext,
 			" in bp2buildModuleTypeAlwaysConvert")
 	}
 
+	if ctx.Config().bp2buildModuleDenylist[moduleName] {
+		return false
+	}
+
 	if bp2buildModuleDoNotConvert[moduleName] {
 		if moduleNameAllowed {
 			ctx.(BaseModuleContext).ModuleErrorf("a module cannot be in bp2buildModuleDoNotConvert" +
@@ -16,6 +16,8 @@ package android
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -23,8 +25,14 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/pathtools"
 
 	"android/soong/bazel/cquery"
 	"android/soong/shared"
@@ -101,6 +109,23 @@ type BazelContext interface {
 
 	// Returns build statements which should get registered to reflect Bazel's outputs.
 	BuildStatementsToRegister() []bazel.BuildStatement
+
+	// Returns the path of the JSON build event protocol file written by Bazel, or the empty
+	// string if Bazel was not asked to write one.
+	BuildEventFile() string
+
+	// Returns the output files resolved for all GetOutputFiles requests by the last InvokeBazel,
+	// keyed by the label and configuration of the request in the form "<label>|<arch>|<os>".
+	ResolvedOutputFiles() map[string][]string
+
+	// Returns the ids, in the form "<label>|<arch>|<os>", of the requests that the last
+	// InvokeBazel couldn't resolve because their Bazel targets failed, when partial results are
+	// allowed. The modules of these requests fall back to building with Soong if they can.
+	FailedCqueryIds() []string
+
+	// Starts the Bazel server in the background so that it is ready by the time
+	// InvokeBazel is called. Does nothing if Bazel is not used.
+	WarmUp()
 }
 
 type bazelRunner interface {
@@ -114,6 +139,49 @@ type bazelPaths struct {
 	workspaceDir string
 	soongOutDir  string
 	metricsDir   string
+
+	// The name of the repository containing the source tree, as referenced from the
+	// @soong_injection buildroot. Set via SOONG_BAZEL_SOURCE_REPOSITORY, defaults to
+	// defaultSourceRepository.
+	sourceRepository string
+
+	// Additional package roots in which Bazel looks for BUILD files after the workspace, for
+	// trees with generated packages outside of the workspace. Relative paths are relative to the
+	// workspace. Set via SOONG_BAZEL_EXTRA_PACKAGE_PATHS as a comma-separated list.
+	extraPackagePaths []string
+
+	// The maximum duration of a Bazel invocation, after which the Bazel process is killed. Zero
+	// for no timeout. Set via BAZEL_COMMAND_TIMEOUT_SECONDS.
+	commandTimeout time.Duration
+}
+
+// MissingCqueryResultError is returned by InvokeBazel when the cquery output has no result for
+// some of the queued requests, typically because the Bazel workspace is out of date.
+type MissingCqueryResultError struct {
+	// The ids of the requests without a result, in the form "<label>|<arch>|<os>", sorted.
+	MissingIds []string
+
+	// The file containing the cquery output.
+	CqueryOutputFile string
+
+	// The file containing the aquery output, only set for offline results as the aquery is not
+	// issued when cquery results are missing.
+	AqueryOutputFile string
+
+	// The standard error of the cquery invocation, empty for offline results.
+	CqueryStderr string
+
+	// True if the results were read from SOONG_BAZEL_OFFLINE_RESULTS.
+	Offline bool
+}
+
+func (e *MissingCqueryResultError) Error() string {
+	if e.Offline {
+		return fmt.Sprintf("offline results %s are missing results for bazel targets: %s",
+			e.CqueryOutputFile, strings.Join(e.MissingIds, ", "))
+	}
+	return fmt.Sprintf("cquery output %s is missing results for bazel targets: %s, cquery err: [%s]",
+		e.CqueryOutputFile, strings.Join(e.MissingIds, ", "), e.CqueryStderr)
 }
 
 // A context object which tracks queued requests that need to be made to Bazel,
@@ -128,6 +196,46 @@ type bazelContext struct {
 
 	// Build statements which should get registered to reflect Bazel's outputs.
 	buildStatements []bazel.BuildStatement
+
+	// If true, InvokeBazel only writes out the files it would use to invoke
+	// Bazel, without issuing any Bazel commands. Set via SOONG_BAZEL_DUMP_ONLY.
+	dumpOnly bool
+
+	// Mnemonics of the build statements that should not be registered with
+	// Soong. Set via SOONG_BAZEL_EXCLUDED_MNEMONICS as a comma-separated list.
+	excludedMnemonics map[string]bool
+
+	// If true, InvokeBazel writes the queued cquery requests as JSON to
+	// requests.json in the bazel intermediates directory before issuing any
+	// Bazel commands. Set via SOONG_BAZEL_DUMP_REQUESTS.
+	dumpRequests bool
+
+	// If non-empty, the aquery and build invocations write Bazel's build event
+	// protocol output to this file as JSON. Set via SOONG_BAZEL_BUILD_EVENT_FILE.
+	buildEventFile string
+
+	// Closed once the command issued by WarmUp has finished, or nil if WarmUp
+	// was not called.
+	warmUpDone chan struct{}
+
+	// If non-empty, InvokeBazel reads previously captured cquery output and,
+	// optionally, aquery jsonproto output from these files instead of issuing
+	// Bazel commands. Set via SOONG_BAZEL_OFFLINE_RESULTS as a comma-separated
+	// list of the cquery.out file followed by the aquery output file.
+	offlineResults []string
+
+	// If true, and the Bazel release supports it, the aquery dumps the action graph that Bazel
+	// already holds from the cquery analysis instead of analyzing the buildroot again. Set via
+	// SOONG_BAZEL_INCREMENTAL_AQUERY.
+	incrementalAquery bool
+
+	// If true, Bazel is run with --keep_going and requests whose targets fail are recorded in
+	// failedIds instead of failing InvokeBazel. Set via SOONG_BAZEL_ALLOW_PARTIAL_RESULTS.
+	allowPartialResults bool
+
+	// The ids of the requests without a result after the last InvokeBazel, if
+	// allowPartialResults is set.
+	failedIds []string
 }
 
 var _ BazelContext = &bazelContext{}
@@ -144,6 +252,8 @@ type MockBazelContext struct {
 	LabelToOutputFiles  map[string][]string
 	LabelToCcInfo       map[string]cquery.CcInfo
 	LabelToPythonBinary map[string]string
+
+	FailedIds []string
 }
 
 func (m MockBazelContext) GetOutputFiles(label string, cfgKey configKey) ([]string, bool) {
@@ -175,6 +285,20 @@ func (m MockBazelContext) BuildStatementsToRegister() []bazel.BuildStatement {
 	return []bazel.BuildStatement{}
 }
 
+func (m MockBazelContext) BuildEventFile() string {
+	return ""
+}
+
+func (m MockBazelContext) FailedCqueryIds() []string {
+	return m.FailedIds
+}
+
+func (m MockBazelContext) ResolvedOutputFiles() map[string][]string {
+	return m.LabelToOutputFiles
+}
+
+func (m MockBazelContext) WarmUp() {}
+
 var _ BazelContext = MockBazelContext{}
 
 func (bazelCtx *bazelContext) GetOutputFiles(label string, cfgKey configKey) ([]string, bool) {
@@ -187,6 +311,16 @@ func (bazelCtx *bazelContext) GetOutputFiles(label string, cfgKey configKey) ([]
 	return ret, ok
 }
 
+func (bazelCtx *bazelContext) ResolvedOutputFiles() map[string][]string {
+	ret := make(map[string][]string)
+	for key, rawString := range bazelCtx.results {
+		if key.requestType == cquery.GetOutputFiles {
+			ret[key.label+"|"+getConfigString(key)] = cquery.GetOutputFiles.ParseResult(strings.TrimSpace(rawString))
+		}
+	}
+	return ret
+}
+
 func (bazelCtx *bazelContext) GetCcInfo(label string, cfgKey configKey) (cquery.CcInfo, bool, error) {
 	result, ok := bazelCtx.cquery(label, cquery.GetCcInfo, cfgKey)
 	if !ok {
@@ -236,6 +370,20 @@ func (m noopBazelContext) BuildStatementsToRegister() []bazel.BuildStatement {
 	return []bazel.BuildStatement{}
 }
 
+func (m noopBazelContext) BuildEventFile() string {
+	return ""
+}
+
+func (m noopBazelContext) FailedCqueryIds() []string {
+	return nil
+}
+
+func (m noopBazelContext) ResolvedOutputFiles() map[string][]string {
+	return nil
+}
+
+func (m noopBazelContext) WarmUp() {}
+
 func NewBazelContext(c *config) (BazelContext, error) {
 	// TODO(cparsons): Assess USE_BAZEL=1 instead once "mixed Soong/Bazel builds"
 	// are production ready.
@@ -247,13 +395,53 @@ func NewBazelContext(c *config) (BazelContext, error) {
 	if err != nil {
 		return nil, err
 	}
+	dumpOnly := c.IsEnvTrue("SOONG_BAZEL_DUMP_ONLY")
+	var offlineResults []string
+	for _, file := range strings.Split(c.Getenv("SOONG_BAZEL_OFFLINE_RESULTS"), ",") {
+		if file = strings.TrimSpace(file); file != "" {
+			offlineResults = append(offlineResults, file)
+		}
+	}
+	if len(offlineResults) > 2 {
+		return nil, fmt.Errorf("SOONG_BAZEL_OFFLINE_RESULTS expects at most a cquery and an aquery output file, got %q",
+			offlineResults)
+	}
+	// Bazel is never run when only dumping the files or when reading offline
+	// results, so it doesn't need to be present.
+	if !dumpOnly && len(offlineResults) == 0 {
+		if err := checkBazelBinary(p.bazelPath); err != nil {
+			return nil, err
+		}
+	}
+	excludedMnemonics := make(map[string]bool)
+	for _, mnemonic := range strings.Split(c.Getenv("SOONG_BAZEL_EXCLUDED_MNEMONICS"), ",") {
+		if mnemonic = strings.TrimSpace(mnemonic); mnemonic != "" {
+			excludedMnemonics[mnemonic] = true
+		}
+	}
+	var buildEventFile string
+	if c.IsEnvTrue("SOONG_BAZEL_BUILD_EVENT_FILE") {
+		buildEventFile = filepath.Join(p.metricsDir, "bep.json")
+	}
 	return &bazelContext{
-		bazelRunner: &builtinBazelRunner{},
-		paths:       p,
-		requests:    make(map[cqueryKey]bool),
+		bazelRunner:       &builtinBazelRunner{},
+		paths:             p,
+		requests:          make(map[cqueryKey]bool),
+		dumpOnly:          dumpOnly,
+		dumpRequests:      c.IsEnvTrue("SOONG_BAZEL_DUMP_REQUESTS"),
+		excludedMnemonics: excludedMnemonics,
+		buildEventFile:    buildEventFile,
+		offlineResults:    offlineResults,
+		incrementalAquery: c.IsEnvTrue("SOONG_BAZEL_INCREMENTAL_AQUERY"),
+
+		allowPartialResults: c.IsEnvTrue("SOONG_BAZEL_ALLOW_PARTIAL_RESULTS"),
 	}, nil
 }
 
+// The name of the repository containing the source tree in the Bazel workspace of the platform
+// build.
+const defaultSourceRepository = "sourceroot"
+
 func bazelPathsFromConfig(c *config) (*bazelPaths, error) {
 	p := bazelPaths{
 		soongOutDir: c.soongOutDir,
@@ -284,6 +472,22 @@ func bazelPathsFromConfig(c *config) (*bazelPaths, error) {
 	} else {
 		missingEnvVars = append(missingEnvVars, "BAZEL_METRICS_DIR")
 	}
+	p.sourceRepository = defaultSourceRepository
+	if repository := c.Getenv("SOONG_BAZEL_SOURCE_REPOSITORY"); repository != "" {
+		p.sourceRepository = repository
+	}
+	for _, path := range strings.Split(c.Getenv("SOONG_BAZEL_EXTRA_PACKAGE_PATHS"), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			p.extraPackagePaths = append(p.extraPackagePaths, path)
+		}
+	}
+	if timeout := c.Getenv("BAZEL_COMMAND_TIMEOUT_SECONDS"); timeout != "" {
+		seconds, err := strconv.Atoi(timeout)
+		if err != nil || seconds < 0 {
+			return nil, fmt.Errorf("invalid BAZEL_COMMAND_TIMEOUT_SECONDS %q, must be a non-negative integer", timeout)
+		}
+		p.commandTimeout = time.Duration(seconds) * time.Second
+	}
 	if len(missingEnvVars) > 0 {
 		return nil, errors.New(fmt.Sprintf("missing required env vars to use bazel: %s", missingEnvVars))
 	} else {
@@ -291,10 +495,87 @@ func bazelPathsFromConfig(c *config) (*bazelPaths, error) {
 	}
 }
 
+// checkBazelBinary returns an error if the given path, set through BAZEL_PATH, isn't an executable
+// file, so that a misconfigured build fails before analysis rather than with an exec failure once
+// Bazel is first invoked.
+func checkBazelBinary(bazelPath string) error {
+	info, err := os.Stat(bazelPath)
+	if err != nil {
+		return fmt.Errorf("BAZEL_PATH points to nonexistent binary: %s", bazelPath)
+	}
+	if info.IsDir() || info.Mode()&0111 == 0 {
+		return fmt.Errorf("BAZEL_PATH points to a file that isn't executable: %s", bazelPath)
+	}
+	return nil
+}
+
 func (p *bazelPaths) BazelMetricsDir() string {
 	return p.metricsDir
 }
 
+// canonicalizeLabel qualifies a label in the source tree with its repository, so that it refers to
+// the same target when referenced from the @soong_injection buildroot.
+func (p *bazelPaths) canonicalizeLabel(label string) string {
+	return "@" + p.sourceRepository + label
+}
+
+// Returns the label as Bazel formats it in cquery output. Labels in the main repository are not
+// qualified with a repository.
+func (p *bazelPaths) cqueryLabel(label string) string {
+	if p.sourceRepository == "" {
+		return label
+	}
+	return p.canonicalizeLabel(label)
+}
+
+// Returns the flags that set the default platforms and toolchains for mixed builds requests, or an
+// error if the host isn't supported.
+func (p *bazelPaths) platformFlags() ([]string, error) {
+	hostPlatform, err := hostPlatformName(hostGOOS, hostGOARCH)
+	if err != nil {
+		return nil, err
+	}
+	return []string{
+		"--platforms=" + p.canonicalizeLabel("//build/bazel/platforms:android_target"),
+		"--extra_toolchains=" + p.canonicalizeLabel("//prebuilts/clang/host/linux-x86:all"),
+		"--host_platform=" + p.canonicalizeLabel("//build/bazel/platforms:"+hostPlatform),
+	}, nil
+}
+
+// The OS and architecture of the host, which tests may override.
+var hostGOOS, hostGOARCH = runtime.GOOS, runtime.GOARCH
+
+// hostPlatformName returns the name of the platform in //build/bazel/platforms that describes a
+// host with the given GOOS and GOARCH, or an error if there is no such platform.
+func hostPlatformName(goos, goarch string) (string, error) {
+	switch goos + "/" + goarch {
+	case "linux/amd64":
+		return "linux_x86_64", nil
+	case "darwin/amd64":
+		return "darwin_x86_64", nil
+	case "darwin/arm64":
+		return "darwin_arm64", nil
+	default:
+		return "", fmt.Errorf("mixed builds are not supported on %s/%s hosts", goos, goarch)
+	}
+}
+
+// packagePathFlags returns the --package_path flag listing the extra package roots after the
+// workspace, which remains the first one, or nothing if there are no extra package roots.
+func (p *bazelPaths) packagePathFlags() []string {
+	if len(p.extraPackagePaths) == 0 {
+		return nil
+	}
+	packagePath := []string{"%workspace%"}
+	for _, path := range p.extraPackagePaths {
+		if !filepath.IsAbs(path) {
+			path = "%workspace%/" + path
+		}
+		packagePath = append(packagePath, path)
+	}
+	return []string{"--package_path=" + strings.Join(packagePath, ":")}
+}
+
 func (context *bazelContext) BazelEnabled() bool {
 	return true
 }
@@ -333,7 +614,9 @@ type bazelCommand struct {
 
 type mockBazelRunner struct {
 	bazelCommandResults map[bazelCommand]string
+	bazelCommandErrors  map[bazelCommand]error
 	commands            []bazelCommand
+	extraFlags          map[bazelCommand][]string
 }
 
 func (r *mockBazelRunner) issueBazelCommand(paths *bazelPaths,
@@ -341,10 +624,11 @@ func (r *mockBazelRunner) issueBazelCommand(paths *bazelPaths,
 	command bazelCommand,
 	extraFlags ...string) (string, string, error) {
 	r.commands = append(r.commands, command)
-	if ret, ok := r.bazelCommandResults[command]; ok {
-		return ret, "", nil
+	if r.extraFlags == nil {
+		r.extraFlags = make(map[bazelCommand][]string)
 	}
-	return "", "", nil
+	r.extraFlags[command] = extraFlags
+	return r.bazelCommandResults[command], "", r.bazelCommandErrors[command]
 }
 
 type builtinBazelRunner struct{}
@@ -365,30 +649,37 @@ func (r *builtinBazelRunner) issueBazelCommand(paths *bazelPaths, runName bazel.
 		"--output_base=" + absolutePath(paths.outputBase),
 		command.command,
 	}
-	cmdFlags = append(cmdFlags, command.expression)
+	if command.expression != "" {
+		cmdFlags = append(cmdFlags, command.expression)
+	}
 	cmdFlags = append(cmdFlags, "--profile="+shared.BazelMetricsFilename(paths, runName))
 
 	// Set default platforms to canonicalized values for mixed builds requests.
 	// If these are set in the bazelrc, they will have values that are
-	// non-canonicalized to @sourceroot labels, and thus be invalid when
+	// not qualified with the source repository, and thus be invalid when
 	// referenced from the buildroot.
 	//
 	// The actual platform values here may be overridden by configuration
 	// transitions from the buildroot.
-	cmdFlags = append(cmdFlags,
-		fmt.Sprintf("--platforms=%s", "//build/bazel/platforms:android_target"))
-	cmdFlags = append(cmdFlags,
-		fmt.Sprintf("--extra_toolchains=%s", "//prebuilts/clang/host/linux-x86:all"))
-	// This should be parameterized on the host OS, but let's restrict to linux
-	// to keep things simple for now.
-	cmdFlags = append(cmdFlags,
-		fmt.Sprintf("--host_platform=%s", "//build/bazel/platforms:linux_x86_64"))
+	platformFlags, err := paths.platformFlags()
+	if err != nil {
+		return "", "", err
+	}
+	cmdFlags = append(cmdFlags, platformFlags...)
+	cmdFlags = append(cmdFlags, paths.packagePathFlags()...)
 
 	// Explicitly disable downloading rules (such as canonical C++ and Java rules) from the network.
 	cmdFlags = append(cmdFlags, "--experimental_repository_disable_download")
 	cmdFlags = append(cmdFlags, extraFlags...)
 
-	bazelCmd := exec.Command(paths.bazelPath, cmdFlags...)
+	cmdContext := context.Background()
+	if paths.commandTimeout > 0 {
+		var cancel context.CancelFunc
+		cmdContext, cancel = context.WithTimeout(cmdContext, paths.commandTimeout)
+		defer cancel()
+	}
+
+	bazelCmd := exec.CommandContext(cmdContext, paths.bazelPath, cmdFlags...)
 	bazelCmd.Dir = absolutePath(paths.syntheticWorkspaceDir())
 	bazelCmd.Env = append(os.Environ(),
 		"HOME="+paths.homeDir,
@@ -403,8 +694,14 @@ func (r *builtinBazelRunner) issueBazelCommand(paths *bazelPaths, runName bazel.
 	stderr := &bytes.Buffer{}
 	bazelCmd.Stderr = stderr
 
-	if output, err := bazelCmd.Output(); err != nil {
+	if output, err := bazelCmd.Output(); cmdContext.Err() == context.DeadlineExceeded {
 		return "", string(stderr.Bytes()),
+			fmt.Errorf("bazel command timed out after %s, set by BAZEL_COMMAND_TIMEOUT_SECONDS. command: [%s]",
+				paths.commandTimeout, bazelCmd)
+	} else if err != nil {
+		// The output is returned as well, as commands run with --keep_going fail but still output
+		// the results of the targets that succeeded.
+		return string(output), string(stderr.Bytes()),
 			fmt.Errorf("bazel command failed. command: [%s], env: [%s], error [%s]", bazelCmd, bazelCmd.Env, stderr)
 	} else {
 		return string(output), string(stderr.Bytes()), nil
@@ -420,8 +717,10 @@ func (context *bazelContext) mainBzlFileContents() []byte {
 #####################################################
 
 def _config_node_transition_impl(settings, attr):
+    # Architecture-independent targets are configured for the x86_64 platform of their OS.
+    arch = "x86_64" if attr.arch == "common" else attr.arch
     return {
-        "//command_line_option:platforms": "@//build/bazel/platforms:%s_%s" % (attr.os, attr.arch),
+        "//command_line_option:platforms": "@//build/bazel/platforms:%s_%s" % (attr.os, arch),
     }
 
 _config_node_transition = transition(
@@ -500,13 +799,16 @@ config_node(name = "%s",
 
 	labelsByConfig := map[string][]string{}
 	for val, _ := range context.requests {
-		labelString := fmt.Sprintf("\"@%s\"", val.label)
+		labelString := fmt.Sprintf("%q", context.paths.canonicalizeLabel(val.label))
 		configString := getConfigString(val)
 		labelsByConfig[configString] = append(labelsByConfig[configString], labelString)
 	}
 
+	// Iterate in sorted order so that the generated file is stable across runs; rewriting it with a
+	// different order would invalidate Bazel's analysis cache.
 	allLabels := []string{}
-	for configString, labels := range labelsByConfig {
+	for _, configString := range SortedStringKeys(labelsByConfig) {
+		labels := SortedUniqueStrings(labelsByConfig[configString])
 		configTokens := strings.Split(configString, "|")
 		if len(configTokens) != 2 {
 			panic(fmt.Errorf("Unexpected config string format: %s", configString))
@@ -538,7 +840,7 @@ func indent(original string) string {
 func (context *bazelContext) cqueryStarlarkFileContents() []byte {
 	requestTypeToCqueryIdEntries := map[cqueryRequest][]string{}
 	for val, _ := range context.requests {
-		cqueryId := getCqueryId(val)
+		cqueryId := context.getCqueryId(val)
 		mapEntryString := fmt.Sprintf("%q : True", cqueryId)
 		requestTypeToCqueryIdEntries[val.requestType] =
 			append(requestTypeToCqueryIdEntries[val.requestType], mapEntryString)
@@ -557,8 +859,9 @@ def %s(target):
 %s
 `
 	mainSwitchSectionFormatString := `
-  if id_string in %s:
-    return id_string + ">>" + %s(target)
+    if id_string in %s:
+      results.append(id_string + ">>" + %s(target))
+      continue
 `
 
 	for requestType := range requestTypeToCqueryIdEntries {
@@ -611,13 +914,22 @@ def get_arch(target):
     return "UNKNOWN"
 
 def format(target):
-  id_string = str(target.label) + "|" + get_arch(target)
-
-  # Main switch section
-  %s
+  arch = get_arch(target)
+  id_strings = [str(target.label) + "|" + arch]
+  if arch.startswith("x86_64|"):
+    # Architecture-independent requests are configured for x86_64, but are
+    # identified by the common arch.
+    id_strings.append(str(target.label) + "|common|" + arch[len("x86_64|"):])
+
+  results = []
+  for id_string in id_strings:
+    # Main switch section
+    %s
+  if results:
+    return "\n".join(results)
   # This target was not requested via cquery, and thus must be a dependency
   # of a requested target.
-  return id_string + ">>NONE"
+  return id_strings[0] + ">>NONE"
 `
 
 	return []byte(fmt.Sprintf(formatString, labelRegistrationMapSection, functionDefSection,
@@ -647,11 +959,109 @@ func (p *bazelPaths) outDir() string {
 	return filepath.Dir(p.soongOutDir)
 }
 
+// Writes the files used to invoke Bazel (main.bzl, BUILD.bazel, WORKSPACE.bazel
+// and buildroot.cquery) to the bazel intermediates directory, so that they can be
+// inspected when debugging mixed builds.
+func (context *bazelContext) dumpBazelFiles() error {
+	dumpDir := absolutePath(context.paths.intermediatesDir())
+	if err := os.MkdirAll(dumpDir, 0777); err != nil {
+		return err
+	}
+	files := map[string][]byte{
+		"main.bzl":         context.mainBzlFileContents(),
+		"BUILD.bazel":      context.mainBuildFileContents(),
+		"WORKSPACE.bazel":  []byte{},
+		"buildroot.cquery": context.cqueryStarlarkFileContents(),
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dumpDir, name), contents, 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A cquery request as written by dumpQueuedRequests.
+type queuedRequest struct {
+	Label       string `json:"label"`
+	RequestType string `json:"request_type"`
+	Arch        string `json:"arch"`
+	Os          string `json:"os"`
+}
+
+// Returns the queued cquery requests as JSON, sorted by label, request type
+// and configuration.
+func (context *bazelContext) queuedRequestsJsonContents() ([]byte, error) {
+	requests := make([]queuedRequest, 0, len(context.requests))
+	for val := range context.requests {
+		requests = append(requests, queuedRequest{
+			Label:       val.label,
+			RequestType: val.requestType.Name(),
+			Arch:        val.configKey.arch,
+			Os:          val.configKey.osType.Name,
+		})
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		a, b := requests[i], requests[j]
+		if a.Label != b.Label {
+			return a.Label < b.Label
+		}
+		if a.RequestType != b.RequestType {
+			return a.RequestType < b.RequestType
+		}
+		if a.Arch != b.Arch {
+			return a.Arch < b.Arch
+		}
+		return a.Os < b.Os
+	})
+	return json.MarshalIndent(requests, "", "  ")
+}
+
+// Writes the queued cquery requests to requests.json in the bazel
+// intermediates directory, so that missing results can be correlated with
+// what was actually requested.
+func (context *bazelContext) dumpQueuedRequests() error {
+	contents, err := context.queuedRequestsJsonContents()
+	if err != nil {
+		return err
+	}
+	dumpDir := absolutePath(context.paths.intermediatesDir())
+	if err := os.MkdirAll(dumpDir, 0777); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dumpDir, "requests.json"), contents, 0666)
+}
+
 // Issues commands to Bazel to receive results for all cquery requests
 // queued in the BazelContext.
 func (context *bazelContext) InvokeBazel() error {
+	// Wait for the warmup command before anything else, so that it neither races the real
+	// commands for the Bazel server lock nor outlives InvokeBazel when it returns early.
+	if context.warmUpDone != nil {
+		<-context.warmUpDone
+	}
+
 	context.results = make(map[cqueryKey]string)
 
+	if context.dumpRequests {
+		if err := context.dumpQueuedRequests(); err != nil {
+			return err
+		}
+	}
+
+	if context.dumpOnly {
+		return context.dumpBazelFiles()
+	}
+
+	numRequests := len(context.requests)
+
+	if len(context.offlineResults) > 0 {
+		if err := context.readOfflineResults(); err != nil {
+			return err
+		}
+		return context.writeCqueryMetrics(true, numRequests)
+	}
+
 	var cqueryOutput string
 	var cqueryErr string
 	var err error
@@ -670,26 +1080,28 @@ func (context *bazelContext) InvokeBazel() error {
 			return err
 		}
 	}
-	err = ioutil.WriteFile(filepath.Join(soongInjectionPath, "WORKSPACE.bazel"), []byte{}, 0666)
+	// The generated files are only rewritten when their contents change, as newer modification
+	// times would invalidate Bazel's analysis cache.
+	err = pathtools.WriteFileIfChanged(filepath.Join(soongInjectionPath, "WORKSPACE.bazel"), []byte{}, 0666)
 	if err != nil {
 		return err
 	}
 
-	err = ioutil.WriteFile(
+	err = pathtools.WriteFileIfChanged(
 		filepath.Join(mixedBuildsPath, "main.bzl"),
 		context.mainBzlFileContents(), 0666)
 	if err != nil {
 		return err
 	}
 
-	err = ioutil.WriteFile(
+	err = pathtools.WriteFileIfChanged(
 		filepath.Join(mixedBuildsPath, "BUILD.bazel"),
 		context.mainBuildFileContents(), 0666)
 	if err != nil {
 		return err
 	}
 	cqueryFileRelpath := filepath.Join(context.paths.injectedFilesDir(), "buildroot.cquery")
-	err = ioutil.WriteFile(
+	err = pathtools.WriteFileIfChanged(
 		absolutePath(cqueryFileRelpath),
 		context.cqueryStarlarkFileContents(), 0666)
 	if err != nil {
@@ -701,48 +1113,73 @@ func (context *bazelContext) InvokeBazel() error {
 		context.paths,
 		bazel.CqueryBuildRootRunName,
 		bazelCommand{"cquery", fmt.Sprintf("deps(%s, 2)", buildrootLabel)},
-		"--output=starlark",
-		"--starlark:file="+absolutePath(cqueryFileRelpath))
-	err = ioutil.WriteFile(filepath.Join(soongInjectionPath, "cquery.out"),
-		[]byte(cqueryOutput), 0666)
-	if err != nil {
-		return err
+		append([]string{
+			"--output=starlark",
+			"--starlark:file=" + absolutePath(cqueryFileRelpath),
+		}, context.keepGoingFlags()...)...)
+	if writeErr := ioutil.WriteFile(filepath.Join(soongInjectionPath, "cquery.out"),
+		[]byte(cqueryOutput), 0666); writeErr != nil {
+		return writeErr
 	}
-
-	if err != nil {
+	// With partial results allowed, the requests of the failed targets are reported as missing
+	// below instead.
+	if err != nil && !context.allowPartialResults {
 		return err
 	}
+	cqueryCmdErr := err
 
-	cqueryResults := map[string]string{}
-	for _, outputLine := range strings.Split(cqueryOutput, "\n") {
-		if strings.Contains(outputLine, ">>") {
-			splitLine := strings.SplitN(outputLine, ">>", 2)
-			cqueryResults[splitLine[0]] = splitLine[1]
-		}
-	}
+	cqueryResults := parseCqueryOutput(cqueryOutput)
 
+	context.failedIds = nil
+	var missing []string
 	for val := range context.requests {
-		if cqueryResult, ok := cqueryResults[getCqueryId(val)]; ok {
+		if cqueryResult, ok := cqueryResults[context.getCqueryId(val)]; ok {
 			context.results[val] = cqueryResult
 		} else {
-			return fmt.Errorf("missing result for bazel target %s. query output: [%s], cquery err: [%s]",
-				getCqueryId(val), cqueryOutput, cqueryErr)
+			missing = append(missing, context.getCqueryId(val))
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		// The failed requests are only tolerated if Bazel produced the results of some of the
+		// others. If it produced none, Bazel itself failed rather than some of the targets.
+		if context.allowPartialResults && len(missing) < len(context.requests) {
+			context.failedIds = missing
+		} else if cqueryCmdErr != nil {
+			return cqueryCmdErr
+		} else {
+			return &MissingCqueryResultError{
+				MissingIds:       missing,
+				CqueryOutputFile: filepath.Join(soongInjectionPath, "cquery.out"),
+				CqueryStderr:     cqueryErr,
+			}
 		}
 	}
 
 	// Issue an aquery command to retrieve action information about the bazel build tree.
 	//
 	// TODO(cparsons): Use --target_pattern_file to avoid command line limits.
+	aqueryCommand := bazelCommand{"aquery", fmt.Sprintf("deps(%s)", buildrootLabel)}
+	aqueryFlags := []string{
+		// Use jsonproto instead of proto; actual proto parsing would require a dependency on Bazel's
+		// proto sources, which would add a number of unnecessary dependencies.
+		"--output=jsonproto",
+	}
+	if context.incrementalAquery && context.supportsSkyframeStateAquery() {
+		// The cquery has already analyzed the buildroot, so its actions are in Skyframe and don't
+		// need to be analyzed again. --skyframe_state doesn't accept a query expression.
+		aqueryCommand.expression = ""
+		aqueryFlags = append(aqueryFlags, "--skyframe_state")
+	}
+	aqueryFlags = append(aqueryFlags, context.keepGoingFlags()...)
 	var aqueryOutput string
 	aqueryOutput, _, err = context.issueBazelCommand(
 		context.paths,
 		bazel.AqueryBuildRootRunName,
-		bazelCommand{"aquery", fmt.Sprintf("deps(%s)", buildrootLabel)},
-		// Use jsonproto instead of proto; actual proto parsing would require a dependency on Bazel's
-		// proto sources, which would add a number of unnecessary dependencies.
-		"--output=jsonproto")
+		aqueryCommand,
+		append(aqueryFlags, context.buildEventFlags()...)...)
 
-	if err != nil {
+	if err != nil && !(context.toleratesTargetFailures() && aqueryOutput != "") {
 		return err
 	}
 
@@ -757,11 +1194,103 @@ func (context *bazelContext) InvokeBazel() error {
 	_, _, err = context.issueBazelCommand(
 		context.paths,
 		bazel.BazelBuildPhonyRootRunName,
-		bazelCommand{"build", "@soong_injection//mixed_builds:phonyroot"})
+		bazelCommand{"build", "@soong_injection//mixed_builds:phonyroot"},
+		append(context.keepGoingFlags(), context.buildEventFlags()...)...)
+
+	if err != nil && !context.toleratesTargetFailures() {
+		return err
+	}
+
+	if err := context.writeCqueryMetrics(false, numRequests); err != nil {
+		return err
+	}
+
+	// Clear requests.
+	context.requests = map[cqueryKey]bool{}
+	return nil
+}
+
+// The name of the file in the Bazel metrics directory that records whether the results of the last
+// InvokeBazel were read from offline results.
+const cqueryMetricsFilename = "cquery_metrics.json"
+
+// cqueryMetrics records whether the cquery results of an InvokeBazel run were read from the
+// offline results in SOONG_BAZEL_OFFLINE_RESULTS or required a fresh Bazel invocation.
+type cqueryMetrics struct {
+	OfflineResults bool `json:"offline_results"`
+	Requests       int  `json:"requests"`
+}
+
+// writeCqueryMetrics writes the cquery metrics of this run to the Bazel metrics directory, if
+// there is one.
+func (context *bazelContext) writeCqueryMetrics(offlineResults bool, numRequests int) error {
+	metricsDir := context.paths.BazelMetricsDir()
+	if metricsDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(metricsDir, 0777); err != nil {
+		return err
+	}
+	contents, err := json.Marshal(cqueryMetrics{OfflineResults: offlineResults, Requests: numRequests})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(metricsDir, cqueryMetricsFilename), contents, 0666)
+}
+
+// Parses the output of the buildroot cquery into a map from cquery id to result.
+func parseCqueryOutput(cqueryOutput string) map[string]string {
+	cqueryResults := map[string]string{}
+	for _, outputLine := range strings.Split(cqueryOutput, "\n") {
+		if strings.Contains(outputLine, ">>") {
+			splitLine := strings.SplitN(outputLine, ">>", 2)
+			cqueryResults[splitLine[0]] = splitLine[1]
+		}
+	}
+	return cqueryResults
+}
 
+// Populates the results and build statements from the offline results files
+// instead of invoking Bazel. Every queued request must have a result.
+func (context *bazelContext) readOfflineResults() error {
+	cqueryOutput, err := ioutil.ReadFile(absolutePath(context.offlineResults[0]))
 	if err != nil {
 		return err
 	}
+	cqueryResults := parseCqueryOutput(string(cqueryOutput))
+
+	var missing []string
+	for val := range context.requests {
+		if cqueryResult, ok := cqueryResults[context.getCqueryId(val)]; ok {
+			context.results[val] = cqueryResult
+		} else {
+			missing = append(missing, context.getCqueryId(val))
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		missingErr := &MissingCqueryResultError{
+			MissingIds:       missing,
+			CqueryOutputFile: context.offlineResults[0],
+			Offline:          true,
+		}
+		if len(context.offlineResults) > 1 {
+			missingErr.AqueryOutputFile = context.offlineResults[1]
+		}
+		return missingErr
+	}
+
+	context.buildStatements = nil
+	if len(context.offlineResults) > 1 {
+		aqueryOutput, err := ioutil.ReadFile(absolutePath(context.offlineResults[1]))
+		if err != nil {
+			return err
+		}
+		context.buildStatements, err = bazel.AqueryBuildStatements(aqueryOutput)
+		if err != nil {
+			return err
+		}
+	}
 
 	// Clear requests.
 	context.requests = map[cqueryKey]bool{}
@@ -769,13 +1298,153 @@ func (context *bazelContext) InvokeBazel() error {
 }
 
 func (context *bazelContext) BuildStatementsToRegister() []bazel.BuildStatement {
-	return context.buildStatements
+	if len(context.excludedMnemonics) == 0 {
+		return context.buildStatements
+	}
+	var buildStatements []bazel.BuildStatement
+	for _, buildStatement := range context.buildStatements {
+		if !context.excludedMnemonics[buildStatement.Mnemonic] {
+			buildStatements = append(buildStatements, buildStatement)
+		}
+	}
+	return buildStatements
 }
 
 func (context *bazelContext) OutputBase() string {
 	return context.paths.outputBase
 }
 
+func (context *bazelContext) BuildEventFile() string {
+	return context.buildEventFile
+}
+
+// Issues `bazel info` asynchronously so that the Bazel server is already
+// running by the time InvokeBazel issues the real queries. Any failure is
+// ignored here; it will surface again from InvokeBazel.
+func (context *bazelContext) WarmUp() {
+	if context.dumpOnly || len(context.offlineResults) > 0 || context.warmUpDone != nil {
+		return
+	}
+	context.warmUpDone = make(chan struct{})
+	go func() {
+		defer close(context.warmUpDone)
+		context.issueBazelCommand(context.paths, bazel.WarmUpRunName, bazelCommand{"info", "release"})
+	}()
+}
+
+// The oldest Bazel release whose aquery supports --skyframe_state with --output=jsonproto.
+var minSkyframeStateAqueryVersion = [3]int{6, 0, 0}
+
+// supportsSkyframeStateAquery returns true if the Bazel release, as reported by `bazel info
+// release`, supports dumping the Skyframe action graph with aquery. Development builds of Bazel
+// don't report a version and are assumed not to support it.
+func (context *bazelContext) supportsSkyframeStateAquery() bool {
+	output, _, err := context.issueBazelCommand(context.paths, bazel.VersionRunName,
+		bazelCommand{"info", "release"})
+	if err != nil {
+		return false
+	}
+	version, ok := parseBazelRelease(output)
+	if !ok {
+		return false
+	}
+	for i := range version {
+		if version[i] != minSkyframeStateAqueryVersion[i] {
+			return version[i] > minSkyframeStateAqueryVersion[i]
+		}
+	}
+	return true
+}
+
+// parseBazelRelease parses the major, minor and patch versions out of the output of `bazel info
+// release`, e.g. "release 6.0.0" or "release 6.0.0-pre.20220720.3".
+func parseBazelRelease(output string) ([3]int, bool) {
+	var version [3]int
+	release := strings.TrimPrefix(strings.TrimSpace(output), "release ")
+	if i := strings.IndexAny(release, "- "); i >= 0 {
+		release = release[:i]
+	}
+	parts := strings.Split(release, ".")
+	if len(parts) != len(version) {
+		return version, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return version, false
+		}
+		version[i] = n
+	}
+	return version, true
+}
+
+// Returns the flags that make Bazel continue past failing targets, if partial results are
+// allowed.
+func (context *bazelContext) keepGoingFlags() []string {
+	if !context.allowPartialResults {
+		return nil
+	}
+	return []string{"--keep_going"}
+}
+
+// Returns whether errors of the Bazel commands that follow the cquery are tolerated, which is only
+// the case if partial results are allowed and the cquery found failed targets. Other errors, such
+// as a crash of the Bazel server, can't be told apart from target failures, but they also fail the
+// cquery.
+func (context *bazelContext) toleratesTargetFailures() bool {
+	return context.allowPartialResults && len(context.failedIds) > 0
+}
+
+func (context *bazelContext) FailedCqueryIds() []string {
+	return context.failedIds
+}
+
+// ModulesWithFailedBazelTargets returns the sorted names of the modules whose Bazel targets failed
+// in the last InvokeBazel of the given config when partial results are allowed. These modules
+// didn't get any results from Bazel and are built with Soong instead, if they can be.
+func ModulesWithFailedBazelTargets(ctx *Context, config Config) []string {
+	failedLabels := make(map[string]bool)
+	for _, id := range config.BazelContext.FailedCqueryIds() {
+		failedLabels[labelWithoutRepository(strings.SplitN(id, "|", 2)[0])] = true
+	}
+	if len(failedLabels) == 0 {
+		return nil
+	}
+	var modules []string
+	ctx.VisitAllModules(func(m blueprint.Module) {
+		b, ok := m.(Bazelable)
+		if !ok {
+			return
+		}
+		label := "//" + ctx.ModuleDir(m) + ":" + ctx.ModuleName(m)
+		if b.HasHandcraftedLabel() {
+			label = b.HandcraftedLabel()
+		}
+		if failedLabels[labelWithoutRepository(label)] {
+			modules = append(modules, ctx.ModuleName(m))
+		}
+	})
+	return SortedUniqueStrings(modules)
+}
+
+// Returns the given label without the repository it may be qualified with, e.g. "//foo:bar" for
+// "@repo//foo:bar".
+func labelWithoutRepository(label string) string {
+	if i := strings.Index(label, "//"); i > 0 {
+		return label[i:]
+	}
+	return label
+}
+
+// Returns the flags that make Bazel write its build event protocol output to
+// the build event file, if one was requested.
+func (context *bazelContext) buildEventFlags() []string {
+	if context.buildEventFile == "" {
+		return nil
+	}
+	return []string{"--build_event_json_file=" + absolutePath(context.buildEventFile)}
+}
+
 // Singleton used for registering BUILD file ninja dependencies (needed
 // for correctness of builds which use Bazel.
 func BazelSingleton() Singleton {
@@ -795,20 +1464,31 @@ func (c *bazelSingleton) GenerateBuildActions(ctx SingletonContext) {
 		filepath.Dir(ctx.Config().moduleListFile), "bazel.list"))
 	ctx.AddNinjaFileDeps(bazelBuildList)
 
-	data, err := ioutil.ReadFile(bazelBuildList)
+	files, ignored, err := readBazelBuildList(bazelBuildList)
 	if err != nil {
 		ctx.Errorf(err.Error())
 	}
-	files := strings.Split(strings.TrimSpace(string(data)), "\n")
-	for _, file := range files {
-		ctx.AddNinjaFileDeps(file)
+	ctx.AddNinjaFileDeps(files...)
+	WriteFileRule(ctx, PathForOutput(ctx, bazel.SoongInjectionDirName, "mixed_builds",
+		"ignored_bazel_list_files.txt"), strings.Join(ignored, "\n"))
+
+	buildStatements, skipped := skipBuildStatementsWithoutCommand(
+		ctx.Config().BazelContext.BuildStatementsToRegister())
+	// Record the skipped build statements rather than failing, as they are usually actions that
+	// no module depends on.
+	WriteFileRule(ctx, PathForOutput(ctx, bazel.SoongInjectionDirName, "mixed_builds",
+		"skipped_build_statements.txt"), strings.Join(skipped, "\n"))
+	// Fail early with the missing path, rather than with a ninja error about a missing dependency
+	// of an anonymous "bazel <index>" rule.
+	if errs := checkBazelBuildStatementInputs(ctx, buildStatements); len(errs) > 0 {
+		for _, err := range errs {
+			ctx.Errorf("%s", err)
+		}
+		return
 	}
 
 	// Register bazel-owned build statements (obtained from the aquery invocation).
-	for index, buildStatement := range ctx.Config().BazelContext.BuildStatementsToRegister() {
-		if len(buildStatement.Command) < 1 {
-			panic(fmt.Sprintf("unhandled build statement: %v", buildStatement))
-		}
+	for index, buildStatement := range buildStatements {
 		rule := NewRuleBuilder(pctx, ctx)
 		cmd := rule.Command()
 
@@ -857,15 +1537,17 @@ func (c *bazelSingleton) GenerateBuildActions(ctx SingletonContext) {
 	}
 }
 
-func getCqueryId(key cqueryKey) string {
-	return key.label + "|" + getConfigString(key)
+func (context *bazelContext) getCqueryId(key cqueryKey) string {
+	return context.paths.cqueryLabel(key.label) + "|" + getConfigString(key)
 }
 
 func getConfigString(key cqueryKey) string {
 	arch := key.configKey.arch
-	if len(arch) == 0 || arch == "common" {
-		// Use host platform, which is currently hardcoded to be x86_64.
-		arch = "x86_64"
+	if len(arch) == 0 {
+		// Requests without an arch are architecture-independent. They are
+		// configured for x86_64 by the config node, but are kept apart from the
+		// requests that are specific to x86_64.
+		arch = "common"
 	}
 	os := key.configKey.osType.Name
 	if len(os) == 0 || os == "common_os" || os == "linux_glibc" {
@@ -882,3 +1564,76 @@ func GetConfigKey(ctx ModuleContext) configKey {
 		osType: ctx.Os(),
 	}
 }
+
+// readBazelBuildList returns the files listed in the given bazel.list, which all bazel invocations
+// require. Listed files that no longer exist are left out and described in the returned messages,
+// as a dependency on a nonexistent file would make ninja regenerate the build on every run.
+func readBazelBuildList(bazelBuildList string) ([]string, []string, error) {
+	data, err := ioutil.ReadFile(bazelBuildList)
+	if err != nil {
+		return nil, nil, err
+	}
+	var files, warnings []string
+	for _, file := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if file = strings.TrimSpace(file); file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			warnings = append(warnings, fmt.Sprintf("ignoring nonexistent file %q listed in %s", file, bazelBuildList))
+			continue
+		}
+		files = append(files, file)
+	}
+	return files, warnings, nil
+}
+
+// skipBuildStatementsWithoutCommand returns the build statements that can be registered, skipping
+// those without a command, such as actions of types that Soong doesn't know how to represent, along
+// with a warning for each skipped build statement.
+func skipBuildStatementsWithoutCommand(buildStatements []bazel.BuildStatement) ([]bazel.BuildStatement, []string) {
+	var kept []bazel.BuildStatement
+	var warnings []string
+	for index, buildStatement := range buildStatements {
+		if strings.TrimSpace(buildStatement.Command) == "" {
+			warnings = append(warnings, fmt.Sprintf("skipping bazel build statement %d (%s) with outputs %q, "+
+				"which has no command", index, buildStatement.Mnemonic, buildStatement.OutputPaths))
+			continue
+		}
+		kept = append(kept, buildStatement)
+	}
+	return kept, warnings
+}
+
+// checkBazelBuildStatementInputs returns an error for each input of the given build statements
+// that is neither an output or symlink of one of the build statements, nor an existing file in the
+// source tree or in Bazel's execution root.
+func checkBazelBuildStatementInputs(ctx PathContext, buildStatements []bazel.BuildStatement) []error {
+	declared := make(map[string]bool)
+	for _, buildStatement := range buildStatements {
+		for _, outputPath := range buildStatement.OutputPaths {
+			declared[outputPath] = true
+		}
+		for _, symlinkPath := range buildStatement.SymlinkPaths {
+			declared[symlinkPath] = true
+		}
+	}
+
+	var errs []error
+	for index, buildStatement := range buildStatements {
+		for _, inputPath := range buildStatement.InputPaths {
+			if declared[inputPath] {
+				continue
+			}
+			if exists, _, err := ctx.Config().fs.Exists(inputPath); err == nil && exists {
+				continue
+			}
+			if _, err := os.Stat(absolutePath(PathForBazelOut(ctx, inputPath).String())); err == nil {
+				continue
+			}
+			errs = append(errs, fmt.Errorf("bazel build statement %d (%s) references input %q, "+
+				"which does not exist and is not an output of any build statement",
+				index, buildStatement.Mnemonic, inputPath))
+		}
+	}
+	return errs
+}
@@ -1,10 +1,19 @@
 package android
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"android/soong/bazel"
+	"android/soong/bazel/cquery"
 )
 
 func TestRequestResultsAfterInvokeBazel(t *testing.T) {
@@ -29,6 +38,36 @@ func TestRequestResultsAfterInvokeBazel(t *testing.T) {
 	}
 }
 
+func TestArchIndependentRequestsUseCommonArch(t *testing.T) {
+	label := "//foo:bar"
+	cfg := configKey{"", Android}
+	bazelContext, _ := testBazelContext(t, map[bazelCommand]string{
+		bazelCommand{command: "cquery", expression: "deps(@soong_injection//mixed_builds:buildroot, 2)"}: `//foo:bar|common|android>>out/foo/bar.txt`,
+	})
+	bazelContext.GetOutputFiles(label, cfg)
+
+	if got, want := bazelContext.getCqueryId(cqueryKey{label, cquery.GetOutputFiles, cfg}), "//foo:bar|common|android"; got != want {
+		t.Errorf("Expected cquery id %q, got %q", want, got)
+	}
+	buildFile := string(bazelContext.mainBuildFileContents())
+	if !strings.Contains(buildFile, `config_node(name = "android_common",
+    arch = "common",`) {
+		t.Errorf("Expected a common config node, got:\n%s", buildFile)
+	}
+	if strings.Contains(buildFile, "x86_64") {
+		t.Errorf("Expected no x86_64 config node for an arch-independent request, got:\n%s", buildFile)
+	}
+
+	if err := bazelContext.InvokeBazel(); err != nil {
+		t.Fatalf("Did not expect error invoking Bazel, but got %s", err)
+	}
+	if g, ok := bazelContext.GetOutputFiles(label, cfg); !ok {
+		t.Errorf("Expected cquery results after running InvokeBazel(), but got none")
+	} else if w := []string{"out/foo/bar.txt"}; !reflect.DeepEqual(w, g) {
+		t.Errorf("Expected output %s, got %s", w, g)
+	}
+}
+
 func TestInvokeBazelWritesBazelFiles(t *testing.T) {
 	bazelContext, baseDir := testBazelContext(t, map[bazelCommand]string{})
 	err := bazelContext.InvokeBazel()
@@ -54,6 +93,157 @@ func TestInvokeBazelWritesBazelFiles(t *testing.T) {
 	}
 }
 
+func TestInvokeBazelOnlyRewritesChangedFiles(t *testing.T) {
+	bazelContext, baseDir := testBazelContext(t, map[bazelCommand]string{})
+	if err := bazelContext.InvokeBazel(); err != nil {
+		t.Fatalf("Did not expect error invoking Bazel, but got %s", err)
+	}
+
+	mainBzl := filepath.Join(baseDir, "soong_injection", "mixed_builds", "main.bzl")
+	buildFile := filepath.Join(baseDir, "soong_injection", "mixed_builds", "BUILD.bazel")
+	oldTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, file := range []string{mainBzl, buildFile} {
+		if err := os.Chtimes(file, oldTime, oldTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Queueing a request changes BUILD.bazel, but not main.bzl.
+	bazelContext.GetOutputFiles("//foo:bar", configKey{"arm64_armv8-a", Android})
+	if err := bazelContext.InvokeBazel(); err != nil {
+		t.Fatalf("Did not expect error invoking Bazel, but got %s", err)
+	}
+
+	modTime := func(file string) time.Time {
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.ModTime()
+	}
+	if got := modTime(mainBzl); !got.Equal(oldTime) {
+		t.Errorf("Expected unchanged main.bzl to keep modification time %s, got %s", oldTime, got)
+	}
+	if got := modTime(buildFile); got.Equal(oldTime) {
+		t.Errorf("Expected changed BUILD.bazel to be rewritten, but it kept modification time %s", got)
+	}
+}
+
+func TestNewBazelContextChecksBazelBinary(t *testing.T) {
+	dir := t.TempDir()
+	notExecutable := filepath.Join(dir, "not_executable")
+	if err := ioutil.WriteFile(notExecutable, nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	executable := filepath.Join(dir, "bazel")
+	if err := ioutil.WriteFile(executable, nil, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		bazelPath   string
+		dumpOnly    bool
+		expectedErr string
+	}{
+		{
+			bazelPath:   filepath.Join(dir, "nonexistent"),
+			expectedErr: "BAZEL_PATH points to nonexistent binary: " + filepath.Join(dir, "nonexistent"),
+		},
+		{
+			bazelPath:   notExecutable,
+			expectedErr: "BAZEL_PATH points to a file that isn't executable: " + notExecutable,
+		},
+		{
+			bazelPath: executable,
+		},
+		{
+			// Bazel isn't run when only dumping the generated files.
+			bazelPath: filepath.Join(dir, "nonexistent"),
+			dumpOnly:  true,
+		},
+	}
+	for _, tc := range testCases {
+		env := map[string]string{
+			"USE_BAZEL_ANALYSIS": "1",
+			"BAZEL_HOME":         "home",
+			"BAZEL_PATH":         tc.bazelPath,
+			"BAZEL_OUTPUT_BASE":  "output_base",
+			"BAZEL_WORKSPACE":    "workspace",
+			"BAZEL_METRICS_DIR":  "metrics",
+		}
+		if tc.dumpOnly {
+			env["SOONG_BAZEL_DUMP_ONLY"] = "1"
+		}
+		config := TestConfig(t.TempDir(), env, "", nil)
+		_, err := NewBazelContext(config.config)
+		if tc.expectedErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %s", tc.bazelPath, err)
+			}
+		} else if err == nil {
+			t.Errorf("%s: expected error %q, got none", tc.bazelPath, tc.expectedErr)
+		} else {
+			AssertStringEquals(t, "error", tc.expectedErr, err.Error())
+		}
+	}
+}
+
+func TestInvokeBazelDumpOnly(t *testing.T) {
+	bazelContext, baseDir := testBazelContext(t, map[bazelCommand]string{})
+	bazelContext.dumpOnly = true
+	bazelContext.GetOutputFiles("//foo:bar", configKey{"arm64_armv8-a", Android})
+	err := bazelContext.InvokeBazel()
+	if err != nil {
+		t.Fatalf("Did not expect error invoking Bazel, but got %s", err)
+	}
+
+	if commands := bazelContext.bazelRunner.(*mockBazelRunner).commands; len(commands) > 0 {
+		t.Errorf("Expected no bazel commands to be issued, but got %v", commands)
+	}
+
+	for _, name := range []string{"main.bzl", "BUILD.bazel", "WORKSPACE.bazel", "buildroot.cquery"} {
+		if _, err := os.Stat(filepath.Join(baseDir, "bazel", name)); os.IsNotExist(err) {
+			t.Errorf("Expected %s to exist, but it does not", name)
+		} else if err != nil {
+			t.Errorf("Unexpected error stating %s %s", name, err)
+		}
+	}
+}
+
+func TestMainBuildFileContentsIsDeterministic(t *testing.T) {
+	labels := []string{"//foo:a", "//foo:b", "//bar:c", "//baz:d", "//qux:e"}
+	configs := []configKey{
+		{"arm64_armv8-a", Android},
+		{"x86_64", Android},
+		{"common", Android},
+		{"x86_64", Linux},
+	}
+
+	render := func(reverse bool) string {
+		bazelContext, _ := testBazelContext(t, map[bazelCommand]string{})
+		for i := range labels {
+			label := labels[i]
+			if reverse {
+				label = labels[len(labels)-1-i]
+			}
+			for _, cfg := range configs {
+				bazelContext.GetOutputFiles(label, cfg)
+			}
+		}
+		return string(bazelContext.mainBuildFileContents())
+	}
+
+	want := render(false)
+	for i := 0; i < 10; i++ {
+		if got := render(i%2 == 1); got != want {
+			t.Fatalf("Expected identical BUILD file contents across renders, got:\n%s\nand:\n%s", want, got)
+		}
+	}
+	if a, c := strings.Index(want, `"//foo:a"`), strings.Index(want, `"//bar:c"`); c > a {
+		t.Errorf("Expected labels to be sorted, got:\n%s", want)
+	}
+}
+
 func TestInvokeBazelPopulatesBuildStatements(t *testing.T) {
 	bazelContext, _ := testBazelContext(t, map[bazelCommand]string{
 		bazelCommand{command: "aquery", expression: "deps(@soong_injection//mixed_builds:buildroot)"}: `
@@ -98,6 +288,670 @@ func TestInvokeBazelPopulatesBuildStatements(t *testing.T) {
 	}
 }
 
+func TestInvokeBazelReadsOfflineResults(t *testing.T) {
+	bazelContext, baseDir := testBazelContext(t, map[bazelCommand]string{})
+	cqueryFile := filepath.Join(baseDir, "cquery.out")
+	aqueryFile := filepath.Join(baseDir, "aquery.json")
+	if err := ioutil.WriteFile(cqueryFile, []byte(`//foo:bar|arm64_armv8-a|android>>out/foo/bar.txt`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	aquery := `
+{
+  "artifacts": [{
+    "id": 1,
+    "pathFragmentId": 1
+  }],
+  "actions": [{
+    "targetId": 1,
+    "actionKey": "x",
+    "mnemonic": "x",
+    "arguments": ["touch", "foo"],
+    "outputIds": [1],
+    "primaryOutputId": 1
+  }],
+  "pathFragments": [{
+    "id": 1,
+    "label": "one"
+  }]
+}`
+	if err := ioutil.WriteFile(aqueryFile, []byte(aquery), 0666); err != nil {
+		t.Fatal(err)
+	}
+	bazelContext.offlineResults = []string{cqueryFile, aqueryFile}
+
+	label := "//foo:bar"
+	cfg := configKey{"arm64_armv8-a", Android}
+	bazelContext.GetOutputFiles(label, cfg)
+	if err := bazelContext.InvokeBazel(); err != nil {
+		t.Fatalf("Did not expect error invoking Bazel, but got %s", err)
+	}
+
+	if commands := bazelContext.bazelRunner.(*mockBazelRunner).commands; len(commands) > 0 {
+		t.Errorf("Expected no bazel commands to be issued, got %v", commands)
+	}
+	g, ok := bazelContext.GetOutputFiles(label, cfg)
+	if !ok {
+		t.Errorf("Expected cquery results after running InvokeBazel(), but got none")
+	} else if w := []string{"out/foo/bar.txt"}; !reflect.DeepEqual(w, g) {
+		t.Errorf("Expected output %s, got %s", w, g)
+	}
+	if got := bazelContext.BuildStatementsToRegister(); len(got) != 1 {
+		t.Errorf("Expected 1 registered build statement, got %#v", got)
+	}
+}
+
+func TestInvokeBazelOfflineResultsMissingRequests(t *testing.T) {
+	bazelContext, baseDir := testBazelContext(t, map[bazelCommand]string{})
+	cqueryFile := filepath.Join(baseDir, "cquery.out")
+	if err := ioutil.WriteFile(cqueryFile, []byte(`//foo:bar|arm64_armv8-a|android>>out/foo/bar.txt`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	bazelContext.offlineResults = []string{cqueryFile}
+
+	bazelContext.GetOutputFiles("//foo:bar", configKey{"arm64_armv8-a", Android})
+	bazelContext.GetOutputFiles("//foo:baz", configKey{"arm64_armv8-a", Android})
+	err := bazelContext.InvokeBazel()
+	if err == nil {
+		t.Fatalf("Expected an error for the missing offline result")
+	}
+	want := "offline results " + cqueryFile + " are missing results for bazel targets: //foo:baz|arm64_armv8-a|android"
+	if err.Error() != want {
+		t.Errorf("Expected error %q, got %q", want, err)
+	}
+	var missingErr *MissingCqueryResultError
+	if !errors.As(err, &missingErr) {
+		t.Fatalf("Expected a MissingCqueryResultError, got %#v", err)
+	}
+	AssertDeepEquals(t, "missing ids", []string{"//foo:baz|arm64_armv8-a|android"}, missingErr.MissingIds)
+}
+
+func TestInvokeBazelMissingCqueryResults(t *testing.T) {
+	bazelContext, baseDir := testBazelContext(t, map[bazelCommand]string{
+		bazelCommand{command: "cquery", expression: "deps(@soong_injection//mixed_builds:buildroot, 2)"}: `//foo:bar|arm64_armv8-a|android>>out/foo/bar.txt`,
+	})
+	bazelContext.GetOutputFiles("//foo:bar", configKey{"arm64_armv8-a", Android})
+	bazelContext.GetOutputFiles("//foo:qux", configKey{"arm64_armv8-a", Android})
+	bazelContext.GetOutputFiles("//foo:baz", configKey{"arm64_armv8-a", Android})
+
+	err := bazelContext.InvokeBazel()
+	var missingErr *MissingCqueryResultError
+	if !errors.As(err, &missingErr) {
+		t.Fatalf("Expected a MissingCqueryResultError, got %#v", err)
+	}
+	AssertDeepEquals(t, "missing ids",
+		[]string{"//foo:baz|arm64_armv8-a|android", "//foo:qux|arm64_armv8-a|android"}, missingErr.MissingIds)
+	AssertStringEquals(t, "cquery output file",
+		filepath.Join(baseDir, "soong_injection", "cquery.out"), missingErr.CqueryOutputFile)
+	AssertBoolEquals(t, "offline", false, missingErr.Offline)
+}
+
+func TestInvokeBazelAllowsPartialResults(t *testing.T) {
+	cquery := bazelCommand{command: "cquery", expression: "deps(@soong_injection//mixed_builds:buildroot, 2)"}
+	phonyroot := bazelCommand{command: "build", expression: "@soong_injection//mixed_builds:phonyroot"}
+	bazelContext, _ := testBazelContext(t, map[bazelCommand]string{
+		cquery: `//foo:bar|arm64_armv8-a|android>>out/foo/bar.txt`,
+	})
+	bazelContext.allowPartialResults = true
+	// Bazel fails with --keep_going if some of the targets failed.
+	bazelContext.bazelRunner.(*mockBazelRunner).bazelCommandErrors = map[bazelCommand]error{
+		cquery:    errors.New("cquery failed"),
+		phonyroot: errors.New("build failed"),
+	}
+	cfg := configKey{"arm64_armv8-a", Android}
+	bazelContext.GetOutputFiles("//foo:bar", cfg)
+	bazelContext.GetOutputFiles("//foo:baz", cfg)
+
+	if err := bazelContext.InvokeBazel(); err != nil {
+		t.Fatalf("Did not expect error invoking Bazel, but got %s", err)
+	}
+	AssertStringListContains(t, "cquery flags",
+		bazelContext.bazelRunner.(*mockBazelRunner).extraFlags[cquery], "--keep_going")
+	AssertDeepEquals(t, "failed ids", []string{"//foo:baz|arm64_armv8-a|android"}, bazelContext.FailedCqueryIds())
+
+	// The module of the successful target uses its results, the other one falls back to Soong.
+	outputs, ok := bazelContext.GetOutputFiles("//foo:bar", cfg)
+	AssertBoolEquals(t, "bar ok", true, ok)
+	AssertDeepEquals(t, "bar outputs", []string{"out/foo/bar.txt"}, outputs)
+	_, ok = bazelContext.GetOutputFiles("//foo:baz", cfg)
+	AssertBoolEquals(t, "baz ok", false, ok)
+}
+
+func TestInvokeBazelPartialResultsRequireSomeResults(t *testing.T) {
+	cquery := bazelCommand{command: "cquery", expression: "deps(@soong_injection//mixed_builds:buildroot, 2)"}
+	bazelContext, _ := testBazelContext(t, map[bazelCommand]string{})
+	bazelContext.allowPartialResults = true
+	bazelContext.bazelRunner.(*mockBazelRunner).bazelCommandErrors = map[bazelCommand]error{
+		cquery: errors.New("server crashed"),
+	}
+	cfg := configKey{"arm64_armv8-a", Android}
+	bazelContext.GetOutputFiles("//foo:bar", cfg)
+	bazelContext.GetOutputFiles("//foo:baz", cfg)
+
+	err := bazelContext.InvokeBazel()
+	AssertErrorMessageEquals(t, "cquery error", "server crashed", err)
+}
+
+func TestInvokeBazelPartialResultsDoNotTolerateBuildErrorsWithoutFailedTargets(t *testing.T) {
+	cquery := bazelCommand{command: "cquery", expression: "deps(@soong_injection//mixed_builds:buildroot, 2)"}
+	phonyroot := bazelCommand{command: "build", expression: "@soong_injection//mixed_builds:phonyroot"}
+	bazelContext, _ := testBazelContext(t, map[bazelCommand]string{
+		cquery: `//foo:bar|arm64_armv8-a|android>>out/foo/bar.txt`,
+	})
+	bazelContext.allowPartialResults = true
+	bazelContext.bazelRunner.(*mockBazelRunner).bazelCommandErrors = map[bazelCommand]error{
+		phonyroot: errors.New("build timed out"),
+	}
+	bazelContext.GetOutputFiles("//foo:bar", configKey{"arm64_armv8-a", Android})
+
+	err := bazelContext.InvokeBazel()
+	AssertErrorMessageEquals(t, "build error", "build timed out", err)
+}
+
+func TestModulesWithFailedBazelTargets(t *testing.T) {
+	result := GroupFixturePreparers(
+		PrepareForTestWithFilegroup,
+		FixtureAddTextFile("foo/Android.bp", `
+			filegroup {
+				name: "foo",
+			}
+		`),
+		FixtureModifyConfig(func(config Config) {
+			config.BazelContext = MockBazelContext{
+				FailedIds: []string{
+					"//foo:foo|common|common",
+					"//bar:handcrafted|arm64_armv8-a|android",
+				},
+			}
+		}),
+	).RunTestWithBp(t, `
+		filegroup {
+			name: "bar",
+			bazel_module: { label: "//bar:handcrafted" },
+		}
+
+		filegroup {
+			name: "baz",
+			bazel_module: { label: "//baz:baz" },
+		}
+	`)
+
+	AssertDeepEquals(t, "modules", []string{"bar", "foo"},
+		ModulesWithFailedBazelTargets(result.TestContext.Context, result.Config))
+}
+
+func readCqueryMetrics(t *testing.T, metricsDir string) cqueryMetrics {
+	t.Helper()
+	contents, err := ioutil.ReadFile(filepath.Join(metricsDir, cqueryMetricsFilename))
+	if err != nil {
+		t.Fatalf("Expected cquery metrics to be written, got %s", err)
+	}
+	var metrics cqueryMetrics
+	if err := json.Unmarshal(contents, &metrics); err != nil {
+		t.Fatal(err)
+	}
+	return metrics
+}
+
+func TestInvokeBazelWritesCqueryMetrics(t *testing.T) {
+	label := "//foo:bar"
+	cfg := configKey{"arm64_armv8-a", Android}
+	cqueryOutput := `//foo:bar|arm64_armv8-a|android>>out/foo/bar.txt`
+
+	// A fresh invocation doesn't use offline results.
+	bazelContext, baseDir := testBazelContext(t, map[bazelCommand]string{
+		bazelCommand{command: "cquery", expression: "deps(@soong_injection//mixed_builds:buildroot, 2)"}: cqueryOutput,
+	})
+	metricsDir := filepath.Join(baseDir, "metrics")
+	bazelContext.paths.metricsDir = metricsDir
+	bazelContext.GetOutputFiles(label, cfg)
+	if err := bazelContext.InvokeBazel(); err != nil {
+		t.Fatalf("Did not expect error invoking Bazel, but got %s", err)
+	}
+	if g, w := readCqueryMetrics(t, metricsDir), (cqueryMetrics{OfflineResults: false, Requests: 1}); g != w {
+		t.Errorf("Expected cquery metrics %#v for a fresh run, got %#v", w, g)
+	}
+
+	// Results read from the offline results are recorded as such.
+	offlineContext, offlineBaseDir := testBazelContext(t, map[bazelCommand]string{})
+	cqueryFile := filepath.Join(offlineBaseDir, "cquery.out")
+	if err := ioutil.WriteFile(cqueryFile, []byte(cqueryOutput), 0666); err != nil {
+		t.Fatal(err)
+	}
+	offlineContext.offlineResults = []string{cqueryFile}
+	offlineContext.paths.metricsDir = metricsDir
+	offlineContext.GetOutputFiles(label, cfg)
+	if err := offlineContext.InvokeBazel(); err != nil {
+		t.Fatalf("Did not expect error invoking Bazel, but got %s", err)
+	}
+	if g, w := readCqueryMetrics(t, metricsDir), (cqueryMetrics{OfflineResults: true, Requests: 1}); g != w {
+		t.Errorf("Expected cquery metrics %#v for an offline run, got %#v", w, g)
+	}
+	if commands := offlineContext.bazelRunner.(*mockBazelRunner).commands; len(commands) > 0 {
+		t.Errorf("Expected no bazel commands to be issued for an offline run, got %v", commands)
+	}
+}
+
+func TestPackagePathFlags(t *testing.T) {
+	p := bazelPaths{}
+	if flags := p.packagePathFlags(); len(flags) > 0 {
+		t.Errorf("Expected no package path flags without extra package paths, got %q", flags)
+	}
+
+	p.extraPackagePaths = []string{"out/soong/generated", "/abs/generated"}
+	AssertArrayString(t, "package path flags", []string{
+		"--package_path=%workspace%:%workspace%/out/soong/generated:/abs/generated",
+	}, p.packagePathFlags())
+}
+
+func TestIssueBazelCommandTimeout(t *testing.T) {
+	soongOutDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(soongOutDir, "workspace"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	// A fake bazel that hangs. exec replaces the shell so that killing the process also closes
+	// its output.
+	bazelPath := filepath.Join(soongOutDir, "bazel")
+	if err := ioutil.WriteFile(bazelPath, []byte("#!/bin/sh\nexec sleep 60\n"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	paths := &bazelPaths{
+		soongOutDir:    soongOutDir,
+		bazelPath:      bazelPath,
+		metricsDir:     soongOutDir,
+		commandTimeout: 100 * time.Millisecond,
+	}
+
+	start := time.Now()
+	_, _, err := (&builtinBazelRunner{}).issueBazelCommand(paths, bazel.CqueryBuildRootRunName,
+		bazelCommand{command: "cquery", expression: "//foo:bar"})
+	if err == nil || !strings.Contains(err.Error(), "bazel command timed out after 100ms") {
+		t.Errorf("Expected a timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Second {
+		t.Errorf("Expected the bazel command to be killed after the timeout, but it ran for %s", elapsed)
+	}
+}
+
+func TestBuildStatementsToRegisterExcludesMnemonics(t *testing.T) {
+	bazelContext, _ := testBazelContext(t, map[bazelCommand]string{})
+	bazelContext.buildStatements = []bazel.BuildStatement{
+		{Command: "touch foo", Mnemonic: "Genrule"},
+		{Command: "write bar", Mnemonic: "FileWrite"},
+		{Command: "link baz", Mnemonic: "SymlinkTree"},
+	}
+	bazelContext.excludedMnemonics = map[string]bool{"FileWrite": true, "SymlinkTree": true}
+
+	got := bazelContext.BuildStatementsToRegister()
+	if len(got) != 1 || got[0].Mnemonic != "Genrule" {
+		t.Errorf("Expected only the Genrule build statement to be registered, got %#v", got)
+	}
+}
+
+func TestReadBazelBuildList(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "BUILD.bazel")
+	if err := ioutil.WriteFile(existing, nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "deleted", "BUILD.bazel")
+	bazelBuildList := filepath.Join(dir, "bazel.list")
+	if err := ioutil.WriteFile(bazelBuildList, []byte(existing+"\n"+missing+"\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	files, warnings, err := readBazelBuildList(bazelBuildList)
+	if err != nil {
+		t.Fatalf("Unexpected error reading bazel.list: %s", err)
+	}
+	AssertArrayString(t, "files", []string{existing}, files)
+	AssertArrayString(t, "warnings", []string{
+		fmt.Sprintf("ignoring nonexistent file %q listed in %s", missing, bazelBuildList),
+	}, warnings)
+
+	if _, _, err := readBazelBuildList(filepath.Join(dir, "missing.list")); err == nil {
+		t.Errorf("Expected an error reading a nonexistent bazel.list")
+	}
+}
+
+func TestSkipBuildStatementsWithoutCommand(t *testing.T) {
+	buildStatements := []bazel.BuildStatement{
+		{Command: "touch foo", Mnemonic: "Genrule", OutputPaths: []string{"bazel-out/foo"}},
+		{Command: "", Mnemonic: "NewActionType", OutputPaths: []string{"bazel-out/bar"}},
+	}
+
+	kept, warnings := skipBuildStatementsWithoutCommand(buildStatements)
+	if len(kept) != 1 || kept[0].Mnemonic != "Genrule" {
+		t.Errorf("Expected only the Genrule build statement to be kept, got %#v", kept)
+	}
+	AssertArrayString(t, "warnings", []string{
+		`skipping bazel build statement 1 (NewActionType) with outputs ["bazel-out/bar"], which has no command`,
+	}, warnings)
+}
+
+func TestCheckBazelBuildStatementInputs(t *testing.T) {
+	config := TestConfig(t.TempDir(), nil, "", map[string][]byte{
+		"foo/foo.c": nil,
+	})
+	ctx := PathContextForTesting(config)
+
+	buildStatements := []bazel.BuildStatement{
+		{
+			Command:     "compile foo",
+			Mnemonic:    "CppCompile",
+			InputPaths:  []string{"foo/foo.c", "foo/missing.h"},
+			OutputPaths: []string{"bazel-out/foo.o"},
+		},
+		{
+			Command:     "link foo",
+			Mnemonic:    "CppLink",
+			InputPaths:  []string{"bazel-out/foo.o", "bazel-out/foo.so.link"},
+			OutputPaths: []string{"bazel-out/foo.so"},
+		},
+		{
+			Command:      "symlink foo",
+			Mnemonic:     "Symlink",
+			InputPaths:   []string{"bazel-out/foo.so"},
+			SymlinkPaths: []string{"bazel-out/foo.so.link"},
+		},
+	}
+
+	errs := checkBazelBuildStatementInputs(ctx, buildStatements)
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 error, got %q", errs)
+	}
+	AssertStringEquals(t, "error",
+		`bazel build statement 0 (CppCompile) references input "foo/missing.h", `+
+			`which does not exist and is not an output of any build statement`,
+		errs[0].Error())
+}
+
+func TestInvokeBazelWritesBuildEventFile(t *testing.T) {
+	bazelContext, baseDir := testBazelContext(t, map[bazelCommand]string{})
+	bazelContext.paths.metricsDir = filepath.Join(baseDir, "metrics")
+	bazelContext.buildEventFile = filepath.Join(bazelContext.paths.metricsDir, "bep.json")
+	err := bazelContext.InvokeBazel()
+	if err != nil {
+		t.Fatalf("Did not expect error invoking Bazel, but got %s", err)
+	}
+
+	if got, want := bazelContext.BuildEventFile(), filepath.Join(baseDir, "metrics", "bep.json"); got != want {
+		t.Errorf("Expected build event file %q, got %q", want, got)
+	}
+
+	wantFlag := "--build_event_json_file=" + absolutePath(bazelContext.BuildEventFile())
+	extraFlags := bazelContext.bazelRunner.(*mockBazelRunner).extraFlags
+	for _, command := range []bazelCommand{
+		{command: "aquery", expression: "deps(@soong_injection//mixed_builds:buildroot)"},
+		{command: "build", expression: "@soong_injection//mixed_builds:phonyroot"},
+	} {
+		if !InList(wantFlag, extraFlags[command]) {
+			t.Errorf("Expected %s flags to contain %q, got %q", command.command, wantFlag, extraFlags[command])
+		}
+	}
+
+	cquery := bazelCommand{command: "cquery", expression: "deps(@soong_injection//mixed_builds:buildroot, 2)"}
+	if InList(wantFlag, extraFlags[cquery]) {
+		t.Errorf("Expected cquery flags not to contain %q, got %q", wantFlag, extraFlags[cquery])
+	}
+}
+
+func TestBuildEventFileDisabledByDefault(t *testing.T) {
+	bazelContext, _ := testBazelContext(t, map[bazelCommand]string{})
+	if got := bazelContext.BuildEventFile(); got != "" {
+		t.Errorf("Expected no build event file, got %q", got)
+	}
+	if got := bazelContext.buildEventFlags(); len(got) != 0 {
+		t.Errorf("Expected no build event flags, got %q", got)
+	}
+}
+
+func TestWarmUpIssuesInfoBeforeInvokeBazel(t *testing.T) {
+	bazelContext, _ := testBazelContext(t, map[bazelCommand]string{})
+	bazelContext.WarmUp()
+	err := bazelContext.InvokeBazel()
+	if err != nil {
+		t.Fatalf("Did not expect error invoking Bazel, but got %s", err)
+	}
+
+	commands := bazelContext.bazelRunner.(*mockBazelRunner).commands
+	warmUp := bazelCommand{command: "info", expression: "release"}
+	if len(commands) == 0 || commands[0] != warmUp {
+		t.Errorf("Expected %v to be issued first, got %v", warmUp, commands)
+	}
+}
+
+func TestWarmUpFinishesBeforeInvokeBazelFails(t *testing.T) {
+	bazelContext, soongOutDir := testBazelContext(t, map[bazelCommand]string{})
+	// A file where the intermediates directory should be makes dumping the requests fail.
+	if err := ioutil.WriteFile(filepath.Join(soongOutDir, "bazel"), nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	bazelContext.dumpRequests = true
+	bazelContext.WarmUp()
+	if err := bazelContext.InvokeBazel(); err == nil {
+		t.Fatalf("Expected error dumping the requests, but got none")
+	}
+
+	commands := bazelContext.bazelRunner.(*mockBazelRunner).commands
+	warmUp := bazelCommand{command: "info", expression: "release"}
+	if len(commands) != 1 || commands[0] != warmUp {
+		t.Errorf("Expected only %v to be issued, got %v", warmUp, commands)
+	}
+}
+
+func TestWarmUpDumpOnly(t *testing.T) {
+	bazelContext, _ := testBazelContext(t, map[bazelCommand]string{})
+	bazelContext.dumpOnly = true
+	bazelContext.WarmUp()
+	if bazelContext.warmUpDone != nil {
+		t.Errorf("Expected no warmup to be started in dump-only mode")
+	}
+	if commands := bazelContext.bazelRunner.(*mockBazelRunner).commands; len(commands) > 0 {
+		t.Errorf("Expected no bazel commands to be issued, but got %v", commands)
+	}
+}
+
+func TestDumpQueuedRequests(t *testing.T) {
+	bazelContext, soongOutDir := testBazelContext(t, map[bazelCommand]string{})
+	bazelContext.dumpRequests = true
+	bazelContext.dumpOnly = true
+	bazelContext.GetOutputFiles("//foo:bar", configKey{"arm64_armv8-a", Android})
+	bazelContext.GetCcInfo("//foo:baz", configKey{"x86_64", Android})
+
+	if err := bazelContext.InvokeBazel(); err != nil {
+		t.Fatalf("Did not expect error invoking Bazel, but got %s", err)
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(soongOutDir, "bazel", "requests.json"))
+	if err != nil {
+		t.Fatalf("Expected requests.json to be written, but got %s", err)
+	}
+	var requests []queuedRequest
+	if err := json.Unmarshal(contents, &requests); err != nil {
+		t.Fatalf("Expected valid JSON, but got %s: %s", err, contents)
+	}
+	AssertDeepEquals(t, "queued requests", []queuedRequest{
+		{Label: "//foo:bar", RequestType: "getOutputFiles", Arch: "arm64_armv8-a", Os: "android"},
+		{Label: "//foo:baz", RequestType: "getCcInfo", Arch: "x86_64", Os: "android"},
+	}, requests)
+}
+
+func TestSourceRepositoryFromConfig(t *testing.T) {
+	testCases := []struct {
+		description string
+		repository  string
+		expected    string
+	}{
+		{description: "unset", expected: "@sourceroot//foo:bar"},
+		{description: "set", repository: "src", expected: "@src//foo:bar"},
+	}
+	for _, tc := range testCases {
+		env := map[string]string{
+			"BAZEL_HOME":        "home",
+			"BAZEL_PATH":        "bazel",
+			"BAZEL_OUTPUT_BASE": "output_base",
+			"BAZEL_WORKSPACE":   "workspace",
+			"BAZEL_METRICS_DIR": "metrics",
+		}
+		if tc.repository != "" {
+			env["SOONG_BAZEL_SOURCE_REPOSITORY"] = tc.repository
+		}
+		config := TestConfig(t.TempDir(), env, "", nil)
+		p, err := bazelPathsFromConfig(config.config)
+		if err != nil {
+			t.Fatalf("%s: did not expect error, but got %s", tc.description, err)
+		}
+		AssertStringEquals(t, tc.description+" label", tc.expected, p.canonicalizeLabel("//foo:bar"))
+		AssertStringEquals(t, tc.description+" cquery label", tc.expected, p.cqueryLabel("//foo:bar"))
+	}
+}
+
+func TestSourceRepositoryQualifiesLabels(t *testing.T) {
+	defer func(goos, goarch string) { hostGOOS, hostGOARCH = goos, goarch }(hostGOOS, hostGOARCH)
+	hostGOOS, hostGOARCH = "linux", "amd64"
+
+	cfg := configKey{"arm64_armv8-a", Android}
+	bazelContext, _ := testBazelContext(t, map[bazelCommand]string{
+		bazelCommand{command: "cquery", expression: "deps(@soong_injection//mixed_builds:buildroot, 2)"}: `@src//foo:bar|arm64_armv8-a|android>>out/foo/bar.txt`,
+	})
+	bazelContext.paths.sourceRepository = "src"
+	bazelContext.GetOutputFiles("//foo:bar", cfg)
+
+	AssertStringDoesContain(t, "BUILD file labels",
+		string(bazelContext.mainBuildFileContents()), `"@src//foo:bar"`)
+	AssertStringDoesContain(t, "cquery ids",
+		string(bazelContext.cqueryStarlarkFileContents()), `"@src//foo:bar|arm64_armv8-a|android"`)
+	platformFlags, err := bazelContext.paths.platformFlags()
+	if err != nil {
+		t.Fatalf("Did not expect error getting the platform flags, but got %s", err)
+	}
+	AssertArrayString(t, "platform flags", []string{
+		"--platforms=@src//build/bazel/platforms:android_target",
+		"--extra_toolchains=@src//prebuilts/clang/host/linux-x86:all",
+		"--host_platform=@src//build/bazel/platforms:linux_x86_64",
+	}, platformFlags)
+
+	err = bazelContext.InvokeBazel()
+	if err != nil {
+		t.Fatalf("Did not expect error invoking Bazel, but got %s", err)
+	}
+	g, ok := bazelContext.GetOutputFiles("//foo:bar", cfg)
+	if !ok {
+		t.Errorf("Expected cquery results after running InvokeBazel(), but got none")
+	} else if w := []string{"out/foo/bar.txt"}; !reflect.DeepEqual(w, g) {
+		t.Errorf("Expected output %s, got %s", w, g)
+	}
+}
+
+func TestResolvedOutputFiles(t *testing.T) {
+	arm64 := configKey{"arm64_armv8-a", Android}
+	x86_64 := configKey{"x86_64", Android}
+	bazelContext, _ := testBazelContext(t, map[bazelCommand]string{
+		bazelCommand{command: "cquery", expression: "deps(@soong_injection//mixed_builds:buildroot, 2)"}: `//foo:bar|arm64_armv8-a|android>>out/foo/bar.txt
+//foo:bar|x86_64|android>>out/foo/bar_x86_64.txt
+//foo:baz|arm64_armv8-a|android>>out/foo/baz.txt`,
+	})
+	bazelContext.GetOutputFiles("//foo:bar", arm64)
+	bazelContext.GetOutputFiles("//foo:bar", x86_64)
+	bazelContext.GetOutputFiles("//foo:baz", arm64)
+
+	AssertDeepEquals(t, "resolved output files before InvokeBazel", map[string][]string{},
+		bazelContext.ResolvedOutputFiles())
+
+	err := bazelContext.InvokeBazel()
+	if err != nil {
+		t.Fatalf("Did not expect error invoking Bazel, but got %s", err)
+	}
+	AssertDeepEquals(t, "resolved output files", map[string][]string{
+		"//foo:bar|arm64_armv8-a|android": {"out/foo/bar.txt"},
+		"//foo:bar|x86_64|android":        {"out/foo/bar_x86_64.txt"},
+		"//foo:baz|arm64_armv8-a|android": {"out/foo/baz.txt"},
+	}, bazelContext.ResolvedOutputFiles())
+}
+
+func TestHostPlatformFlag(t *testing.T) {
+	defer func(goos, goarch string) { hostGOOS, hostGOARCH = goos, goarch }(hostGOOS, hostGOARCH)
+
+	testCases := []struct {
+		goos, goarch  string
+		expected      string
+		expectedError string
+	}{
+		{goos: "linux", goarch: "amd64", expected: "--host_platform=@src//build/bazel/platforms:linux_x86_64"},
+		{goos: "darwin", goarch: "amd64", expected: "--host_platform=@src//build/bazel/platforms:darwin_x86_64"},
+		{goos: "darwin", goarch: "arm64", expected: "--host_platform=@src//build/bazel/platforms:darwin_arm64"},
+		{goos: "linux", goarch: "arm64", expectedError: "mixed builds are not supported on linux/arm64 hosts"},
+		{goos: "windows", goarch: "amd64", expectedError: "mixed builds are not supported on windows/amd64 hosts"},
+	}
+	for _, tc := range testCases {
+		hostGOOS, hostGOARCH = tc.goos, tc.goarch
+		p := bazelPaths{sourceRepository: "src"}
+		flags, err := p.platformFlags()
+		if tc.expectedError != "" {
+			if err == nil || err.Error() != tc.expectedError {
+				t.Errorf("%s/%s: expected error %q, got %v", tc.goos, tc.goarch, tc.expectedError, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s/%s: did not expect error, but got %s", tc.goos, tc.goarch, err)
+		}
+		AssertStringListContains(t, tc.goos+"/"+tc.goarch+" platform flags", flags, tc.expected)
+	}
+}
+
+func TestIncrementalAquery(t *testing.T) {
+	fullAquery := bazelCommand{command: "aquery", expression: "deps(@soong_injection//mixed_builds:buildroot)"}
+	skyframeAquery := bazelCommand{command: "aquery", expression: ""}
+
+	testCases := []struct {
+		release   string
+		supported bool
+	}{
+		{"release 6.0.0", true},
+		{"release 6.1.0-pre.20221102.3", true},
+		{"release 5.3.0", false},
+		{"development version", false},
+	}
+	for _, tc := range testCases {
+		bazelContext, _ := testBazelContext(t, map[bazelCommand]string{
+			bazelCommand{command: "info", expression: "release"}: tc.release,
+			skyframeAquery: "{}\n",
+		})
+		bazelContext.incrementalAquery = true
+		if err := bazelContext.InvokeBazel(); err != nil {
+			t.Fatalf("Did not expect error invoking Bazel, but got %s", err)
+		}
+
+		extraFlags := bazelContext.bazelRunner.(*mockBazelRunner).extraFlags
+		if tc.supported {
+			AssertStringListContains(t, tc.release+" aquery flags", extraFlags[skyframeAquery], "--skyframe_state")
+			if _, ok := extraFlags[fullAquery]; ok {
+				t.Errorf("%s: expected no full aquery, got flags %q", tc.release, extraFlags[fullAquery])
+			}
+		} else {
+			AssertStringListDoesNotContain(t, tc.release+" aquery flags", extraFlags[fullAquery], "--skyframe_state")
+			if _, ok := extraFlags[skyframeAquery]; ok {
+				t.Errorf("%s: expected no skyframe state aquery, got flags %q", tc.release, extraFlags[skyframeAquery])
+			}
+		}
+	}
+}
+
+func TestIncrementalAqueryDisabledByDefault(t *testing.T) {
+	bazelContext, _ := testBazelContext(t, map[bazelCommand]string{
+		bazelCommand{command: "info", expression: "release"}: "release 6.0.0",
+	})
+	if err := bazelContext.InvokeBazel(); err != nil {
+		t.Fatalf("Did not expect error invoking Bazel, but got %s", err)
+	}
+	for _, command := range bazelContext.bazelRunner.(*mockBazelRunner).commands {
+		if command.command == "info" {
+			t.Errorf("Expected no version check, got %v", command)
+		}
+	}
+}
+
 func testBazelContext(t *testing.T, bazelCommandResults map[bazelCommand]string) (*bazelContext, string) {
 	t.Helper()
 	p := bazelPaths{
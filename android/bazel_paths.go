@@ -93,6 +93,7 @@ type BazelConversionPathContext interface {
 	ModuleFromName(name string) (blueprint.Module, bool)
 	AddUnconvertedBp2buildDep(string)
 	AddMissingBp2buildDep(dep string)
+	AddCrossPackageBp2buildFile(label string)
 }
 
 // BazelLabelForModuleDeps expects a list of reference to other modules, ("<module>"
@@ -226,6 +227,8 @@ func directoryHasBlueprint(fs pathtools.FileSystem, prefix string, components []
 // if the "async_safe" directory is actually a package and not just a directory.
 //
 // In particular, paths that extend into packages are transformed into absolute labels beginning with //.
+// As Bazel only allows referencing the files of another package if it exports them, such labels are
+// recorded on the module so that bp2build can emit the exports_files() of the other package.
 func transformSubpackagePath(ctx BazelConversionPathContext, path bazel.Label) bazel.Label {
 	var newPath bazel.Label
 
@@ -269,6 +272,7 @@ func transformSubpackagePath(ctx BazelConversionPathContext, path bazel.Label) b
 		} else {
 			newLabel = "//" + moduleDir + "/" + newLabel
 		}
+		ctx.AddCrossPackageBp2buildFile(newLabel)
 	}
 	newPath.Label = newLabel
 
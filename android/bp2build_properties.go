@@ -0,0 +1,140 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/google/blueprint/proptools"
+)
+
+// Top level properties that are handled by the bp2build framework rather than by the converter of
+// an individual module type.
+var bp2buildFrameworkProperties = map[string]bool{
+	"bazel_module":        true,
+	"bazel_rule_override": true,
+	"bazel_tags":          true,
+	"defaults":            true,
+}
+
+// Properties that hold architecture, os or multilib specific versions of other properties, e.g.
+// arch: { arm: { srcs: [...] } }. Their nested properties are considered consumed if the
+// converter consumed the property of the same name at the top level.
+var bp2buildVariantProperties = map[string]bool{
+	"arch":     true,
+	"multilib": true,
+	"target":   true,
+}
+
+// MarkBp2buildPropertiesConsumed records that the bp2build converter of the current module has
+// read the given properties, e.g. "srcs" or "proto.type". Once a converter has reported any
+// consumed properties, every other property set on the module is reported as dropped.
+func (t *topDownMutatorContext) MarkBp2buildPropertiesConsumed(names ...string) {
+	consumed := &t.Module().base().commonProperties.Bp2buildConsumedProperties
+	*consumed = append(*consumed, names...)
+}
+
+// GetDroppedBp2buildProperties returns the properties that are set on this module but were not
+// consumed by its bp2build converter, sorted by name. It returns nil if the converter did not
+// report the properties it consumed.
+func (m *ModuleBase) GetDroppedBp2buildProperties() []string {
+	consumed := m.commonProperties.Bp2buildConsumedProperties
+	if len(consumed) == 0 {
+		return nil
+	}
+
+	frameworkProps := map[interface{}]bool{
+		&m.nameProperties:          true,
+		&m.commonProperties:        true,
+		&m.distProperties:          true,
+		&m.hostAndDeviceProperties: true,
+	}
+
+	var dropped []string
+	for _, props := range m.GetProperties() {
+		if frameworkProps[props] {
+			continue
+		}
+		for _, name := range setPropertyNames("", reflect.ValueOf(props)) {
+			if !isBp2buildPropertyConsumed(name, consumed) {
+				dropped = append(dropped, name)
+			}
+		}
+	}
+	dropped = FirstUniqueStrings(dropped)
+	sort.Strings(dropped)
+	return dropped
+}
+
+// isBp2buildPropertyConsumed returns true if the property with the given dotted name, or one of
+// its enclosing properties, was consumed.
+func isBp2buildPropertyConsumed(name string, consumed []string) bool {
+	if bp2buildFrameworkProperties[strings.SplitN(name, ".", 2)[0]] {
+		return true
+	}
+	// arch.arm.srcs is consumed along with srcs.
+	if parts := strings.SplitN(name, ".", 3); len(parts) == 3 && bp2buildVariantProperties[parts[0]] {
+		name = parts[2]
+	}
+	for _, c := range consumed {
+		if name == c || strings.HasPrefix(name, c+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// setPropertyNames returns the dotted names of the properties in v that are set to a non-zero
+// value.
+func setPropertyNames(prefix string, v reflect.Value) []string {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if elem := v.Elem(); elem.Kind() == reflect.Struct || elem.Kind() == reflect.Ptr {
+			return setPropertyNames(prefix, elem)
+		}
+		return []string{prefix}
+	case reflect.Struct:
+		var names []string
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if (field.PkgPath != "" && !field.Anonymous) || proptools.HasTag(field, "blueprint", "mutated") {
+				continue
+			}
+			name := prefix
+			if !field.Anonymous {
+				name = joinPropertyName(prefix, proptools.PropertyNameForField(field.Name))
+			}
+			names = append(names, setPropertyNames(name, v.Field(i))...)
+		}
+		return names
+	default:
+		if v.IsZero() {
+			return nil
+		}
+		return []string{prefix}
+	}
+}
+
+func joinPropertyName(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
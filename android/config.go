@@ -159,6 +159,7 @@ type config struct {
 
 	runningAsBp2Build              bool
 	bp2buildPackageConfig          Bp2BuildConfig
+	bp2buildModuleDenylist         map[string]bool
 	Bp2buildSoongConfigDefinitions soongconfig.Bp2BuildSoongConfigDefinitions
 
 	// If testAllowNonExistentPaths is true then PathForSource and PathForModuleSrc won't error
@@ -920,6 +921,12 @@ func (c *config) MinimizeJavaDebugInfo() bool {
 	return Bool(c.productVariables.MinimizeJavaDebugInfo) && !Bool(c.productVariables.Eng)
 }
 
+// JavaToolJvmArgs returns the extra JVM arguments, e.g. "-XX:+UseParallelGC", to pass to the Java
+// compilers and dexers.
+func (c *config) JavaToolJvmArgs() []string {
+	return c.productVariables.JavaToolJvmArgs
+}
+
 func (c *config) Debuggable() bool {
 	return Bool(c.productVariables.Debuggable)
 }
@@ -1406,6 +1413,11 @@ func (c *config) VendorConfig(name string) VendorConfig {
 	return soongconfig.Config(c.productVariables.VendorVars[name])
 }
 
+// ReleaseFlagEnabled returns true if the release flag with the given name is set to "true".
+func (c *config) ReleaseFlagEnabled(name string) bool {
+	return c.productVariables.BuildFlags[name] == "true"
+}
+
 func (c *config) NdkAbis() bool {
 	return Bool(c.productVariables.Ndk_abis)
 }
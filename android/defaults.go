@@ -16,6 +16,8 @@ package android
 
 import (
 	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/google/blueprint"
 	"github.com/google/blueprint/proptools"
@@ -36,6 +38,9 @@ type DefaultableModuleBase struct {
 	defaultableProperties         []interface{}
 	defaultableVariableProperties interface{}
 
+	// The properties set by each applied defaults module, recorded in bp2build mode.
+	bp2buildDefaultsProvenance map[string][]string
+
 	// The optional hook to call after any defaults have been applied.
 	hook DefaultableHook
 }
@@ -71,6 +76,9 @@ type Defaultable interface {
 	// setProperties(...).
 	applyDefaults(TopDownMutatorContext, []Defaults)
 
+	// Get the properties set by each defaults module applied in bp2build mode.
+	bp2buildDefaultsProvenanceMap() map[string][]string
+
 	// Set the hook to be called after any defaults have been applied.
 	//
 	// Should be used in preference to a AddLoadHook when the behavior of the load
@@ -98,6 +106,28 @@ func InitDefaultableModule(module DefaultableModule) {
 	module.AddProperties(module.defaults())
 }
 
+// DefaultsNames returns the names of the defaults modules listed in the defaults property of the
+// module, or nil if defaults cannot be applied to the module.
+func DefaultsNames(module blueprint.Module) []string {
+	if d, ok := module.(Defaultable); ok {
+		return d.defaults().Defaults
+	}
+	return nil
+}
+
+// Bp2buildDefaultsProvenance returns the sorted names of the properties set by each defaults module
+// that was applied to the module in bp2build mode, keyed by the name of the defaults module.
+func Bp2buildDefaultsProvenance(module blueprint.Module) map[string][]string {
+	if d, ok := module.(Defaultable); ok {
+		return d.bp2buildDefaultsProvenanceMap()
+	}
+	return nil
+}
+
+func (d *DefaultableModuleBase) bp2buildDefaultsProvenanceMap() map[string][]string {
+	return d.bp2buildDefaultsProvenance
+}
+
 // A restricted subset of context methods, similar to LoadHookContext.
 type DefaultableHookContext interface {
 	EarlyModuleContext
@@ -275,6 +305,7 @@ func (defaultable *DefaultableModuleBase) applyDefaults(ctx TopDownMutatorContex
 	for _, defaults := range defaultsList {
 		if ctx.Config().runningAsBp2Build {
 			applyNamespacedVariableDefaults(defaults, ctx)
+			defaultable.recordBp2buildDefaultsProvenance(ctx, defaults)
 		}
 		for _, prop := range defaultable.defaultableProperties {
 			if prop == defaultable.defaultableVariableProperties {
@@ -286,6 +317,38 @@ func (defaultable *DefaultableModuleBase) applyDefaults(ctx TopDownMutatorContex
 	}
 }
 
+// recordBp2buildDefaultsProvenance records the properties that the given defaults module sets on
+// this module, so that the conversion can report where the flattened attributes came from.
+func (defaultable *DefaultableModuleBase) recordBp2buildDefaultsProvenance(ctx TopDownMutatorContext,
+	defaults Defaults) {
+
+	var names []string
+	for _, prop := range defaultable.defaultableProperties {
+		if prop == defaultable.defaultableVariableProperties {
+			continue
+		}
+		for _, def := range defaults.properties() {
+			if !proptools.TypeEqual(prop, def) {
+				continue
+			}
+			for _, name := range setPropertyNames("", reflect.ValueOf(def)) {
+				if !bp2buildFrameworkProperties[strings.SplitN(name, ".", 2)[0]] {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+	names = FirstUniqueStrings(names)
+	sort.Strings(names)
+	if defaultable.bp2buildDefaultsProvenance == nil {
+		defaultable.bp2buildDefaultsProvenance = make(map[string][]string)
+	}
+	defaultable.bp2buildDefaultsProvenance[ctx.OtherModuleName(defaults.(blueprint.Module))] = names
+}
+
 // Product variable properties need special handling, the type of the filtered product variable
 // property struct may not be identical between the defaults module and the defaultable module.
 // Use PrependMatchingProperties to apply whichever properties match.
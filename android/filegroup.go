@@ -15,11 +15,13 @@
 package android
 
 import (
+	"path/filepath"
 	"strings"
 
 	"android/soong/bazel"
 
 	"github.com/google/blueprint"
+	"github.com/google/blueprint/pathtools"
 )
 
 func init() {
@@ -38,10 +40,23 @@ func IsFilegroup(ctx bazel.OtherModuleContext, m blueprint.Module) bool {
 // https://docs.bazel.build/versions/master/be/general.html#filegroup
 type bazelFilegroupAttributes struct {
 	Srcs bazel.LabelListAttribute
+
+	// The base path to the srcs, relative to the package of the filegroup. The srcs keep their
+	// labels, so that they are still resolved relative to the package.
+	Path *string
+}
+
+// bazelFilegroupGlobAttributes are the attributes of a filegroup whose srcs keep their glob
+// patterns, when converting with BP2BUILD_EMIT_GLOBS=true.
+type bazelFilegroupGlobAttributes struct {
+	Srcs bazel.GlobListAttribute
+	Path *string
 }
 
 // ConvertWithBp2build performs bp2build conversion of filegroup
 func (fg *fileGroup) ConvertWithBp2build(ctx TopDownMutatorContext) {
+	ctx.MarkBp2buildPropertiesConsumed("srcs", "exclude_srcs", "path")
+
 	srcs := bazel.MakeLabelListAttribute(
 		BazelLabelForModuleSrcExcludes(ctx, fg.properties.Srcs, fg.properties.Exclude_srcs))
 
@@ -65,8 +80,17 @@ func (fg *fileGroup) ConvertWithBp2build(ctx TopDownMutatorContext) {
 		}
 	}
 
-	attrs := &bazelFilegroupAttributes{
+	var attrs interface{} = &bazelFilegroupAttributes{
 		Srcs: srcs,
+		Path: fg.bazelPath(),
+	}
+	if ctx.Config().IsEnvTrue("BP2BUILD_EMIT_GLOBS") {
+		if globSrcs, ok := fg.bazelGlobSrcs(ctx); ok {
+			attrs = &bazelFilegroupGlobAttributes{
+				Srcs: globSrcs,
+				Path: fg.bazelPath(),
+			}
+		}
 	}
 
 	props := bazel.BazelTargetModuleProperties{
@@ -77,6 +101,53 @@ func (fg *fileGroup) ConvertWithBp2build(ctx TopDownMutatorContext) {
 	ctx.CreateBazelTargetModule(props, CommonAttributes{Name: fg.Name()}, attrs)
 }
 
+// bazelGlobSrcs returns the srcs of the filegroup with the glob patterns kept as patterns, to be
+// emitted as a glob() that picks up new files without converting again. It returns false if the
+// patterns can't be kept, because they match files in subpackages, which a Bazel glob() doesn't
+// cross, or because exclude_srcs references modules.
+func (fg *fileGroup) bazelGlobSrcs(ctx TopDownMutatorContext) (bazel.GlobListAttribute, bool) {
+	var globs, others []string
+	for _, src := range fg.properties.Srcs {
+		if m, _ := SrcIsModuleWithTag(src); m == "" && pathtools.IsGlob(src) {
+			globs = append(globs, src)
+		} else {
+			others = append(others, src)
+		}
+	}
+	if len(globs) == 0 {
+		return bazel.GlobListAttribute{}, false
+	}
+	for _, exclude := range fg.properties.Exclude_srcs {
+		if m, _ := SrcIsModuleWithTag(exclude); m != "" {
+			return bazel.GlobListAttribute{}, false
+		}
+	}
+	for _, label := range BazelLabelForModuleSrcExcludes(ctx, globs, fg.properties.Exclude_srcs).Includes {
+		if strings.HasPrefix(label.Label, "//") {
+			return bazel.GlobListAttribute{}, false
+		}
+	}
+
+	return bazel.GlobListAttribute{
+		Globs:    globs,
+		Excludes: fg.properties.Exclude_srcs,
+		Labels:   BazelLabelForModuleSrcExcludes(ctx, others, fg.properties.Exclude_srcs),
+	}, true
+}
+
+// bazelPath returns the path property of the filegroup in a canonical form, or nil if the srcs
+// aren't relative to a base path.
+func (fg *fileGroup) bazelPath() *string {
+	if fg.properties.Path == nil {
+		return nil
+	}
+	path := filepath.Clean(*fg.properties.Path)
+	if path == "." {
+		return nil
+	}
+	return &path
+}
+
 type fileGroupProperties struct {
 	// srcs lists files that will be included in this filegroup
 	Srcs []string `android:"path"`
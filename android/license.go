@@ -16,6 +16,8 @@ package android
 
 import (
 	"github.com/google/blueprint"
+
+	"android/soong/bazel"
 )
 
 type licenseKindDependencyTag struct {
@@ -52,6 +54,7 @@ type licenseModule struct {
 	ModuleBase
 	DefaultableModuleBase
 	SdkBase
+	BazelModuleBase
 
 	properties licenseProperties
 }
@@ -74,6 +77,36 @@ func (m *licenseModule) GenerateAndroidBuildActions(ctx ModuleContext) {
 	}
 }
 
+type bazelLicenseAttributes struct {
+	License_kinds    []string
+	Copyright_notice *string
+	License_text     bazel.LabelAttribute
+	Package_name     *string
+}
+
+func (m *licenseModule) ConvertWithBp2build(ctx TopDownMutatorContext) {
+	// The Bazel license rule takes a single license text. Modules with several are left
+	// unconverted, and so are the package() statements referencing them.
+	if len(m.properties.License_text) > 1 {
+		return
+	}
+	attrs := &bazelLicenseAttributes{
+		License_kinds:    m.properties.License_kinds,
+		Copyright_notice: m.properties.Copyright_notice,
+		Package_name:     m.properties.Package_name,
+	}
+	if len(m.properties.License_text) == 1 {
+		attrs.License_text.SetValue(BazelLabelForModuleSrcSingle(ctx, m.properties.License_text[0]))
+	}
+
+	props := bazel.BazelTargetModuleProperties{
+		Rule_class:        "android_license",
+		Bzl_load_location: "//build/bazel/rules/license:license.bzl",
+	}
+
+	ctx.CreateBazelTargetModule(props, CommonAttributes{Name: m.Name()}, attrs)
+}
+
 func LicenseFactory() Module {
 	module := &licenseModule{}
 
@@ -86,6 +119,7 @@ func LicenseFactory() Module {
 	InitSdkAwareModule(module)
 	initAndroidModuleBase(module)
 	InitDefaultableModule(module)
+	InitBazelModule(module)
 
 	return module
 }
@@ -326,6 +326,10 @@ type BaseModuleContext interface {
 	// AddMissingBp2buildDep stores the module name of a direct dependency that was not found.
 	AddMissingBp2buildDep(dep string)
 
+	// AddCrossPackageBp2buildFile stores the label of a source file in another Bazel package that
+	// is referenced by the bp2build target of this module.
+	AddCrossPackageBp2buildFile(label string)
+
 	Target() Target
 	TargetPrimary() bool
 
@@ -527,6 +531,12 @@ type Module interface {
 	Bp2buildTargets() []bp2buildInfo
 	GetUnconvertedBp2buildDeps() []string
 	GetMissingBp2buildDeps() []string
+	// GetCrossPackageBp2buildFiles returns the labels of the source files in other Bazel packages
+	// referenced by the bp2build targets of this module.
+	GetCrossPackageBp2buildFiles() []string
+	// GetDroppedBp2buildProperties returns the properties set on this module that its bp2build
+	// converter did not consume.
+	GetDroppedBp2buildProperties() []string
 
 	BuildParamsForTests() []BuildParams
 	RuleParamsForTests() map[blueprint.Rule]blueprint.RuleParams
@@ -924,6 +934,14 @@ type commonProperties struct {
 
 	// MissingBp2buildDep stores the module names of direct dependency that were not found
 	MissingBp2buildDeps []string `blueprint:"mutated"`
+
+	// CrossPackageBp2buildFiles stores the labels of the source files in other Bazel packages
+	// referenced by the bp2build targets of this module, which those packages must export.
+	CrossPackageBp2buildFiles []string `blueprint:"mutated"`
+
+	// Bp2buildConsumedProperties stores the names of the properties read by the bp2build converter
+	// of this module.
+	Bp2buildConsumedProperties []string `blueprint:"mutated"`
 }
 
 // CommonAttributes represents the common Bazel attributes from which properties
@@ -934,6 +952,10 @@ type CommonAttributes struct {
 	Name string
 	// Data mapped from: Required
 	Data bazel.LabelListAttribute
+	// Tags mapped from: Bazel_tags
+	Tags bazel.StringListAttribute
+	// Testonly set for test modules, so that production targets can't depend on them
+	Testonly *bool
 }
 
 // constraintAttributes represents Bazel attributes pertaining to build constraints,
@@ -1171,13 +1193,6 @@ func (attrs *CommonAttributes) fillCommonBp2BuildModuleAttrs(ctx *topDownMutator
 	mod := ctx.Module().base()
 	props := &mod.commonProperties
 
-	depsToLabelList := func(deps []string) bazel.LabelListAttribute {
-		return bazel.MakeLabelListAttribute(BazelLabelForModuleDeps(ctx, deps))
-	}
-
-	data := &attrs.Data
-
-	required := depsToLabelList(props.Required)
 	archVariantProps := mod.GetArchVariantProperties(ctx, &commonProperties{})
 
 	var enabledProperty bazel.BoolAttribute
@@ -1188,7 +1203,6 @@ func (attrs *CommonAttributes) fillCommonBp2BuildModuleAttrs(ctx *topDownMutator
 	for axis, configToProps := range archVariantProps {
 		for config, _props := range configToProps {
 			if archProps, ok := _props.(*commonProperties); ok {
-				required.SetSelectValue(axis, config, depsToLabelList(archProps.Required).Value)
 				if archProps.Enabled != nil {
 					enabledProperty.SetSelectValue(axis, config, archProps.Enabled)
 				}
@@ -1229,6 +1243,26 @@ func (attrs *CommonAttributes) fillCommonBp2BuildModuleAttrs(ctx *topDownMutator
 		enabledProperty.SetSelectValue(bazel.OsConfigurationAxis, Android.Name, proptools.BoolPtr(false))
 	}
 
+	// A module that is never built for the host, either because its module type only supports the
+	// device or because it sets host_supported: false, is only compatible with the android os.
+	// Modules that are merely not built for the host by default are left alone, since Bazel may
+	// still build their targets for the host as dependencies of host modules.
+	moduleSupportsHost := mod.commonProperties.HostOrDeviceSupported&hostSupported == hostSupported
+	hostDisabled := !moduleSupportsHost || mod.hostAndDeviceProperties.Host_supported != nil
+	if mod.DeviceSupported() && !mod.HostSupported() && hostDisabled {
+		for _, os := range osTypeList {
+			if os.Class == Host {
+				enabledProperty.SetSelectValue(bazel.OsConfigurationAxis, os.Name, proptools.BoolPtr(false))
+			}
+		}
+		if proptools.BoolDefault(enabledProperty.Value, true) {
+			if enabledProperty.SelectValue(bazel.OsConfigurationAxis, Android.Name) == nil {
+				enabledProperty.SetSelectValue(bazel.OsConfigurationAxis, Android.Name, proptools.BoolPtr(true))
+			}
+			enabledProperty.Value = proptools.BoolPtr(false)
+		}
+	}
+
 	platformEnabledAttribute, err := enabledProperty.ToLabelListAttribute(
 		bazel.LabelList{[]bazel.Label{bazel.Label{Label: "@platforms//:incompatible"}}, nil},
 		bazel.LabelList{[]bazel.Label{}, nil})
@@ -1236,7 +1270,17 @@ func (attrs *CommonAttributes) fillCommonBp2BuildModuleAttrs(ctx *topDownMutator
 		ctx.ModuleErrorf("Error processing platform enabled attribute: %s", err)
 	}
 
-	data.Append(required)
+	attrs.Data.Append(bp2buildRequiredData(ctx))
+
+	if b, ok := ctx.Module().(Bazelable); ok {
+		if tags := b.bazelProps().Bazel_tags; len(tags) > 0 {
+			attrs.Tags.Append(bazel.MakeStringListAttribute(tags))
+		}
+	}
+
+	if isBp2buildTestModule(ctx) {
+		attrs.Testonly = proptools.BoolPtr(true)
+	}
 
 	constraints := constraintAttributes{}
 	moduleEnableConstraints := bazel.LabelListAttribute{}
@@ -1247,6 +1291,35 @@ func (attrs *CommonAttributes) fillCommonBp2BuildModuleAttrs(ctx *topDownMutator
 	return constraints
 }
 
+// bp2buildRequiredData returns the modules listed in the required property, including its arch
+// variants, as labels for the data attribute of the converted target, so that the target of any
+// converted module type depends on them at runtime like the module does.
+func bp2buildRequiredData(ctx *topDownMutatorContext) bazel.LabelListAttribute {
+	mod := ctx.Module().base()
+	required := bazel.MakeLabelListAttribute(BazelLabelForModuleDeps(ctx, mod.commonProperties.Required))
+	for axis, configToProps := range mod.GetArchVariantProperties(ctx, &commonProperties{}) {
+		for config, _props := range configToProps {
+			if archProps, ok := _props.(*commonProperties); ok {
+				required.SetSelectValue(axis, config, BazelLabelForModuleDeps(ctx, archProps.Required))
+			}
+		}
+	}
+	return required
+}
+
+// isBp2buildTestModule returns true if the module is a test, either because its module type is a
+// test module type or because it is in test suites.
+func isBp2buildTestModule(ctx *topDownMutatorContext) bool {
+	moduleType := ctx.ModuleType()
+	if strings.HasSuffix(moduleType, "_test") || strings.HasSuffix(moduleType, "_test_host") {
+		return true
+	}
+	if tsm, ok := ctx.Module().(TestSuiteModule); ok && len(tsm.TestSuites()) > 0 {
+		return true
+	}
+	return false
+}
+
 // Check product variables for `enabled: true` flag override.
 // Returns a list of the constraint_value targets who enable this override.
 func productVariableConfigEnableLabels(ctx *topDownMutatorContext) []bazel.Label {
@@ -1451,6 +1524,13 @@ func (b *baseModuleContext) AddMissingBp2buildDep(dep string) {
 	*missingDeps = append(*missingDeps, dep)
 }
 
+// AddCrossPackageBp2buildFile stores the label of a source file in another Bazel package that is
+// referenced by the bp2build target of this module.
+func (b *baseModuleContext) AddCrossPackageBp2buildFile(label string) {
+	files := &b.Module().base().commonProperties.CrossPackageBp2buildFiles
+	*files = append(*files, label)
+}
+
 // GetUnconvertedBp2buildDeps returns the list of module names of this module's direct dependencies that
 // were not converted to Bazel.
 func (m *ModuleBase) GetUnconvertedBp2buildDeps() []string {
@@ -1462,6 +1542,12 @@ func (m *ModuleBase) GetMissingBp2buildDeps() []string {
 	return FirstUniqueStrings(m.commonProperties.MissingBp2buildDeps)
 }
 
+// GetCrossPackageBp2buildFiles returns the labels of the source files in other Bazel packages that
+// are referenced by the bp2build targets of this module.
+func (m *ModuleBase) GetCrossPackageBp2buildFiles() []string {
+	return FirstUniqueStrings(m.commonProperties.CrossPackageBp2buildFiles)
+}
+
 func (m *ModuleBase) AddJSONData(d *map[string]interface{}) {
 	(*d)["Android"] = map[string]interface{}{
 		// Properties set in Blueprint or in blueprint of a defaults modules
@@ -1581,6 +1667,21 @@ func (m *ModuleBase) GetProperties() []interface{} {
 	return m.registerProps
 }
 
+// EffectiveProperties returns the property structs of the module, which hold the values merged
+// from its defaults, arch variants and product variables once the mutators have run. The structs
+// holding the unmerged arch and product variable specific values are omitted.
+func EffectiveProperties(m Module) []interface{} {
+	base := m.base()
+	var props []interface{}
+	for _, p := range m.GetProperties() {
+		if _, ok := p.(*archPropRoot); ok || p == base.variableProperties {
+			continue
+		}
+		props = append(props, p)
+	}
+	return props
+}
+
 func (m *ModuleBase) BuildParamsForTests() []BuildParams {
 	// Expand the references to module variables like $flags[0-9]*,
 	// so we do not need to change many existing unit tests.
@@ -262,6 +262,10 @@ type TopDownMutatorContext interface {
 	// platforms, as dictated by a given bool attribute: the target will not be buildable in
 	// any platform for which this bool attribute is false.
 	CreateBazelTargetModuleWithRestrictions(bazel.BazelTargetModuleProperties, CommonAttributes, interface{}, bazel.BoolAttribute)
+
+	// MarkBp2buildPropertiesConsumed records that the bp2build converter of this module read the
+	// given properties. Set properties that were not consumed are reported as dropped.
+	MarkBp2buildPropertiesConsumed(names ...string)
 }
 
 type topDownMutatorContext struct {
@@ -528,6 +532,14 @@ func (t *topDownMutatorContext) createBazelTargetModule(
 	enabledProperty bazel.BoolAttribute) {
 	constraintAttributes := commonAttrs.fillCommonBp2BuildModuleAttrs(t, enabledProperty)
 	mod := t.Module()
+	if b, ok := mod.(Bazelable); ok {
+		if override := b.bazelProps().Bazel_rule_override; override.Rule_class != nil {
+			bazelProps.Rule_class = *override.Rule_class
+			bazelProps.Bzl_load_location = String(override.Bzl_load_location)
+		} else if override.Bzl_load_location != nil {
+			bazelProps.Bzl_load_location = *override.Bzl_load_location
+		}
+	}
 	info := bp2buildInfo{
 		Dir:             t.OtherModuleDir(mod),
 		BazelProps:      bazelProps,
@@ -56,6 +56,15 @@ func (p *packageModule) qualifiedModuleId(ctx BaseModuleContext) qualifiedModule
 	return newPackageId(ctx.ModuleDir())
 }
 
+// PackageDefaultApplicableLicenses returns the default_applicable_licenses of the given module if
+// it is a package module, and whether it is one.
+func PackageDefaultApplicableLicenses(module blueprint.Module) ([]string, bool) {
+	if p, ok := module.(*packageModule); ok {
+		return p.properties.Default_applicable_licenses, true
+	}
+	return nil, false
+}
+
 func PackageFactory() Module {
 	module := &packageModule{}
 
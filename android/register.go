@@ -167,6 +167,17 @@ func (ctx *Context) SetRunningAsBp2build() {
 	ctx.config.runningAsBp2Build = true
 }
 
+// RegisterBp2BuildModuleDenylist registers module names that bp2build must never
+// convert, regardless of the Bp2BuildConfig of the directories they are in.
+func (ctx *Context) RegisterBp2BuildModuleDenylist(moduleNames []string) {
+	if ctx.config.bp2buildModuleDenylist == nil {
+		ctx.config.bp2buildModuleDenylist = make(map[string]bool)
+	}
+	for _, moduleName := range moduleNames {
+		ctx.config.bp2buildModuleDenylist[moduleName] = true
+	}
+}
+
 // RegisterForBazelConversion registers an alternate shadow pipeline of
 // singletons, module types and mutators to register for converting Blueprint
 // files to semantically equivalent BUILD files.
@@ -276,6 +276,9 @@ type productVariables struct {
 	Arc                          *bool    `json:",omitempty"`
 	MinimizeJavaDebugInfo        *bool    `json:",omitempty"`
 
+	// Extra JVM arguments passed to the javac, turbine, d8 and r8 JVMs.
+	JavaToolJvmArgs []string `json:",omitempty"`
+
 	Check_elf_files *bool `json:",omitempty"`
 
 	UncompressPrivAppDex             *bool    `json:",omitempty"`
@@ -374,6 +377,9 @@ type productVariables struct {
 
 	VendorVars map[string]map[string]string `json:",omitempty"`
 
+	// The values of the release flags, keyed by flag name, e.g. "RELEASE_FOO": "true".
+	BuildFlags map[string]string `json:",omitempty"`
+
 	Ndk_abis *bool `json:",omitempty"`
 
 	Flatten_apex                 *bool `json:",omitempty"`
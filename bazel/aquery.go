@@ -329,9 +329,10 @@ func AqueryBuildStatements(aqueryJsonProto []byte) ([]BuildStatement, error) {
 			if !pyBinaryFound {
 				return nil, fmt.Errorf("Could not find the correspondinging Python binary stub script of PythonZipper: %q", outputPaths)
 			}
-		} else if len(actionEntry.Arguments) < 1 {
-			return nil, fmt.Errorf("received action with no command: [%v]", buildStatement)
 		}
+		// Actions without arguments that aren't handled above, e.g. actions of types introduced by a
+		// newer Bazel version, are returned with an empty command. They are skipped with a warning
+		// when the build statements are registered, rather than failing the build.
 		buildStatements = append(buildStatements, buildStatement)
 	}
 
@@ -1095,6 +1095,42 @@ func TestTemplateExpandActionNoOutput(t *testing.T) {
 	assertError(t, err, `Expect 1 output to template expand action, got: output []`)
 }
 
+func TestUnknownActionWithoutArguments(t *testing.T) {
+	const inputString = `
+{
+  "artifacts": [{
+    "id": 1,
+    "pathFragmentId": 1
+  }],
+  "actions": [{
+    "targetId": 1,
+    "actionKey": "x",
+    "mnemonic": "NewActionType",
+    "configurationId": 1,
+    "outputIds": [1],
+    "primaryOutputId": 1,
+    "executionPlatform": "//build/bazel/platforms:linux_x86_64"
+  }],
+  "pathFragments": [{
+    "id": 1,
+    "label": "new_output"
+  }]
+}`
+
+	actual, err := AqueryBuildStatements([]byte(inputString))
+	if err != nil {
+		t.Fatalf("Unexpected error %q", err)
+	}
+	expected := []BuildStatement{
+		BuildStatement{
+			Command:     "",
+			OutputPaths: []string{"new_output"},
+			Mnemonic:    "NewActionType",
+		},
+	}
+	assertBuildStatements(t, expected, actual)
+}
+
 func TestPythonZipperActionSuccess(t *testing.T) {
 	const inputString = `
 {
@@ -19,6 +19,12 @@ const (
 	// Run bazel as a ninja executer
 	BazelNinjaExecRunName = RunName("bazel-ninja-exec")
 
+	// Issue a cheap command to start the bazel server before it is needed.
+	WarmUpRunName = RunName("bazel-warmup")
+
+	// Query the Bazel release to check which features it supports.
+	VersionRunName = RunName("bazel-version")
+
 	SoongInjectionDirName = "soong_injection"
 
 	GeneratedBazelFileWarning = "# GENERATED FOR BAZEL FROM SOONG. DO NOT EDIT"
@@ -17,6 +17,7 @@ package bazel
 import (
 	"fmt"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"sort"
 	"strings"
@@ -588,6 +589,25 @@ func (ll labelListSelectValues) HasConfigurableValues() bool {
 	return false
 }
 
+// GlobListAttribute represents a list of source files, some of which are given as glob patterns,
+// as a Bazel glob() of the patterns followed by the labels of the other files. Unlike a label list
+// with the expanded patterns, files matching the patterns are picked up without converting again.
+type GlobListAttribute struct {
+	// The glob patterns, relative to the package.
+	Globs []string
+
+	// The glob patterns and files excluded from the files matched by Globs.
+	Excludes []string
+
+	// The labels of the source files that aren't given as glob patterns.
+	Labels LabelList
+}
+
+// HasConfigurableValues returns false, as a GlobListAttribute is never configurable.
+func (ga GlobListAttribute) HasConfigurableValues() bool {
+	return false
+}
+
 // LabelListAttribute is used to represent a list of Bazel labels as an
 // attribute.
 type LabelListAttribute struct {
@@ -1094,6 +1114,81 @@ func (sla *StringListAttribute) DeduplicateAxesFromBase() {
 	}
 }
 
+// RemoveValues removes the strings in removals from this StringListAttribute. Base removals are
+// removed from the base and all configuration-specific values. Configuration-specific removals of
+// base values move those values from the base into every other configuration of the axis,
+// including the default one, and leave an explicit, possibly empty, value for the configurations
+// that removed them.
+func (sla *StringListAttribute) RemoveValues(removals StringListAttribute) {
+	sla.Value = SubtractStrings(sla.Value, removals.Value)
+	for axis, configToList := range sla.ConfigurableValues {
+		for config, list := range configToList {
+			sla.ConfigurableValues[axis][config] = SubtractStrings(list, removals.Value)
+		}
+	}
+
+	for _, axis := range removals.SortedConfigurationAxes() {
+		configToRemovals := map[string][]string{}
+		removedForAxis := map[string]bool{}
+		for config, r := range removals.ConfigurableValues[axis] {
+			if len(r) > 0 {
+				configToRemovals[config] = r
+				for _, v := range r {
+					removedForAxis[v] = true
+				}
+			}
+		}
+		if len(configToRemovals) == 0 {
+			continue
+		}
+
+		var moved []string
+		for _, v := range sla.Value {
+			if removedForAxis[v] {
+				moved = append(moved, v)
+			}
+		}
+		if len(moved) > 0 {
+			sla.Value = SubtractStrings(sla.Value, moved)
+			for config := range configToRemovals {
+				if sla.SelectValue(axis, config) == nil {
+					sla.SetSelectValue(axis, config, []string{})
+				}
+			}
+			if sla.SelectValue(axis, ConditionsDefaultConfigKey) == nil {
+				sla.SetSelectValue(axis, ConditionsDefaultConfigKey, []string{})
+			}
+			// Base values come before configuration-specific values.
+			for config, list := range sla.ConfigurableValues[axis] {
+				sla.ConfigurableValues[axis][config] = append(append([]string{}, moved...), list...)
+			}
+		}
+
+		for config, r := range configToRemovals {
+			if list := sla.SelectValue(axis, config); list != nil {
+				remaining := SubtractStrings(list, r)
+				if remaining == nil && len(moved) > 0 {
+					// Keep an explicit empty value so that the configuration doesn't fall back to
+					// the default one, which contains the moved values.
+					remaining = []string{}
+				}
+				sla.SetSelectValue(axis, config, remaining)
+			}
+		}
+
+		if len(moved) > 0 {
+			// Omit the configurations that only contain the moved values, for brevity.
+			configToList := sla.ConfigurableValues[axis]
+			defaultList := configToList[ConditionsDefaultConfigKey]
+			for config, list := range configToList {
+				if config != ConditionsDefaultConfigKey && reflect.DeepEqual(list, defaultList) {
+					delete(configToList, config)
+				}
+			}
+		}
+	}
+}
+
 // TryVariableSubstitution, replace string substitution formatting within each string in slice with
 // Starlark string.format compatible tag for productVariable.
 func TryVariableSubstitutions(slice []string, productVariable string) ([]string, bool) {
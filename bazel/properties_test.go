@@ -596,3 +596,39 @@ func TestDeduplicateAxesFromBase(t *testing.T) {
 		}
 	}
 }
+
+func TestRemoveValues(t *testing.T) {
+	attr := StringListAttribute{
+		Value: []string{"all", "not_for_arm", "not_anywhere"},
+		ConfigurableValues: configurableStringLists{
+			ArchConfigurationAxis: stringListSelectValues{
+				"arm":    []string{"arm"},
+				"arm64":  nil,
+				"x86":    []string{"x86"},
+				"x86_64": nil,
+			},
+		},
+	}
+	removals := StringListAttribute{
+		Value: []string{"not_anywhere"},
+		ConfigurableValues: configurableStringLists{
+			ArchConfigurationAxis: stringListSelectValues{
+				"arm": []string{"not_for_arm"},
+			},
+		},
+	}
+
+	attr.RemoveValues(removals)
+
+	if expected := []string{"all"}; !reflect.DeepEqual(expected, attr.Value) {
+		t.Errorf("Expected Value %q, got %q", expected, attr.Value)
+	}
+	expected := stringListSelectValues{
+		"arm":                      []string{"arm"},
+		"x86":                      []string{"not_for_arm", "x86"},
+		ConditionsDefaultConfigKey: []string{"not_for_arm"},
+	}
+	if got := attr.ConfigurableValues[ArchConfigurationAxis]; !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected arch values %q, got %q", expected, got)
+	}
+}
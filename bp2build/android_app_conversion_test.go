@@ -48,9 +48,10 @@ android_app {
 `,
 		expectedBazelTargets: []string{
 			makeBazelTarget("android_binary", "TestApp", attrNameToString{
-				"srcs":           `["app.java"]`,
-				"manifest":       `"AndroidManifest.xml"`,
-				"resource_files": `["res/res.png"]`,
+				"srcs":                   `["app.java"]`,
+				"manifest":               `"AndroidManifest.xml"`,
+				"resource_files":         `["res/res.png"]`,
+				"target_compatible_with": androidOnlyTargetCompatibleWith,
 			}),
 		}})
 }
@@ -85,8 +86,9 @@ android_app {
         "resa/res.png",
         "resb/res.png",
     ]`,
-				"custom_package": `"com.google"`,
-				"deps":           `[":static_lib_dep"]`,
+				"custom_package":         `"com.google"`,
+				"deps":                   `[":static_lib_dep"]`,
+				"target_compatible_with": androidOnlyTargetCompatibleWith,
 			}),
 		}})
 }
@@ -123,8 +125,95 @@ android_app {
         "//build/bazel/platforms/arch:x86": ["x86.java"],
         "//conditions:default": [],
     })`,
-				"manifest":       `"AndroidManifest.xml"`,
-				"resource_files": `["res/res.png"]`,
+				"manifest":               `"AndroidManifest.xml"`,
+				"resource_files":         `["res/res.png"]`,
+				"target_compatible_with": androidOnlyTargetCompatibleWith,
 			}),
 		}})
 }
+
+func TestAndroidAppCertificateModuleReference(t *testing.T) {
+	runBp2BuildTestCase(t, func(ctx android.RegistrationContext) {
+		ctx.RegisterModuleType("android_app_certificate", java.AndroidAppCertificateFactory)
+	}, bp2buildTestCase{
+		description:                "Android app - certificate module reference",
+		moduleTypeUnderTest:        "android_app",
+		moduleTypeUnderTestFactory: java.AndroidAppFactory,
+		filesystem: map[string]string{
+			"app.java":            "",
+			"res/res.png":         "",
+			"AndroidManifest.xml": "",
+		},
+		blueprint: `
+android_app_certificate {
+        name: "com.android.test.cert",
+        certificate: "test_cert",
+}
+
+android_app {
+        name: "TestApp",
+        srcs: ["app.java"],
+        sdk_version: "current",
+        certificate: ":com.android.test.cert",
+}
+`,
+		expectedBazelTargets: []string{
+			makeBazelTarget("android_app_certificate", "com.android.test.cert", attrNameToString{
+				"certificate": `"test_cert"`,
+			}),
+			makeBazelTarget("android_binary", "TestApp", attrNameToString{
+				"srcs":                   `["app.java"]`,
+				"manifest":               `"AndroidManifest.xml"`,
+				"resource_files":         `["res/res.png"]`,
+				"certificate":            `":com.android.test.cert"`,
+				"target_compatible_with": androidOnlyTargetCompatibleWith,
+			}),
+		}})
+}
+
+func TestAndroidAppCertificateName(t *testing.T) {
+	for _, tc := range []struct {
+		certificate string
+		attrs       attrNameToString
+	}{
+		{
+			certificate: `certificate: "platform",`,
+			attrs:       attrNameToString{"certificate_name": `"platform"`},
+		},
+		{
+			// The default certificate of the product is used when the certificate is empty.
+			certificate: `certificate: "",`,
+			attrs:       attrNameToString{},
+		},
+	} {
+		attrs := attrNameToString{
+			"srcs":                   `["app.java"]`,
+			"manifest":               `"AndroidManifest.xml"`,
+			"resource_files":         `["res/res.png"]`,
+			"target_compatible_with": androidOnlyTargetCompatibleWith,
+		}
+		for name, value := range tc.attrs {
+			attrs[name] = value
+		}
+		runAndroidAppTestCase(t, bp2buildTestCase{
+			description:                "Android app - " + tc.certificate,
+			moduleTypeUnderTest:        "android_app",
+			moduleTypeUnderTestFactory: java.AndroidAppFactory,
+			filesystem: map[string]string{
+				"app.java":            "",
+				"res/res.png":         "",
+				"AndroidManifest.xml": "",
+			},
+			blueprint: `
+android_app {
+        name: "TestApp",
+        srcs: ["app.java"],
+        sdk_version: "current",
+        ` + tc.certificate + `
+}
+`,
+			expectedBazelTargets: []string{
+				makeBazelTarget("android_binary", "TestApp", attrs),
+			}})
+	}
+}
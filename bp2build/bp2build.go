@@ -16,7 +16,9 @@ package bp2build
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"android/soong/android"
@@ -26,7 +28,7 @@ import (
 // Codegen is the backend of bp2build. The code generator is responsible for
 // writing .bzl files that are equivalent to Android.bp files that are capable
 // of being built with Bazel.
-func Codegen(ctx *CodegenContext) CodegenMetrics {
+func Codegen(ctx *CodegenContext) (CodegenMetrics, error) {
 	// This directory stores BUILD files that could be eventually checked-in.
 	bp2buildDir := android.PathForOutput(ctx, "bp2build")
 	android.RemoveAllOutputDir(bp2buildDir)
@@ -43,10 +45,19 @@ func Codegen(ctx *CodegenContext) CodegenMetrics {
 	bp2buildFiles := CreateBazelFiles(nil, res.buildFileToTargets, ctx.mode)
 	writeFiles(ctx, bp2buildDir, bp2buildFiles)
 
+	if ctx.outputDir != "" {
+		if err := os.RemoveAll(ctx.outputDir); err != nil {
+			return res.metrics, fmt.Errorf("failed to clear %q: %s", ctx.outputDir, err)
+		}
+		if err := writeFilesToDir(ctx.outputDir, bp2buildFiles); err != nil {
+			return res.metrics, fmt.Errorf("failed to write BUILD files to %q: %s", ctx.outputDir, err)
+		}
+	}
+
 	soongInjectionDir := android.PathForOutput(ctx, bazel.SoongInjectionDirName)
 	writeFiles(ctx, soongInjectionDir, CreateSoongInjectionFiles(ctx.Config(), res.metrics))
 
-	return res.metrics
+	return res.metrics, nil
 }
 
 // Get the output directory and create it if it doesn't exist.
@@ -71,3 +82,18 @@ func writeFile(ctx android.PathContext, pathToFile android.OutputPath, content s
 	// in the source tree.
 	return android.WriteFileToOutputDir(pathToFile, []byte(content), 0644)
 }
+
+// writeFilesToDir materializes a list of BazelFile rooted at dir, which needn't be a Soong output
+// path.
+func writeFilesToDir(dir string, files []BazelFile) error {
+	for _, f := range files {
+		fileDir := filepath.Join(dir, f.Dir)
+		if err := os.MkdirAll(fileDir, os.ModePerm); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(fileDir, f.Basename), []byte(f.Contents), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bp2build
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestWriteFilesToOutputDir(t *testing.T) {
+	fs := map[string][]byte{
+		"a/Android.bp":   []byte(`filegroup { name: "a" }`),
+		"a/b/Android.bp": []byte(`filegroup { name: "b" }`),
+	}
+	config := android.TestConfig(buildDir, nil, "", fs)
+	ctx := android.NewTestContext(config)
+	ctx.RegisterModuleType("filegroup", android.FileGroupFactory)
+	ctx.RegisterBp2BuildConfig(android.Bp2BuildConfig{
+		"a": android.Bp2BuildDefaultTrueRecursively,
+	})
+	ctx.RegisterForBazelConversion()
+
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp", "a/Android.bp", "a/b/Android.bp"})
+	android.FailIfErrored(t, errs)
+	_, errs = ctx.ResolveDependencies(config)
+	android.FailIfErrored(t, errs)
+
+	codegenCtx := NewCodegenContext(config, *ctx.Context, Bp2Build)
+	res, err := GenerateBazelTargets(codegenCtx, false)
+	android.FailIfErrored(t, err)
+
+	outputDir := filepath.Join(t.TempDir(), "mirror")
+	files := CreateBazelFiles(nil, res.buildFileToTargets, Bp2Build)
+	if err := writeFilesToDir(outputDir, files); err != nil {
+		t.Fatalf("Unexpected error writing files: %s", err)
+	}
+
+	for pkg, name := range map[string]string{"a": "a", "a/b": "b"} {
+		contents, err := ioutil.ReadFile(filepath.Join(outputDir, pkg, GeneratedBuildFileName))
+		if err != nil {
+			t.Fatalf("Expected a BUILD file for package %q: %s", pkg, err)
+		}
+		android.AssertStringDoesContain(t, pkg+" BUILD file", string(contents), `name = "`+name+`"`)
+	}
+}
+
+func TestCheckOutputDir(t *testing.T) {
+	testCases := []struct {
+		dir           string
+		expectedError string
+	}{
+		{dir: "/src/out/mirror"},
+		{dir: "/src/out/soong/mirror"},
+		{dir: "/src", expectedError: `must not contain the source tree`},
+		{dir: "/", expectedError: `must not contain the source tree`},
+		{dir: "/src/out", expectedError: `must be a subdirectory of the out directory`},
+		{dir: "/src/mirror", expectedError: `must be a subdirectory of the out directory`},
+		{dir: "/src/out/../mirror", expectedError: `must be a subdirectory of the out directory`},
+		{dir: "/other", expectedError: `must be a subdirectory of the out directory`},
+	}
+	for _, tc := range testCases {
+		err := checkOutputDir(tc.dir, "/src", "/src/out")
+		if tc.expectedError == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %s", tc.dir, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tc.expectedError) {
+			t.Errorf("%s: expected error containing %q, got %v", tc.dir, tc.expectedError, err)
+		}
+	}
+}
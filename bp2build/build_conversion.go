@@ -21,6 +21,7 @@ for queryview/bp2build
 
 import (
 	"fmt"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
@@ -44,6 +45,17 @@ type BazelTarget struct {
 	ruleClass       string
 	bzlLoadLocation string
 	handcrafted     bool
+
+	// The rendered attributes of a generated target, keyed by attribute name.
+	attrs map[string]string
+	// The defaults modules applied to the Soong module the target was generated from.
+	defaults []string
+	// Whether the target is a dict of attributes shared by the targets using a defaults module,
+	// rather than a rule instantiation.
+	sharedAttributes bool
+	// Whether the target is the package() statement of the BUILD file, rather than a rule
+	// instantiation.
+	packageStatement bool
 }
 
 // IsLoadedFromStarlark determines if the BazelTarget's rule class is loaded from a .bzl file,
@@ -83,6 +95,14 @@ func (targets BazelTargets) sort() {
 			// Handcrafted targets will be generated after the bp2build generated targets.
 			return targets[j].handcrafted
 		}
+		if targets[i].packageStatement != targets[j].packageStatement {
+			// Bazel requires package() to precede all rules in the BUILD file.
+			return targets[i].packageStatement
+		}
+		if targets[i].sharedAttributes != targets[j].sharedAttributes {
+			// Shared attributes must be defined before the targets referencing them.
+			return targets[i].sharedAttributes
+		}
 		// This will cover all bp2build generated targets.
 		return targets[i].name < targets[j].name
 	})
@@ -159,12 +179,58 @@ type CodegenContext struct {
 	mode               CodegenMode
 	additionalDeps     []string
 	unconvertedDepMode unconvertedDepsMode
+	// Whether attributes shared by the targets using a defaults module are emitted once, as a dict
+	// named after the defaults module, rather than inlined into every target.
+	shareDefaultsAttributes bool
+	// If set, the generated BUILD files are also written to this directory, mirroring the package
+	// structure, for evaluating the conversion without touching the bazel workspace.
+	outputDir string
 }
 
 func (c *CodegenContext) Mode() CodegenMode {
 	return c.mode
 }
 
+// SetOutputDir sets the directory the generated BUILD files are additionally written to. As the
+// directory is cleared before writing them, it must be a subdirectory of outDir that doesn't
+// contain topDir.
+func (c *CodegenContext) SetOutputDir(dir, topDir, outDir string) error {
+	if err := checkOutputDir(dir, topDir, outDir); err != nil {
+		return err
+	}
+	c.outputDir = dir
+	return nil
+}
+
+// checkOutputDir returns an error if dir is not a subdirectory of outDir, or if it contains topDir.
+func checkOutputDir(dir, topDir, outDir string) error {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	absTopDir, err := filepath.Abs(topDir)
+	if err != nil {
+		return err
+	}
+	absOutDir, err := filepath.Abs(outDir)
+	if err != nil {
+		return err
+	}
+	if isSameOrSubdir(absTopDir, absDir) {
+		return fmt.Errorf("bp2build output directory %q must not contain the source tree %q", dir, topDir)
+	}
+	if absDir == absOutDir || !isSameOrSubdir(absDir, absOutDir) {
+		return fmt.Errorf("bp2build output directory %q must be a subdirectory of the out directory %q", dir, outDir)
+	}
+	return nil
+}
+
+// isSameOrSubdir returns true if the absolute path dir is parent or one of its subdirectories.
+func isSameOrSubdir(dir, parent string) bool {
+	rel, err := filepath.Rel(parent, dir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
 // CodegenMode is an enum to differentiate code-generation modes.
 type CodegenMode int
 
@@ -228,10 +294,11 @@ func NewCodegenContext(config android.Config, context android.Context, mode Code
 		unconvertedDeps = errorModulesUnconvertedDeps
 	}
 	return &CodegenContext{
-		context:            context,
-		config:             config,
-		mode:               mode,
-		unconvertedDepMode: unconvertedDeps,
+		context:                 context,
+		config:                  config,
+		mode:                    mode,
+		unconvertedDepMode:      unconvertedDeps,
+		shareDefaultsAttributes: config.IsEnvTrue("BP2BUILD_SHARE_DEFAULTS_ATTRIBUTES"),
 	}
 }
 
@@ -261,11 +328,18 @@ func GenerateBazelTargets(ctx *CodegenContext, generateFilegroups bool) (convers
 	// Simple metrics tracking for bp2build
 	metrics := CodegenMetrics{
 		ruleClassCount:           make(map[string]uint64),
+		defaultsProvenance:       make(map[string]map[string][]string),
 		convertedModuleTypeCount: make(map[string]uint64),
 		totalModuleTypeCount:     make(map[string]uint64),
 	}
 
 	dirs := make(map[string]bool)
+	dirToDefaults := make(map[string][]string)
+	dirToPackageLicenses := make(map[string][]string)
+	// The directories of the license modules converted to Bazel targets, by module name.
+	licenseDirs := make(map[string]string)
+	// The source files referenced from other packages, which their package must export.
+	dirToExportedFiles := make(map[string][]string)
 
 	var errs []error
 
@@ -275,6 +349,13 @@ func GenerateBazelTargets(ctx *CodegenContext, generateFilegroups bool) (convers
 		moduleType := bpCtx.ModuleType(m)
 		dirs[dir] = true
 
+		if sharedAttributesDefaultsModuleTypes[moduleType] {
+			dirToDefaults[dir] = append(dirToDefaults[dir], bpCtx.ModuleName(m))
+		}
+		if licenses, ok := android.PackageDefaultApplicableLicenses(m); ok && len(licenses) > 0 {
+			dirToPackageLicenses[dir] = licenses
+		}
+
 		var targets []BazelTarget
 
 		switch ctx.Mode() {
@@ -337,12 +418,36 @@ func GenerateBazelTargets(ctx *CodegenContext, generateFilegroups bool) (convers
 						return
 					}
 				}
+				// Properties the converter did not consume are not reflected in the generated
+				// targets, so always warn about them.
+				if dropped := aModule.GetDroppedBp2buildProperties(); len(dropped) > 0 {
+					msg := fmt.Sprintf("%q sets properties that were not converted: %s", m.Name(), strings.Join(dropped, ", "))
+					metrics.moduleWithDroppedPropertiesMsgs = append(metrics.moduleWithDroppedPropertiesMsgs, msg)
+				}
+				if provenance := android.Bp2buildDefaultsProvenance(aModule); len(provenance) > 0 {
+					metrics.AddDefaultsProvenance(m.Name(), provenance)
+				}
+				for _, label := range aModule.GetCrossPackageBp2buildFiles() {
+					fileDir, file := splitFileLabel(label)
+					dirToExportedFiles[fileDir] = append(dirToExportedFiles[fileDir], file)
+				}
 				targets = generateBazelTargets(bpCtx, aModule)
+				if moduleType == "license" && len(targets) > 0 {
+					licenseDirs[bpCtx.ModuleName(m)] = dir
+				}
 				for _, t := range targets {
 					// A module can potentially generate more than 1 Bazel
 					// target, each of a different rule class.
 					metrics.IncrementRuleClassCount(t.ruleClass)
 				}
+				if ctx.shareDefaultsAttributes {
+					for i := range targets {
+						targets[i].defaults = android.DefaultsNames(aModule)
+					}
+				}
+				if alias, ok := generateAliasForRenamedModule(bpCtx.ModuleName(m), dir, targets); ok {
+					targets = append(targets, alias)
+				}
 			} else {
 				metrics.AddUnconvertedModule(moduleType)
 				return
@@ -368,6 +473,31 @@ func GenerateBazelTargets(ctx *CodegenContext, generateFilegroups bool) (convers
 		return conversionResults{}, errs
 	}
 
+	if ctx.shareDefaultsAttributes {
+		for dir, defaults := range dirToDefaults {
+			buildFileToTargets[dir] = shareDefaultsAttributes(buildFileToTargets[dir], android.FirstUniqueStrings(defaults))
+		}
+	}
+
+	if ctx.Mode() == Bp2Build {
+		for dir, licenses := range dirToPackageLicenses {
+			// A handcrafted BUILD file may declare its own package(), which Bazel only allows once.
+			if buildFileToTargets[dir].hasHandcraftedTargets() {
+				continue
+			}
+			if t, ok := generatePackageTarget(dir, licenses, licenseDirs); ok {
+				buildFileToTargets[dir] = append(BazelTargets{t}, buildFileToTargets[dir]...)
+			}
+		}
+		for dir, files := range dirToExportedFiles {
+			// A handcrafted BUILD file is responsible for exporting its own files.
+			if buildFileToTargets[dir].hasHandcraftedTargets() {
+				continue
+			}
+			buildFileToTargets[dir] = append(buildFileToTargets[dir], generateExportsFilesTarget(files))
+		}
+	}
+
 	if generateFilegroups {
 		// Add a filegroup target that exposes all sources in the subtree of this package
 		// NOTE: This also means we generate a BUILD file for every Android.bp file (as long as it has at least one module)
@@ -386,6 +516,75 @@ func GenerateBazelTargets(ctx *CodegenContext, generateFilegroups bool) (convers
 	}, errs
 }
 
+// generatePackageTarget returns the package() statement setting the default_applicable_licenses
+// of the package in dir, with the license module references rewritten to Bazel labels. It returns
+// false if some of the license modules weren't converted, as Bazel would fail to load the package.
+func generatePackageTarget(dir string, licenses []string, licenseDirs map[string]string) (BazelTarget, bool) {
+	var labels []string
+	for _, license := range licenses {
+		label, ok := licenseLabel(dir, license, licenseDirs)
+		if !ok {
+			return BazelTarget{}, false
+		}
+		labels = append(labels, label)
+	}
+	return BazelTarget{
+		name: "package",
+		content: fmt.Sprintf("package(default_applicable_licenses = %s)",
+			starlark_fmt.PrintStringList(labels, 0)),
+		packageStatement: true,
+	}, true
+}
+
+// generateExportsFilesTarget returns the exports_files() statement making the given files of a
+// package visible to the targets of other packages.
+func generateExportsFilesTarget(files []string) BazelTarget {
+	files = android.SortedUniqueStrings(files)
+	return BazelTarget{
+		name:    "exports_files",
+		content: fmt.Sprintf("exports_files(%s)", starlark_fmt.PrintStringList(files, 0)),
+	}
+}
+
+// splitFileLabel returns the package directory and the package-relative path of the file with the
+// given absolute label, e.g. "foo/bar" and "baz/qux.txt" for "//foo/bar:baz/qux.txt".
+func splitFileLabel(label string) (string, string) {
+	label = strings.TrimPrefix(label, "//")
+	i := strings.Index(label, ":")
+	dir, file := label[:i], label[i+1:]
+	if dir == "" {
+		dir = "."
+	}
+	return dir, file
+}
+
+// licenseLabel returns the Bazel label of the license module referenced from the package in dir,
+// and false if the license module wasn't converted.
+func licenseLabel(dir, license string, licenseDirs map[string]string) (string, bool) {
+	name, referencedDir := license, ""
+	if i := strings.LastIndex(license, ":"); i >= 0 {
+		// A fully qualified or package-relative reference.
+		name, referencedDir = license[i+1:], strings.TrimPrefix(license[:i], "//")
+		if referencedDir == "" {
+			referencedDir = "."
+		}
+		if !strings.HasPrefix(license, "//") {
+			referencedDir = dir
+		}
+	}
+	licenseDir, ok := licenseDirs[name]
+	if !ok || (referencedDir != "" && referencedDir != licenseDir) {
+		return "", false
+	}
+	if licenseDir == dir {
+		return ":" + name, true
+	}
+	if licenseDir == "." {
+		return "//:" + name, true
+	}
+	return "//" + licenseDir + ":" + name, true
+}
+
 func getBazelPackagePath(b android.Bazelable) string {
 	label := b.HandcraftedLabel()
 	pathToBuildFile := strings.TrimPrefix(label, "//")
@@ -452,7 +651,36 @@ func generateBazelTarget(ctx bpToBuildContext, m bp2buildModule) BazelTarget {
 			attributes,
 		),
 		handcrafted: false,
+		attrs:       props.Attrs,
+	}
+}
+
+// generateAliasForRenamedModule returns an alias from the name of a module to
+// the single target generated for it, if that target was given a different
+// name, so that references to the module by its original name still resolve.
+func generateAliasForRenamedModule(moduleName, dir string, targets []BazelTarget) (BazelTarget, bool) {
+	if len(targets) != 1 {
+		return BazelTarget{}, false
+	}
+	target := targets[0]
+	if target.name == moduleName && target.packageName == dir {
+		return BazelTarget{}, false
+	}
+	actual := target.Label()
+	if target.packageName == dir {
+		actual = ":" + target.name
 	}
+	return BazelTarget{
+		name:        moduleName,
+		packageName: dir,
+		ruleClass:   "alias",
+		content: fmt.Sprintf(
+			bazelTarget,
+			"alias",
+			moduleName,
+			propsToAttributes(map[string]string{"actual": fmt.Sprintf("%q", actual)}),
+		),
+	}, true
 }
 
 // Convert a module and its deps and props into a Bazel macro/rule
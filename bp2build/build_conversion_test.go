@@ -459,6 +459,20 @@ custom {
 				}),
 			},
 		},
+		{
+			description: "renamed target gets an alias from the module name",
+			blueprint: `custom {
+    name: "foo",
+    target_name: "bar",
+    bazel_module: { bp2build_available: true },
+}`,
+			expectedBazelTargets: []string{
+				makeBazelTarget("custom", "bar", attrNameToString{}),
+				makeBazelTarget("alias", "foo", attrNameToString{
+					"actual": `":bar"`,
+				}),
+			},
+		},
 	}
 
 	dir := "."
@@ -1031,6 +1045,38 @@ filegroup { name: "opt-out-h", bazel_module: { bp2build_available: false } }
 	}
 }
 
+func TestBp2buildModuleDenylist(t *testing.T) {
+	fs := map[string][]byte{
+		"migrated/Android.bp": []byte(`
+filegroup { name: "a" }
+filegroup { name: "denied" }
+`),
+	}
+	config := android.TestConfig(buildDir, nil, "", fs)
+	ctx := android.NewTestContext(config)
+	ctx.RegisterModuleType("filegroup", android.FileGroupFactory)
+	ctx.RegisterBp2BuildConfig(android.Bp2BuildConfig{
+		"migrated": android.Bp2BuildDefaultTrueRecursively,
+	})
+	ctx.RegisterBp2BuildModuleDenylist([]string{"denied"})
+	ctx.RegisterForBazelConversion()
+
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp", "migrated/Android.bp"})
+	android.FailIfErrored(t, errs)
+	_, errs = ctx.ResolveDependencies(config)
+	android.FailIfErrored(t, errs)
+
+	codegenCtx := NewCodegenContext(config, *ctx.Context, Bp2Build)
+	bazelTargets, err := generateBazelTargetsForDir(codegenCtx, "migrated")
+	android.FailIfErrored(t, err)
+	if actualCount := len(bazelTargets); actualCount != 1 {
+		t.Fatalf("Expected 1 bazel target, got %d: %s", actualCount, bazelTargets)
+	}
+	if name := bazelTargets[0].name; name != "a" {
+		t.Errorf("Expected bazel target for module %q, got %q", "a", name)
+	}
+}
+
 func TestCombineBuildFilesBp2buildTargets(t *testing.T) {
 	testCases := []bp2buildTestCase{
 		{
@@ -1346,3 +1392,168 @@ filegroup {
 		})
 	}
 }
+
+func TestTestonlyForTestModules(t *testing.T) {
+	runBp2BuildTestCase(t, func(ctx android.RegistrationContext) {
+		ctx.RegisterModuleType("custom", customModuleFactory)
+	}, bp2buildTestCase{
+		description:                "test module types are testonly",
+		moduleTypeUnderTest:        "custom_test",
+		moduleTypeUnderTestFactory: customModuleFactory,
+		blueprint: `
+custom {
+    name: "lib",
+    bazel_module: { bp2build_available: true },
+}
+
+custom_test {
+    name: "lib_test",
+    bazel_module: { bp2build_available: true },
+}`,
+		expectedBazelTargets: []string{
+			makeBazelTarget("custom", "lib", attrNameToString{}),
+			makeBazelTarget("custom", "lib_test", attrNameToString{
+				"testonly": "True",
+			}),
+		},
+	})
+}
+
+func TestPackageDefaultApplicableLicenses(t *testing.T) {
+	registerPackageAndLicense := func(ctx android.RegistrationContext) {
+		ctx.RegisterModuleType("package", android.PackageFactory)
+		ctx.RegisterModuleType("license", android.LicenseFactory)
+	}
+	testCases := []bp2buildTestCase{
+		{
+			description:                "license in the same package",
+			moduleTypeUnderTest:        "filegroup",
+			moduleTypeUnderTestFactory: android.FileGroupFactory,
+			blueprint: `
+package {
+    default_applicable_licenses: ["foo_license"],
+}
+
+license {
+    name: "foo_license",
+}
+
+filegroup {
+    name: "fg_foo",
+    bazel_module: { bp2build_available: true },
+}`,
+			expectedBazelTargets: []string{
+				`package(default_applicable_licenses = [":foo_license"])`,
+				makeBazelTarget("android_license", "foo_license", attrNameToString{}),
+				makeBazelTarget("filegroup", "fg_foo", map[string]string{}),
+			},
+		},
+		{
+			description:                "licenses in other packages",
+			moduleTypeUnderTest:        "filegroup",
+			moduleTypeUnderTestFactory: android.FileGroupFactory,
+			filesystem: map[string]string{
+				"licenses/Android.bp": `
+license {
+    name: "bar_license",
+}
+
+license {
+    name: "baz_license",
+}`,
+				"foo/Android.bp": `
+package {
+    default_applicable_licenses: [
+        "bar_license",
+        "//licenses:baz_license",
+    ],
+}
+
+filegroup {
+    name: "fg_foo",
+    bazel_module: { bp2build_available: true },
+}`,
+			},
+			dir: "foo",
+			expectedBazelTargets: []string{
+				`package(default_applicable_licenses = [
+    "//licenses:bar_license",
+    "//licenses:baz_license",
+])`,
+				makeBazelTarget("filegroup", "fg_foo", map[string]string{}),
+			},
+		},
+		{
+			description:                "license targets in other packages",
+			moduleTypeUnderTest:        "filegroup",
+			moduleTypeUnderTestFactory: android.FileGroupFactory,
+			filesystem: map[string]string{
+				"licenses/Android.bp": `
+license {
+    name: "bar_license",
+}
+
+license {
+    name: "baz_license",
+}`,
+				"foo/Android.bp": `
+package {
+    default_applicable_licenses: [
+        "bar_license",
+        "//licenses:baz_license",
+    ],
+}`,
+			},
+			dir: "licenses",
+			expectedBazelTargets: []string{
+				makeBazelTarget("android_license", "bar_license", attrNameToString{}),
+				makeBazelTarget("android_license", "baz_license", attrNameToString{}),
+			},
+		},
+		{
+			description:                "unconverted license",
+			moduleTypeUnderTest:        "filegroup",
+			moduleTypeUnderTestFactory: android.FileGroupFactory,
+			blueprint: `
+package {
+    default_applicable_licenses: ["foo_license"],
+}
+
+license {
+    name: "foo_license",
+    bazel_module: { bp2build_available: false },
+}
+
+filegroup {
+    name: "fg_foo",
+    bazel_module: { bp2build_available: true },
+}`,
+			expectedBazelTargets: []string{
+				makeBazelTarget("filegroup", "fg_foo", map[string]string{}),
+			},
+		},
+		{
+			description:                "no default licenses",
+			moduleTypeUnderTest:        "filegroup",
+			moduleTypeUnderTestFactory: android.FileGroupFactory,
+			blueprint: `
+package {
+    default_visibility: ["//visibility:public"],
+}
+
+filegroup {
+    name: "fg_foo",
+    bazel_module: { bp2build_available: true },
+}`,
+			expectedBazelTargets: []string{
+				makeBazelTarget("filegroup", "fg_foo", map[string]string{}),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			runBp2BuildTestCase(t, registerPackageAndLicense, tc)
+		})
+	}
+}
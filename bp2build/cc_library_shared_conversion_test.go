@@ -279,6 +279,56 @@ cc_library_shared {
 	})
 }
 
+func TestCcLibrarySharedStaticLibsAndWholeStaticLibs(t *testing.T) {
+	runCcLibrarySharedTestCase(t, bp2buildTestCase{
+		description: "cc_library_shared base, arch, and os-specific static_libs with whole_static_libs",
+		filesystem:  map[string]string{},
+		blueprint: soongCcLibrarySharedPreamble + `
+cc_library_static {
+    name: "static_dep",
+    bazel_module: { bp2build_available: false },
+}
+cc_library_static {
+    name: "static_dep_exported",
+    bazel_module: { bp2build_available: false },
+}
+cc_library_static {
+    name: "static_dep2",
+    bazel_module: { bp2build_available: false },
+}
+cc_library_static {
+    name: "static_dep3",
+    bazel_module: { bp2build_available: false },
+}
+cc_library_static {
+    name: "whole_static_dep",
+    bazel_module: { bp2build_available: false },
+}
+cc_library_shared {
+    name: "foo_shared",
+    static_libs: ["static_dep", "static_dep_exported"],
+    export_static_lib_headers: ["static_dep_exported"],
+    whole_static_libs: ["whole_static_dep"],
+    target: { android: { static_libs: ["static_dep2"] } },
+    arch: { arm64: { static_libs: ["static_dep3"] } },
+    include_build_directory: false,
+}`,
+		expectedBazelTargets: []string{
+			makeBazelTarget("cc_library_shared", "foo_shared", attrNameToString{
+				"deps": `[":static_dep_exported"]`,
+				"implementation_deps": `[":static_dep"] + select({
+        "//build/bazel/platforms/arch:arm64": [":static_dep3"],
+        "//conditions:default": [],
+    }) + select({
+        "//build/bazel/platforms/os:android": [":static_dep2"],
+        "//conditions:default": [],
+    })`,
+				"whole_archive_deps": `[":whole_static_dep"]`,
+			}),
+		},
+	})
+}
+
 func TestCcLibrarySharedSimpleExcludeSrcs(t *testing.T) {
 	runCcLibrarySharedTestCase(t, bp2buildTestCase{
 		description: "cc_library_shared simple exclude_srcs",
@@ -359,6 +409,43 @@ cc_library_shared {
 	})
 }
 
+func TestCcLibrarySharedArchSpecificVersionScript(t *testing.T) {
+	runCcLibrarySharedTestCase(t, bp2buildTestCase{
+		description: "cc_library_shared arch-specific version script",
+		filesystem: map[string]string{
+			"arm.map":   "",
+			"arm64.map": "",
+		},
+		blueprint: soongCcLibrarySharedPreamble + `
+cc_library_shared {
+    name: "foo_shared",
+    arch: {
+        arm: {
+            version_script: "arm.map",
+        },
+        arm64: {
+            version_script: "arm64.map",
+        },
+    },
+    include_build_directory: false,
+}`,
+		expectedBazelTargets: []string{
+			makeBazelTarget("cc_library_shared", "foo_shared", attrNameToString{
+				"additional_linker_inputs": `select({
+        "//build/bazel/platforms/arch:arm": ["arm.map"],
+        "//build/bazel/platforms/arch:arm64": ["arm64.map"],
+        "//conditions:default": [],
+    })`,
+				"linkopts": `select({
+        "//build/bazel/platforms/arch:arm": ["-Wl,--version-script,$(location arm.map)"],
+        "//build/bazel/platforms/arch:arm64": ["-Wl,--version-script,$(location arm64.map)"],
+        "//conditions:default": [],
+    })`,
+			}),
+		},
+	})
+}
+
 func TestCcLibrarySharedNoCrtTrue(t *testing.T) {
 	runCcLibrarySharedTestCase(t, bp2buildTestCase{
 		description: "cc_library_shared - nocrt: true emits attribute",
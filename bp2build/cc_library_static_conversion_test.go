@@ -1148,6 +1148,128 @@ cc_library_static {
 	})
 }
 
+func TestCcLibraryStaticHostTargetSelects(t *testing.T) {
+	runCcLibraryStaticTestCase(t, bp2buildTestCase{
+		description: "cc_library_static host, not_windows and windows target selects",
+		blueprint: soongCcLibraryStaticPreamble + `
+cc_library_static {
+    name: "foo_static",
+    srcs: ["common.c"],
+    shared_libs: ["libfoo"],
+    target: {
+        host: {
+            cflags: ["-DHOST"],
+        },
+        not_windows: {
+            cflags: ["-DNOT_WINDOWS"],
+        },
+        windows: {
+            exclude_shared_libs: ["libfoo"],
+        },
+    },
+    include_build_directory: false,
+}
+
+cc_library {
+    name: "libfoo",
+    bazel_module: { bp2build_available: false },
+}`,
+		expectedBazelTargets: []string{
+			makeBazelTarget("cc_library_static", "foo_static", attrNameToString{
+				"copts": `select({
+        "//build/bazel/platforms/os:darwin": [
+            "-DHOST",
+            "-DNOT_WINDOWS",
+        ],
+        "//build/bazel/platforms/os:linux": [
+            "-DHOST",
+            "-DNOT_WINDOWS",
+        ],
+        "//build/bazel/platforms/os:linux_bionic": [
+            "-DHOST",
+            "-DNOT_WINDOWS",
+        ],
+        "//build/bazel/platforms/os:linux_musl": [
+            "-DHOST",
+            "-DNOT_WINDOWS",
+        ],
+        "//build/bazel/platforms/os:windows": ["-DHOST"],
+        "//conditions:default": [],
+    })`,
+				"implementation_dynamic_deps": `select({
+        "//build/bazel/platforms/os:windows": [],
+        "//conditions:default": [":libfoo"],
+    })`,
+				"srcs_c": `["common.c"]`,
+			}),
+		},
+	})
+}
+
+func TestCcLibraryStaticProductVariableSrcs(t *testing.T) {
+	runCcLibraryStaticTestCase(t, bp2buildTestCase{
+		description: "cc_library_static product variable srcs",
+		filesystem: map[string]string{
+			"common.c":            "",
+			"malloc_not_svelte.c": "",
+		},
+		blueprint: soongCcLibraryStaticPreamble + `
+cc_library_static {
+    name: "foo_static",
+    srcs: ["common.c"],
+    product_variables: {
+      malloc_not_svelte: {
+        srcs: ["malloc_not_svelte.c"],
+      },
+    },
+    include_build_directory: false,
+} `,
+		expectedBazelTargets: []string{
+			makeBazelTarget("cc_library_static", "foo_static", attrNameToString{
+				"srcs_c": `["common.c"] + select({
+        "//build/bazel/product_variables:malloc_not_svelte": ["malloc_not_svelte.c"],
+        "//conditions:default": [],
+    })`,
+			}),
+		},
+	})
+}
+
+func TestCcLibraryStaticArchCflagsRemove(t *testing.T) {
+	runCcLibraryStaticTestCase(t, bp2buildTestCase{
+		description: "cc_library_static arch-specific cflags_remove",
+		blueprint: soongCcLibraryStaticPreamble + `
+cc_library_static {
+    name: "foo_static",
+    srcs: ["common.c"],
+    cflags: ["-Wall", "-Wno-error"],
+    arch: {
+        arm: {
+            cflags: ["-Darm"],
+            cflags_remove: ["-Wno-error"],
+        },
+        x86: {
+            cflags: ["-Dx86"],
+        },
+    },
+    include_build_directory: false,
+} `,
+		expectedBazelTargets: []string{
+			makeBazelTarget("cc_library_static", "foo_static", attrNameToString{
+				"copts": `["-Wall"] + select({
+        "//build/bazel/platforms/arch:arm": ["-Darm"],
+        "//build/bazel/platforms/arch:x86": [
+            "-Wno-error",
+            "-Dx86",
+        ],
+        "//conditions:default": ["-Wno-error"],
+    })`,
+				"srcs_c": `["common.c"]`,
+			}),
+		},
+	})
+}
+
 func TestCcLibraryStaticProductVariableArchSpecificSelects(t *testing.T) {
 	runCcLibraryStaticTestCase(t, bp2buildTestCase{
 		description: "cc_library_static arch-specific product variable selects",
@@ -1489,3 +1611,48 @@ func TestCcLibraryStaticStdInFlags(t *testing.T) {
 		},
 	})
 }
+
+func TestCcLibraryStaticHostNotSupported(t *testing.T) {
+	runCcLibraryStaticTestCase(t, bp2buildTestCase{
+		description: "cc_library_static with host_supported: false is only compatible with android",
+		blueprint: soongCcLibraryStaticPreamble + `
+cc_library_static {
+    name: "foo",
+    host_supported: false,
+    include_build_directory: false,
+}
+`,
+		expectedBazelTargets: []string{
+			makeBazelTarget("cc_library_static", "foo", attrNameToString{
+				"target_compatible_with": androidOnlyTargetCompatibleWith,
+			}),
+		},
+	})
+}
+
+func TestCcLibraryStaticRequiredIntoData(t *testing.T) {
+	runCcLibraryStaticTestCase(t, bp2buildTestCase{
+		description: "cc_library_static required and arch specific required into data",
+		blueprint: soongCcLibraryStaticPreamble +
+			simpleModuleDoNotConvertBp2build("cc_library_static", "reqd") +
+			simpleModuleDoNotConvertBp2build("cc_library_static", "reqdarm") + `
+cc_library_static {
+    name: "foo_static",
+    required: ["reqd"],
+    arch: {
+        arm: {
+            required: ["reqdarm"],
+        },
+    },
+    include_build_directory: false,
+}`,
+		expectedBazelTargets: []string{
+			makeBazelTarget("cc_library_static", "foo_static", attrNameToString{
+				"data": `[":reqd"] + select({
+        "//build/bazel/platforms/arch:arm": [":reqdarm"],
+        "//conditions:default": [],
+    })`,
+			}),
+		},
+	})
+}
@@ -59,3 +59,46 @@ cc_prebuilt_library_shared {
 			},
 		})
 }
+
+func TestSharedPrebuiltLibraryWithExportedIncludes(t *testing.T) {
+	runBp2BuildTestCaseSimple(t,
+		bp2buildTestCase{
+			description:                "prebuilt library shared with arch variance and exported includes",
+			moduleTypeUnderTest:        "cc_prebuilt_library_shared",
+			moduleTypeUnderTestFactory: cc.PrebuiltSharedLibraryFactory,
+			filesystem: map[string]string{
+				"arm/libf.so":   "",
+				"arm64/libf.so": "",
+			},
+			blueprint: `
+cc_prebuilt_library_shared {
+	name: "libtest",
+	export_include_dirs: ["include"],
+	export_system_include_dirs: ["system_include"],
+	arch: {
+		arm: {
+			srcs: ["arm/libf.so"],
+			export_include_dirs: ["arm/include"],
+		},
+		arm64: {
+			srcs: ["arm64/libf.so"],
+		},
+	},
+	bazel_module: { bp2build_available: true },
+}`,
+			expectedBazelTargets: []string{
+				makeBazelTarget("prebuilt_library_shared", "libtest", attrNameToString{
+					"export_includes": `["include"] + select({
+        "//build/bazel/platforms/arch:arm": ["arm/include"],
+        "//conditions:default": [],
+    })`,
+					"export_system_includes": `["system_include"]`,
+					"shared_library": `select({
+        "//build/bazel/platforms/arch:arm": "arm/libf.so",
+        "//build/bazel/platforms/arch:arm64": "arm64/libf.so",
+        "//conditions:default": None,
+    })`,
+				}),
+			},
+		})
+}
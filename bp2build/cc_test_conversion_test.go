@@ -0,0 +1,185 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bp2build
+
+import (
+	"testing"
+
+	"android/soong/android"
+	"android/soong/cc"
+)
+
+func registerCcTestModuleTypes(ctx android.RegistrationContext) {
+	cc.RegisterCCBuildComponents(ctx)
+	ctx.RegisterModuleType("filegroup", android.FileGroupFactory)
+	ctx.RegisterModuleType("cc_library_static", cc.LibraryStaticFactory)
+	ctx.RegisterModuleType("cc_library", cc.LibraryFactory)
+}
+
+func runCcTestTestCase(t *testing.T, tc bp2buildTestCase) {
+	t.Helper()
+	(&tc).moduleTypeUnderTest = "cc_test"
+	(&tc).moduleTypeUnderTestFactory = cc.TestFactory
+	runBp2BuildTestCase(t, registerCcTestModuleTypes, tc)
+}
+
+func TestCcTestWithData(t *testing.T) {
+	runCcTestTestCase(t, bp2buildTestCase{
+		description: "cc_test with data and test suites",
+		filesystem: map[string]string{
+			"testdata/input.txt": "",
+		},
+		blueprint: `
+cc_test {
+    name: "foo_test",
+    srcs: ["foo_test.cc"],
+    cflags: ["-Wall"],
+    static_libs: ["libbar"],
+    shared_libs: ["libbaz"],
+    data: ["testdata/input.txt"],
+    test_suites: ["general-tests", "device-tests"],
+    gtest: false,
+    include_build_directory: false,
+}
+` + simpleModuleDoNotConvertBp2build("cc_library_static", "libbar") +
+			simpleModuleDoNotConvertBp2build("cc_library", "libbaz"),
+		expectedBazelTargets: []string{
+			makeBazelTarget("cc_test", "foo_test", attrNameToString{
+				"copts":        `["-Wall"]`,
+				"data":         `["testdata/input.txt"]`,
+				"deps":         `[":libbar"]`,
+				"dynamic_deps": `[":libbaz"]`,
+				"srcs":         `["foo_test.cc"]`,
+				"tags": `[
+        "general-tests",
+        "device-tests",
+    ]`,
+				"testonly": `True`,
+			}),
+		},
+	})
+}
+
+func TestCcTestArchSpecificSrcs(t *testing.T) {
+	runCcTestTestCase(t, bp2buildTestCase{
+		description: "cc_test with arch specific srcs and data",
+		blueprint: `
+cc_test {
+    name: "foo_test",
+    srcs: ["foo_test.cc"],
+    arch: {
+        arm: {
+            srcs: ["foo_test_arm.cc"],
+            data: ["arm_data.txt"],
+        },
+        x86: { srcs: ["foo_test_x86.cc"] },
+    },
+    gtest: false,
+    include_build_directory: false,
+}
+`,
+		expectedBazelTargets: []string{
+			makeBazelTarget("cc_test", "foo_test", attrNameToString{
+				"data": `select({
+        "//build/bazel/platforms/arch:arm": ["arm_data.txt"],
+        "//conditions:default": [],
+    })`,
+				"srcs": `["foo_test.cc"] + select({
+        "//build/bazel/platforms/arch:arm": ["foo_test_arm.cc"],
+        "//build/bazel/platforms/arch:x86": ["foo_test_x86.cc"],
+        "//conditions:default": [],
+    })`,
+				"testonly": `True`,
+			}),
+		},
+	})
+}
+
+const gtestCopts = `["-DGTEST_HAS_STD_STRING"] + select({
+        "//build/bazel/platforms/os:android": ["-DGTEST_OS_LINUX_ANDROID"],
+        "//build/bazel/platforms/os:darwin": [
+            "-O0",
+            "-g",
+            "-DGTEST_OS_MAC",
+        ],
+        "//build/bazel/platforms/os:linux": [
+            "-O0",
+            "-g",
+            "-DGTEST_OS_LINUX",
+        ],
+        "//build/bazel/platforms/os:linux_bionic": [
+            "-O0",
+            "-g",
+        ],
+        "//build/bazel/platforms/os:linux_musl": [
+            "-O0",
+            "-g",
+        ],
+        "//build/bazel/platforms/os:windows": [
+            "-O0",
+            "-g",
+            "-DGTEST_OS_WINDOWS",
+        ],
+        "//conditions:default": [],
+    })`
+
+func TestCcTestGtest(t *testing.T) {
+	runCcTestTestCase(t, bp2buildTestCase{
+		description: "cc_test with the default gtest",
+		blueprint: `
+cc_test {
+    name: "foo_test",
+    srcs: ["foo_test.cc"],
+    include_build_directory: false,
+}
+` + simpleModuleDoNotConvertBp2build("cc_library_static", "libgtest_main") +
+			simpleModuleDoNotConvertBp2build("cc_library_static", "libgtest"),
+		expectedBazelTargets: []string{
+			makeBazelTarget("cc_test", "foo_test", attrNameToString{
+				"copts": gtestCopts,
+				"deps": `[
+        ":libgtest_main",
+        ":libgtest",
+    ]`,
+				"srcs":     `["foo_test.cc"]`,
+				"testonly": `True`,
+			}),
+		},
+	})
+}
+
+func TestCcTestGtestIsolated(t *testing.T) {
+	runCcTestTestCase(t, bp2buildTestCase{
+		description: "cc_test with isolated gtest",
+		blueprint: `
+cc_test {
+    name: "foo_test",
+    srcs: ["foo_test.cc"],
+    isolated: true,
+    include_build_directory: false,
+}
+` + simpleModuleDoNotConvertBp2build("cc_library_static", "libgtest_isolated_main") +
+			simpleModuleDoNotConvertBp2build("cc_library", "liblog"),
+		expectedBazelTargets: []string{
+			makeBazelTarget("cc_test", "foo_test", attrNameToString{
+				"copts":        gtestCopts,
+				"deps":         `[":libgtest_isolated_main"]`,
+				"dynamic_deps": `[":liblog"]`,
+				"srcs":         `["foo_test.cc"]`,
+				"testonly":     `True`,
+			}),
+		},
+	})
+}
@@ -3,6 +3,7 @@ package bp2build
 import (
 	"fmt"
 	"reflect"
+	"strings"
 
 	"android/soong/android"
 	"android/soong/bazel"
@@ -127,6 +128,12 @@ var (
 	bazelNone      = "None"
 )
 
+// MarshalAttribute converts an Attribute to its Starlark representation, i.e. its
+// base value followed by a select() for each configuration axis it has values for.
+func MarshalAttribute(attr bazel.Attribute) (string, error) {
+	return prettyPrintAttribute(attr, 0)
+}
+
 // prettyPrintAttribute converts an Attribute to its Bazel syntax. May contain
 // select statements.
 func prettyPrintAttribute(v bazel.Attribute, indent int) (string, error) {
@@ -147,6 +154,8 @@ func prettyPrintAttribute(v bazel.Attribute, indent int) (string, error) {
 		if list.ForceSpecifyEmptyList && (!value.IsNil() || list.HasConfigurableValues()) {
 			shouldPrintDefault = true
 		}
+	case bazel.GlobListAttribute:
+		return prettyPrintGlobList(list, indent)
 	case bazel.LabelAttribute:
 		if err := list.Collapse(); err != nil {
 			return "", err
@@ -200,6 +209,27 @@ func prettyPrintAttribute(v bazel.Attribute, indent int) (string, error) {
 	return ret, nil
 }
 
+// prettyPrintGlobList converts a GlobListAttribute to a glob() call followed by the list of the
+// other labels, if any.
+func prettyPrintGlobList(list bazel.GlobListAttribute, indent int) (string, error) {
+	var parts []string
+	if len(list.Globs) > 0 {
+		glob := "glob(" + starlark_fmt.PrintStringList(list.Globs, indent)
+		if len(list.Excludes) > 0 {
+			glob += ", exclude = " + starlark_fmt.PrintStringList(list.Excludes, indent)
+		}
+		parts = append(parts, glob+")")
+	}
+	if len(list.Labels.Includes) > 0 {
+		labels, err := prettyPrint(reflect.ValueOf(list.Labels.Includes), indent, false)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, labels)
+	}
+	return strings.Join(parts, " + "), nil
+}
+
 // prettyPrintSelectMap converts a map of select keys to reflected Values as a generic way
 // to construct a select map for any kind of attribute type.
 func prettyPrintSelectMap(selectMap map[string]reflect.Value, defaultValue *string, indent int, emitZeroValues bool) (string, error) {
@@ -0,0 +1,90 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bp2build
+
+import (
+	"testing"
+
+	"android/soong/bazel"
+)
+
+func TestMarshalAttribute(t *testing.T) {
+	labels := func(names ...string) bazel.LabelList {
+		var ret []bazel.Label
+		for _, name := range names {
+			ret = append(ret, bazel.Label{Label: name})
+		}
+		return bazel.MakeLabelList(ret)
+	}
+
+	testCases := []struct {
+		description string
+		attr        func() bazel.Attribute
+		expected    string
+	}{
+		{
+			description: "base value only",
+			attr: func() bazel.Attribute {
+				return bazel.MakeLabelListAttribute(labels("base"))
+			},
+			expected: `["base"]`,
+		},
+		{
+			description: "base, arch and os values",
+			attr: func() bazel.Attribute {
+				attr := bazel.MakeLabelListAttribute(labels("base1", "base2"))
+				attr.SetSelectValue(bazel.ArchConfigurationAxis, "arm", labels("arm"))
+				attr.SetSelectValue(bazel.ArchConfigurationAxis, "x86", labels("x86"))
+				attr.SetSelectValue(bazel.OsConfigurationAxis, "android", labels("android"))
+				return attr
+			},
+			expected: `[
+    "base1",
+    "base2",
+] + select({
+    "//build/bazel/platforms/arch:arm": ["arm"],
+    "//build/bazel/platforms/arch:x86": ["x86"],
+    "//conditions:default": [],
+}) + select({
+    "//build/bazel/platforms/os:android": ["android"],
+    "//conditions:default": [],
+})`,
+		},
+		{
+			description: "configured values only",
+			attr: func() bazel.Attribute {
+				attr := bazel.LabelListAttribute{}
+				attr.SetSelectValue(bazel.OsConfigurationAxis, "linux", labels("linux"))
+				return attr
+			},
+			expected: `select({
+    "//build/bazel/platforms/os:linux": ["linux"],
+    "//conditions:default": [],
+})`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			got, err := MarshalAttribute(tc.attr())
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != tc.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tc.expected, got)
+			}
+		})
+	}
+}
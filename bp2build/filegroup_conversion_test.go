@@ -56,3 +56,223 @@ filegroup {
 		expectedErr: fmt.Errorf("filegroup 'foo' cannot contain a file with the same name"),
 	})
 }
+
+func TestFilegroupWithBazelTags(t *testing.T) {
+	runFilegroupTestCase(t, bp2buildTestCase{
+		description: "filegroup - with bazel_tags",
+		filesystem:  map[string]string{},
+		blueprint: `
+filegroup {
+    name: "fg_foo",
+    srcs: ["a"],
+    bazel_tags: ["manual", "no-remote"],
+    bazel_module: { bp2build_available: true },
+}
+`,
+		expectedBazelTargets: []string{
+			makeBazelTarget("filegroup", "fg_foo", attrNameToString{
+				"srcs": `["a"]`,
+				"tags": `[
+        "manual",
+        "no-remote",
+    ]`,
+			}),
+		}})
+}
+
+func TestFilegroupWithEmptyBazelTags(t *testing.T) {
+	runFilegroupTestCase(t, bp2buildTestCase{
+		description: "filegroup - with empty bazel_tags",
+		filesystem:  map[string]string{},
+		blueprint: `
+filegroup {
+    name: "fg_foo",
+    srcs: ["a"],
+    bazel_tags: [],
+    bazel_module: { bp2build_available: true },
+}
+`,
+		expectedBazelTargets: []string{
+			makeBazelTarget("filegroup", "fg_foo", attrNameToString{
+				"srcs": `["a"]`,
+			}),
+		}})
+}
+
+func TestFilegroupWithBazelRuleOverride(t *testing.T) {
+	bp := `
+filegroup {
+    name: "fg_foo",
+    srcs: ["a"],
+    bazel_rule_override: {
+        rule_class: "my_filegroup",
+        bzl_load_location: "//build/bazel/rules:my_filegroup.bzl",
+    },
+    bazel_module: { bp2build_available: true },
+}
+`
+	config := android.TestConfig(buildDir, nil, bp, nil)
+	ctx := android.NewTestContext(config)
+	ctx.RegisterModuleType("filegroup", android.FileGroupFactory)
+	ctx.RegisterForBazelConversion()
+
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+	android.FailIfErrored(t, errs)
+	_, errs = ctx.ResolveDependencies(config)
+	android.FailIfErrored(t, errs)
+
+	codegenCtx := NewCodegenContext(config, *ctx.Context, Bp2Build)
+	bazelTargets, errs := generateBazelTargetsForDir(codegenCtx, ".")
+	android.FailIfErrored(t, errs)
+
+	expectedTarget := makeBazelTarget("my_filegroup", "fg_foo", attrNameToString{
+		"srcs": `["a"]`,
+	})
+	android.AssertStringEquals(t, "targets", expectedTarget, bazelTargets.String())
+	android.AssertStringEquals(t, "load statements",
+		`load("//build/bazel/rules:my_filegroup.bzl", "my_filegroup")`, bazelTargets.LoadStatements())
+}
+
+func TestFilegroupWithPath(t *testing.T) {
+	runFilegroupTestCase(t, bp2buildTestCase{
+		description: "filegroup - with path",
+		filesystem:  map[string]string{},
+		blueprint: `
+filegroup {
+    name: "fg_foo",
+    path: "java-res/",
+    srcs: ["java-res/a/a", "java-res/b/b"],
+    bazel_module: { bp2build_available: true },
+}
+`,
+		expectedBazelTargets: []string{
+			makeBazelTarget("filegroup", "fg_foo", attrNameToString{
+				"path": `"java-res"`,
+				"srcs": `[
+        "java-res/a/a",
+        "java-res/b/b",
+    ]`,
+			}),
+		}})
+}
+
+func TestFilegroupWithPathInSubpackage(t *testing.T) {
+	runFilegroupTestCase(t, bp2buildTestCase{
+		description: "filegroup - with path into a subpackage",
+		filesystem: map[string]string{
+			"java-res/Android.bp": "",
+		},
+		blueprint: `
+filegroup {
+    name: "fg_foo",
+    path: "java-res",
+    srcs: ["java-res/a/a"],
+    bazel_module: { bp2build_available: true },
+}
+`,
+		expectedBazelTargets: []string{
+			makeBazelTarget("filegroup", "fg_foo", attrNameToString{
+				"path": `"java-res"`,
+				"srcs": `["//java-res:a/a"]`,
+			}),
+		}})
+}
+
+func TestFilegroupWithCurrentDirectoryPath(t *testing.T) {
+	runFilegroupTestCase(t, bp2buildTestCase{
+		description: "filegroup - with path of the package directory",
+		filesystem:  map[string]string{},
+		blueprint: `
+filegroup {
+    name: "fg_foo",
+    path: ".",
+    srcs: ["a"],
+    bazel_module: { bp2build_available: true },
+}
+`,
+		expectedBazelTargets: []string{
+			makeBazelTarget("filegroup", "fg_foo", attrNameToString{
+				"srcs": `["a"]`,
+			}),
+		}})
+}
+
+func TestFilegroupWithGlobsEmittedAsGlob(t *testing.T) {
+	runFilegroupTestCase(t, bp2buildTestCase{
+		description: "filegroup - glob patterns emitted as glob()",
+		env:         map[string]string{"BP2BUILD_EMIT_GLOBS": "true"},
+		filesystem: map[string]string{
+			"a/a.java": "",
+			"b/b.java": "",
+			"c.txt":    "",
+		},
+		blueprint: `
+filegroup {
+    name: "fg_foo",
+    srcs: ["**/*.java", "c.txt"],
+    exclude_srcs: ["b/*.java"],
+    bazel_module: { bp2build_available: true },
+}
+`,
+		expectedBazelTargets: []string{
+			makeBazelTarget("filegroup", "fg_foo", attrNameToString{
+				"srcs": `glob(["**/*.java"], exclude = ["b/*.java"]) + ["c.txt"]`,
+			}),
+		}})
+}
+
+func TestFilegroupWithGlobsIntoSubpackageExpanded(t *testing.T) {
+	runFilegroupTestCase(t, bp2buildTestCase{
+		description: "filegroup - glob patterns matching files in subpackages are expanded",
+		env:         map[string]string{"BP2BUILD_EMIT_GLOBS": "true"},
+		filesystem: map[string]string{
+			"a.java":       "",
+			"b/Android.bp": "",
+			"b/b.java":     "",
+		},
+		blueprint: `
+filegroup {
+    name: "fg_foo",
+    srcs: ["**/*.java"],
+    bazel_module: { bp2build_available: true },
+}
+`,
+		expectedBazelTargets: []string{
+			makeBazelTarget("filegroup", "fg_foo", attrNameToString{
+				"srcs": `[
+        "a.java",
+        "//b:b.java",
+    ]`,
+			}),
+		}})
+}
+
+func TestFilegroupWithFilesInOtherPackageExported(t *testing.T) {
+	runFilegroupTestCase(t, bp2buildTestCase{
+		description: "filegroup - files in another package are exported by that package",
+		dir:         "other",
+		filesystem: map[string]string{
+			"other/Android.bp": "",
+			"other/b.txt":      "",
+			"other/sub/a.txt":  "",
+		},
+		blueprint: `
+filegroup {
+    name: "fg_foo",
+    srcs: ["other/sub/a.txt", "other/b.txt"],
+    bazel_module: { bp2build_available: true },
+}
+
+filegroup {
+    name: "fg_bar",
+    srcs: ["other/b.txt"],
+    bazel_module: { bp2build_available: true },
+}
+`,
+		expectedBazelTargets: []string{
+			`exports_files([
+    "b.txt",
+    "sub/a.txt",
+])`,
+		}})
+}
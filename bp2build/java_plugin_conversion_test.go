@@ -106,3 +106,27 @@ java_library {
 		},
 	})
 }
+
+func TestJavaPluginProcessorClassAndGeneratesApi(t *testing.T) {
+	runJavaPluginTestCase(t, bp2buildTestCase{
+		description: "java_plugin with processor_class and generates_api",
+		blueprint: `java_plugin {
+    name: "java-plug-1",
+    srcs: ["a.java"],
+    processor_class: "com.android.FooProcessor",
+    generates_api: true,
+    bazel_module: { bp2build_available: true },
+}`,
+		expectedBazelTargets: []string{
+			makeBazelTarget("java_plugin", "java-plug-1", attrNameToString{
+				"target_compatible_with": `select({
+        "//build/bazel/platforms/os:android": ["@platforms//:incompatible"],
+        "//conditions:default": [],
+    })`,
+				"generates_api":   "True",
+				"processor_class": `"com.android.FooProcessor"`,
+				"srcs":            `["a.java"]`,
+			}),
+		},
+	})
+}
@@ -0,0 +1,78 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bp2build
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func runLicenseTestCase(t *testing.T, tc bp2buildTestCase) {
+	t.Helper()
+	(&tc).moduleTypeUnderTest = "license"
+	(&tc).moduleTypeUnderTestFactory = android.LicenseFactory
+	runBp2BuildTestCase(t, registerLicenseModuleTypes, tc)
+}
+
+func registerLicenseModuleTypes(ctx android.RegistrationContext) {
+	ctx.RegisterModuleType("license_kind", android.LicenseKindFactory)
+}
+
+func TestLicense(t *testing.T) {
+	runLicenseTestCase(t, bp2buildTestCase{
+		description: "license with all properties",
+		filesystem: map[string]string{
+			"NOTICE": "",
+		},
+		blueprint: `
+license_kind {
+    name: "SPDX-license-identifier-Apache-2.0",
+    conditions: ["notice"],
+}
+
+license {
+    name: "foo_license",
+    license_kinds: ["SPDX-license-identifier-Apache-2.0"],
+    copyright_notice: "Copyright (C) 2022 The Android Open Source Project",
+    license_text: ["NOTICE"],
+    package_name: "foo",
+}`,
+		expectedBazelTargets: []string{
+			makeBazelTarget("android_license", "foo_license", attrNameToString{
+				"copyright_notice": `"Copyright (C) 2022 The Android Open Source Project"`,
+				"license_kinds":    `["SPDX-license-identifier-Apache-2.0"]`,
+				"license_text":     `"NOTICE"`,
+				"package_name":     `"foo"`,
+			}),
+		},
+	})
+}
+
+func TestLicenseWithSeveralTextsIsNotConverted(t *testing.T) {
+	runLicenseTestCase(t, bp2buildTestCase{
+		description: "license with several license texts",
+		filesystem: map[string]string{
+			"NOTICE":  "",
+			"NOTICE2": "",
+		},
+		blueprint: `
+license {
+    name: "foo_license",
+    license_text: ["NOTICE", "NOTICE2"],
+}`,
+		expectedBazelTargets: []string{},
+	})
+}
@@ -2,6 +2,7 @@ package bp2build
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -35,6 +36,14 @@ type CodegenMetrics struct {
 	// NOTE: NOT in the .proto
 	moduleWithMissingDepsMsgs []string
 
+	// List of modules with set properties that their converter did not consume
+	// NOTE: NOT in the .proto
+	moduleWithDroppedPropertiesMsgs []string
+
+	// Properties set by each defaults module, keyed by converted module and then defaults module
+	// NOTE: NOT in the .proto
+	defaultsProvenance map[string]map[string][]string
+
 	// List of converted modules
 	convertedModules []string
 
@@ -75,6 +84,10 @@ func (metrics *CodegenMetrics) Print() {
 	%s
 %d converted modules have missing deps:
 	%s
+%d converted modules have dropped properties:
+	%s
+%d converted modules have properties from defaults:
+	%s
 `,
 		metrics.generatedModuleCount,
 		generatedTargetCount,
@@ -84,6 +97,10 @@ func (metrics *CodegenMetrics) Print() {
 		strings.Join(metrics.moduleWithUnconvertedDepsMsgs, "\n\t"),
 		len(metrics.moduleWithMissingDepsMsgs),
 		strings.Join(metrics.moduleWithMissingDepsMsgs, "\n\t"),
+		len(metrics.moduleWithDroppedPropertiesMsgs),
+		strings.Join(metrics.moduleWithDroppedPropertiesMsgs, "\n\t"),
+		len(metrics.defaultsProvenance),
+		strings.Join(metrics.defaultsProvenanceMsgs(), "\n\t"),
 	)
 }
 
@@ -121,10 +138,51 @@ func (metrics *CodegenMetrics) Write(dir string) {
 	}
 }
 
+// WriteMarker writes a summary of the conversion to the bp2build marker file at the given path.
+// It must only be called once code generation has succeeded, so that callers can rely on the
+// presence of the marker to detect a complete conversion.
+func (metrics *CodegenMetrics) WriteMarker(path string) error {
+	return ioutil.WriteFile(path, []byte(metrics.markerContents()), 0666)
+}
+
+func (metrics *CodegenMetrics) markerContents() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "converted_module_count: %d\n", metrics.generatedModuleCount+metrics.handCraftedModuleCount)
+	fmt.Fprintf(&sb, "generated_module_count: %d\n", metrics.generatedModuleCount)
+	fmt.Fprintf(&sb, "handcrafted_module_count: %d\n", metrics.handCraftedModuleCount)
+	fmt.Fprintf(&sb, "unconverted_module_count: %d\n", metrics.unconvertedModuleCount)
+	fmt.Fprintf(&sb, "total_module_count: %d\n", metrics.TotalModuleCount())
+	for _, ruleClass := range android.SortedStringKeys(metrics.ruleClassCount) {
+		fmt.Fprintf(&sb, "rule_class_count: %s %d\n", ruleClass, metrics.ruleClassCount[ruleClass])
+	}
+	return sb.String()
+}
+
 func (metrics *CodegenMetrics) IncrementRuleClassCount(ruleClass string) {
 	metrics.ruleClassCount[ruleClass] += 1
 }
 
+// AddDefaultsProvenance records the properties of a converted module that were set by each of its
+// defaults modules.
+func (metrics *CodegenMetrics) AddDefaultsProvenance(moduleName string, provenance map[string][]string) {
+	metrics.defaultsProvenance[moduleName] = provenance
+}
+
+// defaultsProvenanceMsgs returns a message for each converted module with properties from defaults,
+// sorted by module name.
+func (metrics *CodegenMetrics) defaultsProvenanceMsgs() []string {
+	var msgs []string
+	for _, moduleName := range android.SortedStringKeys(metrics.defaultsProvenance) {
+		provenance := metrics.defaultsProvenance[moduleName]
+		var sources []string
+		for _, defaultsName := range android.SortedStringKeys(provenance) {
+			sources = append(sources, fmt.Sprintf("%s from %s", strings.Join(provenance[defaultsName], ", "), defaultsName))
+		}
+		msgs = append(msgs, fmt.Sprintf("%q: %s", moduleName, strings.Join(sources, "; ")))
+	}
+	return msgs
+}
+
 func (metrics *CodegenMetrics) AddUnconvertedModule(moduleType string) {
 	metrics.unconvertedModuleCount += 1
 	metrics.totalModuleTypeCount[moduleType] += 1
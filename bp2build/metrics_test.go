@@ -0,0 +1,167 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bp2build
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"android/soong/android"
+	"android/soong/cc"
+)
+
+func TestWriteMarker(t *testing.T) {
+	fs := map[string][]byte{
+		"migrated/Android.bp": []byte(`
+filegroup { name: "a" }
+filegroup { name: "b" }
+filegroup {
+    name: "c",
+    bazel_module: { bp2build_available: false },
+}
+`),
+	}
+	config := android.TestConfig(buildDir, nil, "", fs)
+	ctx := android.NewTestContext(config)
+	ctx.RegisterModuleType("filegroup", android.FileGroupFactory)
+	ctx.RegisterBp2BuildConfig(android.Bp2BuildConfig{
+		"migrated": android.Bp2BuildDefaultTrueRecursively,
+	})
+	ctx.RegisterForBazelConversion()
+
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp", "migrated/Android.bp"})
+	android.FailIfErrored(t, errs)
+	_, errs = ctx.ResolveDependencies(config)
+	android.FailIfErrored(t, errs)
+
+	codegenCtx := NewCodegenContext(config, *ctx.Context, Bp2Build)
+	res, err := GenerateBazelTargets(codegenCtx, false)
+	android.FailIfErrored(t, err)
+
+	marker := filepath.Join(t.TempDir(), "bp2build_workspace_marker")
+	if err := res.metrics.WriteMarker(marker); err != nil {
+		t.Fatalf("Unexpected error writing marker: %s", err)
+	}
+	contents, err2 := ioutil.ReadFile(marker)
+	if err2 != nil {
+		t.Fatalf("Unexpected error reading marker: %s", err2)
+	}
+
+	expected := `converted_module_count: 2
+generated_module_count: 2
+handcrafted_module_count: 0
+unconverted_module_count: 1
+total_module_count: 3
+rule_class_count: filegroup 2
+`
+	android.AssertStringEquals(t, "marker contents", expected, string(contents))
+}
+
+func TestDroppedPropertiesAreReported(t *testing.T) {
+	fs := map[string][]byte{
+		"migrated/Android.bp": []byte(`
+filegroup {
+    name: "a",
+    srcs: ["a.txt"],
+    bazel_tags: ["manual"],
+    bazel_rule_override: { rule_class: "filegroup" },
+}
+filegroup {
+    name: "b",
+    srcs: ["b.txt"],
+    path: "sub",
+    export_to_make_var: "B_FILES",
+}
+`),
+	}
+	config := android.TestConfig(buildDir, nil, "", fs)
+	ctx := android.NewTestContext(config)
+	ctx.RegisterModuleType("filegroup", android.FileGroupFactory)
+	ctx.RegisterBp2BuildConfig(android.Bp2BuildConfig{
+		"migrated": android.Bp2BuildDefaultTrueRecursively,
+	})
+	ctx.RegisterForBazelConversion()
+
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp", "migrated/Android.bp"})
+	android.FailIfErrored(t, errs)
+	_, errs = ctx.ResolveDependencies(config)
+	android.FailIfErrored(t, errs)
+
+	codegenCtx := NewCodegenContext(config, *ctx.Context, Bp2Build)
+	res, err := GenerateBazelTargets(codegenCtx, false)
+	android.FailIfErrored(t, err)
+
+	android.AssertDeepEquals(t, "dropped properties",
+		[]string{`"b" sets properties that were not converted: export_to_make_var`},
+		res.metrics.moduleWithDroppedPropertiesMsgs)
+}
+
+func TestDefaultsProvenanceIsReported(t *testing.T) {
+	fs := map[string][]byte{
+		"migrated/Android.bp": []byte(`
+cc_defaults {
+    name: "foo_defaults",
+    cflags: ["-Wall"],
+    include_build_directory: false,
+}
+cc_defaults {
+    name: "bar_defaults",
+    defaults: ["foo_defaults"],
+    srcs: ["bar.cpp"],
+}
+cc_library_static {
+    name: "foo",
+    defaults: ["bar_defaults"],
+    system_shared_libs: [],
+}
+cc_library_static {
+    name: "baz",
+    include_build_directory: false,
+    system_shared_libs: [],
+}
+`),
+		"migrated/bar.cpp": nil,
+	}
+	config := android.TestConfig(buildDir, nil, soongCcLibraryStaticPreamble, fs)
+	ctx := android.NewTestContext(config)
+	registerCcLibraryStaticModuleTypes(ctx)
+	ctx.RegisterModuleType("cc_library_static", cc.LibraryStaticFactory)
+	ctx.RegisterBp2BuildConfig(android.Bp2BuildConfig{
+		"migrated": android.Bp2BuildDefaultTrueRecursively,
+	})
+	ctx.RegisterForBazelConversion()
+
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp", "migrated/Android.bp"})
+	android.FailIfErrored(t, errs)
+	_, errs = ctx.ResolveDependencies(config)
+	android.FailIfErrored(t, errs)
+
+	codegenCtx := NewCodegenContext(config, *ctx.Context, Bp2Build)
+	res, err := GenerateBazelTargets(codegenCtx, false)
+	android.FailIfErrored(t, err)
+
+	android.AssertDeepEquals(t, "defaults provenance",
+		map[string]map[string][]string{
+			"foo": {
+				"bar_defaults": {"srcs"},
+				"foo_defaults": {"cflags", "include_build_directory"},
+			},
+		},
+		res.metrics.defaultsProvenance)
+	android.AssertDeepEquals(t, "defaults provenance messages",
+		[]string{`"foo": srcs from bar_defaults; cflags, include_build_directory from foo_defaults`},
+		res.metrics.defaultsProvenanceMsgs())
+}
@@ -29,6 +29,7 @@ func runPrebuiltEtcTestCase(t *testing.T, tc bp2buildTestCase) {
 }
 
 func registerPrebuiltEtcModuleTypes(ctx android.RegistrationContext) {
+	ctx.RegisterModuleType("filegroup", android.FileGroupFactory)
 }
 
 func TestPrebuiltEtcSimple(t *testing.T) {
@@ -46,10 +47,53 @@ prebuilt_etc {
 `,
 		expectedBazelTargets: []string{
 			makeBazelTarget("prebuilt_etc", "apex_tz_version", attrNameToString{
-				"filename":    `"tz_version"`,
-				"installable": `False`,
-				"src":         `"version/tz_version"`,
-				"sub_dir":     `"tz"`,
+				"filename":               `"tz_version"`,
+				"installable":            `False`,
+				"src":                    `"version/tz_version"`,
+				"sub_dir":                `"tz"`,
+				"target_compatible_with": androidOnlyTargetCompatibleWith,
+			})}})
+}
+
+func TestPrebuiltEtcWithoutSubDir(t *testing.T) {
+	runPrebuiltEtcTestCase(t, bp2buildTestCase{
+		description: "prebuilt_etc - without sub_dir",
+		filesystem:  map[string]string{},
+		blueprint: `
+prebuilt_etc {
+    name: "init.rc",
+    src: "init.rc",
+}
+`,
+		expectedBazelTargets: []string{
+			makeBazelTarget("prebuilt_etc", "init.rc", attrNameToString{
+				"src":                    `"init.rc"`,
+				"target_compatible_with": androidOnlyTargetCompatibleWith,
+			})}})
+}
+
+func TestPrebuiltEtcSrcModuleReference(t *testing.T) {
+	runPrebuiltEtcTestCase(t, bp2buildTestCase{
+		description: "prebuilt_etc - src references another module",
+		filesystem:  map[string]string{},
+		blueprint: `
+filegroup {
+    name: "tz_version_file",
+    srcs: ["version/tz_version"],
+    bazel_module: { bp2build_available: false },
+}
+
+prebuilt_etc {
+    name: "apex_tz_version",
+    src: ":tz_version_file",
+    sub_dir: "tz",
+}
+`,
+		expectedBazelTargets: []string{
+			makeBazelTarget("prebuilt_etc", "apex_tz_version", attrNameToString{
+				"src":                    `":tz_version_file"`,
+				"sub_dir":                `"tz"`,
+				"target_compatible_with": androidOnlyTargetCompatibleWith,
 			})}})
 }
 
@@ -83,7 +127,8 @@ prebuilt_etc {
         "//build/bazel/platforms/arch:arm64": "arm64",
         "//conditions:default": "version/tz_version",
     })`,
-				"sub_dir": `"tz"`,
+				"sub_dir":                `"tz"`,
+				"target_compatible_with": androidOnlyTargetCompatibleWith,
 			})}})
 }
 
@@ -125,6 +170,7 @@ prebuilt_etc {
         "//build/bazel/platforms/os_arch:linux_bionic_arm64": "darwin_or_arm64",
         "//conditions:default": "version/tz_version",
     })`,
-				"sub_dir": `"tz"`,
+				"sub_dir":                `"tz"`,
+				"target_compatible_with": androidOnlyTargetCompatibleWith,
 			})}})
 }
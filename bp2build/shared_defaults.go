@@ -0,0 +1,127 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bp2build
+
+import (
+	"fmt"
+	"strings"
+
+	"android/soong/android"
+)
+
+// Soong applies defaults before conversion, so every converted target contains the flattened
+// result. When enabled with BP2BUILD_SHARE_DEFAULTS_ATTRIBUTES, the attributes that all targets
+// using a defaults module in the same package render identically are instead emitted once, as a
+// dict named after the defaults module, e.g.
+//
+//   foo_defaults = {
+//       "copts": ["-Wall"],
+//   }
+//
+//   cc_library_static(
+//       name = "bar",
+//       copts = foo_defaults["copts"],
+//   )
+//
+// Targets in other packages can't reference the dict and keep the inlined attributes.
+
+// The module types whose users share attributes through a dict.
+var sharedAttributesDefaultsModuleTypes = map[string]bool{
+	"cc_defaults": true,
+}
+
+// shareDefaultsAttributes returns the targets of a package with the attributes shared by the users
+// of each of the given defaults modules moved into a dict, followed by the dicts.
+func shareDefaultsAttributes(targets BazelTargets, defaultsNames []string) BazelTargets {
+	// The given targets may still be used by the caller, so they are copied before being modified.
+	targets = append(BazelTargets(nil), targets...)
+
+	// The attributes of each target that already reference a dict.
+	shared := make(map[int]map[string]bool)
+
+	var dicts BazelTargets
+	for _, defaultsName := range defaultsNames {
+		var users []int
+		for i, t := range targets {
+			if !t.handcrafted && t.attrs != nil && android.InList(defaultsName, t.defaults) {
+				users = append(users, i)
+			}
+		}
+		// Sharing attributes only simplifies the package if the defaults module has several users.
+		if len(users) < 2 {
+			continue
+		}
+
+		common := make(map[string]string)
+		for name, value := range targets[users[0]].attrs {
+			isCommon := true
+			for _, i := range users {
+				if shared[i][name] || targets[i].attrs[name] != value {
+					isCommon = false
+					break
+				}
+			}
+			if isCommon {
+				common[name] = value
+			}
+		}
+		if len(common) == 0 {
+			continue
+		}
+
+		dictName := starlarkIdentifier(defaultsName)
+		for _, i := range users {
+			if shared[i] == nil {
+				shared[i] = make(map[string]bool)
+				attrs := make(map[string]string, len(targets[i].attrs))
+				for name, value := range targets[i].attrs {
+					attrs[name] = value
+				}
+				targets[i].attrs = attrs
+			}
+			for name := range common {
+				targets[i].attrs[name] = fmt.Sprintf("%s[%q]", dictName, name)
+				shared[i][name] = true
+			}
+			targets[i].content = fmt.Sprintf(bazelTarget, targets[i].ruleClass, targets[i].name,
+				propsToAttributes(targets[i].attrs))
+		}
+
+		var entries string
+		for _, name := range android.SortedStringKeys(common) {
+			entries += fmt.Sprintf("    %q: %s,\n", name, common[name])
+		}
+		dicts = append(dicts, BazelTarget{
+			name:             dictName,
+			content:          fmt.Sprintf("%s = {\n%s}", dictName, entries),
+			sharedAttributes: true,
+		})
+	}
+	return append(targets, dicts...)
+}
+
+// starlarkIdentifier converts a module name into a valid Starlark identifier.
+func starlarkIdentifier(name string) string {
+	identifier := strings.Map(func(r rune) rune {
+		if r == '_' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+	if identifier == "" || ('0' <= identifier[0] && identifier[0] <= '9') {
+		identifier = "_" + identifier
+	}
+	return identifier
+}
@@ -0,0 +1,117 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bp2build
+
+import (
+	"testing"
+)
+
+const sharedDefaultsBp = soongCcLibraryStaticPreamble + `
+cc_defaults {
+    name: "common-defaults",
+    cflags: ["-Wall", "-Werror"],
+    include_build_directory: false,
+}
+
+cc_library_static {
+    name: "foo",
+    defaults: ["common-defaults"],
+    srcs: ["foo.c"],
+}
+
+cc_library_static {
+    name: "bar",
+    defaults: ["common-defaults"],
+    srcs: ["bar.c"],
+}
+`
+
+func TestCcDefaultsSharedAttributes(t *testing.T) {
+	runCcLibraryStaticTestCase(t, bp2buildTestCase{
+		description:             "cc_defaults shared by two modules is emitted as a dict",
+		blueprint:               sharedDefaultsBp,
+		shareDefaultsAttributes: true,
+		expectedBazelTargets: []string{
+			makeBazelTarget("cc_library_static", "foo", attrNameToString{
+				"copts":  `common_defaults["copts"]`,
+				"srcs_c": `["foo.c"]`,
+			}),
+			makeBazelTarget("cc_library_static", "bar", attrNameToString{
+				"copts":  `common_defaults["copts"]`,
+				"srcs_c": `["bar.c"]`,
+			}),
+			`common_defaults = {
+    "copts": [
+        "-Wall",
+        "-Werror",
+    ],
+}`,
+		},
+	})
+}
+
+func TestCcDefaultsInlinedByDefault(t *testing.T) {
+	copts := `[
+        "-Wall",
+        "-Werror",
+    ]`
+	runCcLibraryStaticTestCase(t, bp2buildTestCase{
+		description: "cc_defaults are inlined unless sharing is enabled",
+		blueprint:   sharedDefaultsBp,
+		expectedBazelTargets: []string{
+			makeBazelTarget("cc_library_static", "foo", attrNameToString{
+				"copts":  copts,
+				"srcs_c": `["foo.c"]`,
+			}),
+			makeBazelTarget("cc_library_static", "bar", attrNameToString{
+				"copts":  copts,
+				"srcs_c": `["bar.c"]`,
+			}),
+		},
+	})
+}
+
+func TestSharedAttributesSortedFirst(t *testing.T) {
+	targets := BazelTargets{
+		BazelTarget{name: "a", ruleClass: "cc_library_static"},
+		BazelTarget{name: "z_defaults", sharedAttributes: true},
+	}
+	targets.sort()
+	if targets[0].name != "z_defaults" {
+		t.Errorf("expected shared attributes to be defined before the targets using them, got %q", targets[0].name)
+	}
+}
+
+func TestShareDefaultsAttributesDoesNotModifyTargets(t *testing.T) {
+	targets := BazelTargets{
+		BazelTarget{name: "foo", ruleClass: "cc_library_static", defaults: []string{"common-defaults"},
+			attrs: map[string]string{"copts": `["-Wall"]`}, content: "foo"},
+		BazelTarget{name: "bar", ruleClass: "cc_library_static", defaults: []string{"common-defaults"},
+			attrs: map[string]string{"copts": `["-Wall"]`}, content: "bar"},
+	}
+	shared := shareDefaultsAttributes(targets, []string{"common-defaults"})
+
+	if got := shared[0].attrs["copts"]; got != `common_defaults["copts"]` {
+		t.Errorf("expected the shared copts to reference the dict, got %q", got)
+	}
+	for _, target := range targets {
+		if got := target.attrs["copts"]; got != `["-Wall"]` {
+			t.Errorf("expected the copts of the given target %q to be unchanged, got %q", target.name, got)
+		}
+		if target.content != target.name {
+			t.Errorf("expected the content of the given target %q to be unchanged, got %q", target.name, target.content)
+		}
+	}
+}
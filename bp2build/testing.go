@@ -83,6 +83,9 @@ type bp2buildTestCase struct {
 	dir                        string
 	expectedErr                error
 	unconvertedDepsMode        unconvertedDepsMode
+	shareDefaultsAttributes    bool
+	// Environment variables of the config of the conversion.
+	env map[string]string
 }
 
 func runBp2BuildTestCase(t *testing.T, registerModuleTypes func(ctx android.RegistrationContext), tc bp2buildTestCase) {
@@ -98,7 +101,7 @@ func runBp2BuildTestCase(t *testing.T, registerModuleTypes func(ctx android.Regi
 		}
 		filesystem[f] = []byte(content)
 	}
-	config := android.TestConfig(buildDir, nil, tc.blueprint, filesystem)
+	config := android.TestConfig(buildDir, tc.env, tc.blueprint, filesystem)
 	ctx := android.NewTestContext(config)
 
 	registerModuleTypes(ctx)
@@ -126,6 +129,7 @@ func runBp2BuildTestCase(t *testing.T, registerModuleTypes func(ctx android.Regi
 	}
 	codegenCtx := NewCodegenContext(config, *ctx.Context, Bp2Build)
 	codegenCtx.unconvertedDepMode = tc.unconvertedDepsMode
+	codegenCtx.shareDefaultsAttributes = tc.shareDefaultsAttributes
 	bazelTargets, errs := generateBazelTargetsForDir(codegenCtx, checkDir)
 	if tc.expectedErr != nil {
 		if checkError(t, errs, tc.expectedErr) {
@@ -183,6 +187,9 @@ type customProps struct {
 
 	// Prop used to indicate this conversion should be 1 module -> multiple targets
 	One_to_many_prop *bool
+
+	// Prop used to give the generated target a name different from the module name
+	Target_name *string
 }
 
 type customModule struct {
@@ -314,7 +321,12 @@ func (m *customModule) ConvertWithBp2build(ctx android.TopDownMutatorContext) {
 		Rule_class: "custom",
 	}
 
-	ctx.CreateBazelTargetModule(props, android.CommonAttributes{Name: m.Name()}, attrs)
+	targetName := m.Name()
+	if m.props.Target_name != nil {
+		targetName = *m.props.Target_name
+	}
+
+	ctx.CreateBazelTargetModule(props, android.CommonAttributes{Name: targetName}, attrs)
 }
 
 // A bp2build mutator that uses load statements and creates a 1:M mapping from
@@ -365,6 +377,13 @@ func simpleModuleDoNotConvertBp2build(typ, name string) string {
 
 type attrNameToString map[string]string
 
+// androidOnlyTargetCompatibleWith is the target_compatible_with attribute of modules that are only
+// built for the android os.
+const androidOnlyTargetCompatibleWith = `select({
+        "//build/bazel/platforms/os:android": [],
+        "//conditions:default": ["@platforms//:incompatible"],
+    })`
+
 func makeBazelTarget(typ, name string, attrs attrNameToString) string {
 	attrStrings := make([]string, 0, len(attrs)+1)
 	attrStrings = append(attrStrings, fmt.Sprintf(`    name = "%s",`, name))
@@ -584,6 +584,19 @@ func (handler *ccBinaryBazelHandler) GenerateBazelBuildActions(ctx android.Modul
 }
 
 func binaryBp2build(ctx android.TopDownMutatorContext, m *Module, typ string) {
+	attrs := binaryBp2buildAttrs(ctx, m)
+
+	ctx.CreateBazelTargetModule(bazel.BazelTargetModuleProperties{
+		Rule_class:        "cc_binary",
+		Bzl_load_location: "//build/bazel/rules/cc:cc_binary.bzl",
+	},
+		android.CommonAttributes{Name: m.Name()},
+		attrs)
+}
+
+// binaryBp2buildAttrs returns the Bazel attributes of a cc binary, shared by the binary and test
+// conversions.
+func binaryBp2buildAttrs(ctx android.TopDownMutatorContext, m *Module) *binaryAttributes {
 	baseAttrs := bp2BuildParseBaseProps(ctx, m)
 	binaryLinkerAttrs := bp2buildBinaryLinkerProps(ctx, m)
 
@@ -634,12 +647,7 @@ func binaryBp2build(ctx android.TopDownMutatorContext, m *Module, typ string) {
 		sdkAttributes: bp2BuildParseSdkAttributes(m),
 	}
 
-	ctx.CreateBazelTargetModule(bazel.BazelTargetModuleProperties{
-		Rule_class:        "cc_binary",
-		Bzl_load_location: "//build/bazel/rules/cc:cc_binary.bzl",
-	},
-		android.CommonAttributes{Name: m.Name()},
-		attrs)
+	return attrs
 }
 
 // binaryAttributes contains Bazel attributes corresponding to a cc binary
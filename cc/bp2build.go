@@ -236,6 +236,8 @@ type baseAttributes struct {
 type compilerAttributes struct {
 	// Options for all languages
 	copts bazel.StringListAttribute
+	// Options removed from copts
+	coptsRemove bazel.StringListAttribute
 	// Assembly options and sources
 	asFlags bazel.StringListAttribute
 	asSrcs  bazel.LabelListAttribute
@@ -309,6 +311,7 @@ func (ca *compilerAttributes) bp2buildForAxisAndConfig(ctx android.BazelConversi
 	// incompatibilities, so we remove "-std=" flags from Cflag properties while leaving it in other
 	// cases.
 	ca.copts.SetSelectValue(axis, config, parseCommandLineFlags(props.Cflags, filterOutStdFlag))
+	ca.coptsRemove.SetSelectValue(axis, config, parseCommandLineFlags(props.Cflags_remove, nil))
 	ca.asFlags.SetSelectValue(axis, config, parseCommandLineFlags(props.Asflags, nil))
 	ca.conlyFlags.SetSelectValue(axis, config, parseCommandLineFlags(props.Conlyflags, nil))
 	ca.cppFlags.SetSelectValue(axis, config, parseCommandLineFlags(props.Cppflags, nil))
@@ -351,6 +354,29 @@ func (ca *compilerAttributes) convertProductVariables(ctx android.BazelConversio
 			}
 		}
 	}
+
+	// srcs and exclude_srcs set under the same product variable form a single select value, whose
+	// excludes are resolved along with those of the other srcs in finalize.
+	productConfigSrcs := map[android.ProductConfigProperty]bool{}
+	srcsProps := productVariableProps["Srcs"]
+	excludeSrcsProps := productVariableProps["Exclude_srcs"]
+	for productConfigProp := range srcsProps {
+		productConfigSrcs[productConfigProp] = true
+	}
+	for productConfigProp := range excludeSrcsProps {
+		productConfigSrcs[productConfigProp] = true
+	}
+	for productConfigProp := range productConfigSrcs {
+		srcs, srcsOk := srcsProps[productConfigProp].([]string)
+		excludeSrcs, excludeSrcsOk := excludeSrcsProps[productConfigProp].([]string)
+		if (srcsProps[productConfigProp] != nil && !srcsOk) ||
+			(excludeSrcsProps[productConfigProp] != nil && !excludeSrcsOk) {
+			ctx.ModuleErrorf("Could not convert product variable srcs property")
+			continue
+		}
+		ca.srcs.SetSelectValue(productConfigProp.ConfigurationAxis(), productConfigProp.SelectKey(),
+			android.BazelLabelForModuleSrcExcludes(ctx, srcs, excludeSrcs))
+	}
 }
 
 func (ca *compilerAttributes) finalize(ctx android.BazelConversionPathContext, implementationHdrs bazel.LabelListAttribute) {
@@ -374,6 +400,8 @@ func (ca *compilerAttributes) finalize(ctx android.BazelConversionPathContext, i
 
 	ca.absoluteIncludes.DeduplicateAxesFromBase()
 	ca.localIncludes.DeduplicateAxesFromBase()
+
+	ca.copts.RemoveValues(ca.coptsRemove)
 }
 
 // Parse srcs from an arch or OS's props value.
@@ -3533,7 +3533,9 @@ func (c *Module) ConvertWithBp2build(ctx android.TopDownMutatorContext) {
 	prebuilt := c.IsPrebuilt()
 	switch c.typ() {
 	case binary:
-		if !prebuilt {
+		if _, ok := c.linker.(*testBinary); ok {
+			testBinaryBp2build(ctx, c)
+		} else if !prebuilt {
 			binaryBp2build(ctx, c, ctx.ModuleType())
 		}
 	case object:
@@ -2815,6 +2815,28 @@ func TestLlndkHeaders(t *testing.T) {
 	}
 }
 
+func TestCflagsRemove(t *testing.T) {
+	ctx := testCc(t, `
+	cc_library_static {
+		name: "libfoo",
+		srcs: ["foo.c"],
+		cflags: ["-DFOO_ALL", "-DFOO_NOT_ARM64"],
+		arch: {
+			arm64: {
+				cflags_remove: ["-DFOO_NOT_ARM64"],
+			},
+		},
+	}
+	`)
+
+	arm64CFlags := ctx.ModuleForTests("libfoo", "android_arm64_armv8-a_static").Rule("cc").Args["cFlags"]
+	android.AssertStringDoesContain(t, "arm64 cflags", arm64CFlags, "-DFOO_ALL")
+	android.AssertStringDoesNotContain(t, "arm64 cflags", arm64CFlags, "-DFOO_NOT_ARM64")
+
+	armCFlags := ctx.ModuleForTests("libfoo", "android_arm_armv7-a-neon_static").Rule("cc").Args["cFlags"]
+	android.AssertStringDoesContain(t, "arm cflags", armCFlags, "-DFOO_NOT_ARM64")
+}
+
 func checkRuntimeLibs(t *testing.T, expected []string, module *Module) {
 	actual := module.Properties.AndroidMkRuntimeLibs
 	if !reflect.DeepEqual(actual, expected) {
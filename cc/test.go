@@ -22,6 +22,7 @@ import (
 	"github.com/google/blueprint/proptools"
 
 	"android/soong/android"
+	"android/soong/bazel"
 	"android/soong/tradefed"
 )
 
@@ -474,7 +475,7 @@ func (test *testBinary) install(ctx ModuleContext, file android.Path) {
 }
 
 func NewTest(hod android.HostOrDeviceSupported) *Module {
-	module, binary := newBinary(hod, false)
+	module, binary := newBinary(hod, true)
 	module.multilib = android.MultilibBoth
 	binary.baseInstaller = NewTestInstaller()
 
@@ -492,6 +493,72 @@ func NewTest(hod android.HostOrDeviceSupported) *Module {
 	return module
 }
 
+func testBinaryBp2build(ctx android.TopDownMutatorContext, m *Module) {
+	test := m.linker.(*testBinary)
+	gtest := test.testDecorator.gtest()
+	if gtest && m.Properties.Sdk_version != nil {
+		// The sdk variant links against the NDK builds of gtest, which have no Bazel equivalent.
+		return
+	}
+	attrs := binaryBp2buildAttrs(ctx, m)
+	if gtest {
+		addGtestBp2buildAttrs(ctx, test, attrs)
+	}
+
+	var data bazel.LabelListAttribute
+	for axis, configToProps := range m.GetArchVariantProperties(ctx, &TestBinaryProperties{}) {
+		for config, props := range configToProps {
+			if testProps, ok := props.(*TestBinaryProperties); ok && len(testProps.Data) > 0 {
+				data.SetSelectValue(axis, config, android.BazelLabelForModuleSrc(ctx, testProps.Data))
+			}
+		}
+	}
+
+	// Bazel tags aren't configurable, so only the test suites of the base properties are tagged.
+	var tags bazel.StringListAttribute
+	if testSuites := test.testDecorator.InstallerProperties.Test_suites; len(testSuites) > 0 {
+		tags = bazel.MakeStringListAttribute(android.FirstUniqueStrings(testSuites))
+	}
+
+	ctx.CreateBazelTargetModule(bazel.BazelTargetModuleProperties{
+		Rule_class:        "cc_test",
+		Bzl_load_location: "//build/bazel/rules/cc:cc_test.bzl",
+	},
+		android.CommonAttributes{
+			Name: m.Name(),
+			Data: data,
+			Tags: tags,
+		},
+		attrs)
+}
+
+// addGtestBp2buildAttrs adds the gtest libraries and flags that testDecorator adds to the deps and
+// flags of a test to its Bazel attributes.
+func addGtestBp2buildAttrs(ctx android.TopDownMutatorContext, test *testBinary, attrs *binaryAttributes) {
+	if Bool(test.testDecorator.LinkerProperties.Isolated) {
+		attrs.Deps.Append(bazel.MakeLabelListAttribute(
+			android.BazelLabelForModuleDeps(ctx, []string{"libgtest_isolated_main"})))
+		attrs.Dynamic_deps.Append(bazel.MakeLabelListAttribute(
+			android.BazelLabelForModuleDeps(ctx, []string{"liblog"})))
+	} else {
+		attrs.Deps.Append(bazel.MakeLabelListAttribute(
+			android.BazelLabelForModuleDeps(ctx, []string{"libgtest_main", "libgtest"})))
+	}
+
+	gtestCopts := bazel.MakeStringListAttribute([]string{"-DGTEST_HAS_STD_STRING"})
+	for osName, osCopts := range map[string][]string{
+		"android":      {"-DGTEST_OS_LINUX_ANDROID"},
+		"darwin":       {"-O0", "-g", "-DGTEST_OS_MAC"},
+		"linux_glibc":  {"-O0", "-g", "-DGTEST_OS_LINUX"},
+		"linux_musl":   {"-O0", "-g"},
+		"linux_bionic": {"-O0", "-g"},
+		"windows":      {"-O0", "-g", "-DGTEST_OS_WINDOWS"},
+	} {
+		gtestCopts.SetSelectValue(bazel.OsConfigurationAxis, osName, osCopts)
+	}
+	attrs.Copts.Append(gtestCopts)
+}
+
 type testLibrary struct {
 	*testDecorator
 	*libraryDecorator
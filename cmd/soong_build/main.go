@@ -15,6 +15,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -53,6 +54,8 @@ var (
 	docFile           string
 	bazelQueryViewDir string
 	bp2buildMarker    string
+	bp2buildOutputDir string
+	checkOnly         bool
 
 	cmdlineArgs bootstrap.Args
 )
@@ -82,8 +85,10 @@ func init() {
 	flag.StringVar(&docFile, "soong_docs", "", "build documentation file to output")
 	flag.StringVar(&bazelQueryViewDir, "bazel_queryview_dir", "", "path to the bazel queryview directory relative to --top")
 	flag.StringVar(&bp2buildMarker, "bp2build_marker", "", "If set, run bp2build, touch the specified marker file then exit")
+	flag.StringVar(&bp2buildOutputDir, "bp2build_output_dir", "", "If set, bp2build also writes the generated BUILD files to this directory relative to --top, which must be inside the out directory")
 	flag.StringVar(&cmdlineArgs.OutFile, "o", "build.ninja", "the Ninja file to output")
 	flag.BoolVar(&cmdlineArgs.EmptyNinjaFile, "empty-ninja-file", false, "write out a 0-byte ninja file")
+	flag.BoolVar(&checkOnly, "check", false, "parse and resolve all Android.bp files, report any errors, then exit without writing ninja")
 
 	// Flags that probably shouldn't be flags of soong_build but we haven't found
 	// the time to remove them yet
@@ -146,8 +151,20 @@ func runMixedModeBuild(configuration android.Config, firstCtx *android.Context,
 	// Invoke bazel commands and save results for second pass.
 	if err := configuration.BazelContext.InvokeBazel(); err != nil {
 		fmt.Fprintf(os.Stderr, "%s", err)
+		var missingErr *android.MissingCqueryResultError
+		if errors.As(err, &missingErr) {
+			fmt.Fprintf(os.Stderr, "\nThe Bazel workspace may be out of date with the Android.bp files, "+
+				"regenerate it with `m bp2build` and try again.\n")
+		}
 		os.Exit(1)
 	}
+	if configuration.IsEnvTrue("SOONG_BAZEL_DUMP_ONLY") {
+		// Only the Bazel invocation files were written; there are no results to
+		// continue the build with.
+		fmt.Fprintf(os.Stderr, "SOONG_BAZEL_DUMP_ONLY is set, wrote Bazel files to %s\n",
+			filepath.Join(configuration.SoongOutDir(), "bazel"))
+		os.Exit(0)
+	}
 	// Second pass: Full analysis, using the bazel command results. Output ninja file.
 	secondConfig, err := android.ConfigForAdditionalRun(configuration)
 	if err != nil {
@@ -163,6 +180,11 @@ func runMixedModeBuild(configuration android.Config, firstCtx *android.Context,
 	ninjaDeps = append(ninjaDeps, extraNinjaDeps...)
 	secondCtx.EventHandler.End("analyze")
 
+	if modules := android.ModulesWithFailedBazelTargets(secondCtx, secondConfig); len(modules) > 0 {
+		fmt.Fprintf(os.Stderr, "warning: Bazel failed to build the targets of %d modules, which are "+
+			"built with Soong instead: %s\n", len(modules), strings.Join(modules, ", "))
+	}
+
 	globListFiles := writeBuildGlobsNinjaFile(secondCtx, configuration.SoongOutDir(), configuration)
 	ninjaDeps = append(ninjaDeps, globListFiles...)
 
@@ -255,19 +277,21 @@ func doChosenActivity(configuration android.Config, extraNinjaDeps []string) str
 	blueprintArgs := cmdlineArgs
 
 	ctx := newContext(configuration)
+	if checkOnly {
+		// Parse and resolve all Android.bp files, running the mutators. Any errors
+		// are reported by RunBlueprint, which exits on failure.
+		stopBefore := chooseStopBefore(checkOnly, generateModuleGraphFile, generateQueryView, generateDocFile)
+		bootstrap.RunBlueprint(blueprintArgs, stopBefore, ctx.Context, configuration)
+		fmt.Fprintln(os.Stderr, "soong_build: all Android.bp files were parsed and resolved successfully")
+		os.Exit(0)
+	}
+
 	if mixedModeBuild {
+		// Start the Bazel server while the first analysis pass runs.
+		configuration.BazelContext.WarmUp()
 		runMixedModeBuild(configuration, ctx, extraNinjaDeps)
 	} else {
-		var stopBefore bootstrap.StopBefore
-		if generateModuleGraphFile {
-			stopBefore = bootstrap.StopBeforeWriteNinja
-		} else if generateQueryView {
-			stopBefore = bootstrap.StopBeforePrepareBuildActions
-		} else if generateDocFile {
-			stopBefore = bootstrap.StopBeforePrepareBuildActions
-		} else {
-			stopBefore = bootstrap.DoEverything
-		}
+		stopBefore := chooseStopBefore(checkOnly, generateModuleGraphFile, generateQueryView, generateDocFile)
 
 		ninjaDeps := bootstrap.RunBlueprint(blueprintArgs, stopBefore, ctx.Context, configuration)
 		ninjaDeps = append(ninjaDeps, extraNinjaDeps...)
@@ -306,6 +330,21 @@ func doChosenActivity(configuration android.Config, extraNinjaDeps []string) str
 	return cmdlineArgs.OutFile
 }
 
+// chooseStopBefore returns the point at which RunBlueprint should stop for the
+// requested activity. Checking only takes precedence over any other activity.
+func chooseStopBefore(checkOnly, generateModuleGraphFile, generateQueryView, generateDocFile bool) bootstrap.StopBefore {
+	if checkOnly {
+		return bootstrap.StopBeforePrepareBuildActions
+	} else if generateModuleGraphFile {
+		return bootstrap.StopBeforeWriteNinja
+	} else if generateQueryView {
+		return bootstrap.StopBeforePrepareBuildActions
+	} else if generateDocFile {
+		return bootstrap.StopBeforePrepareBuildActions
+	}
+	return bootstrap.DoEverything
+}
+
 // soong_ui dumps the available environment variables to
 // soong.environment.available . Then soong_build itself is run with an empty
 // environment so that the only way environment variables can be accessed is
@@ -534,7 +573,19 @@ func runBp2Build(configuration android.Config, extraNinjaDeps []string) {
 	// Run the code-generation phase to convert BazelTargetModules to BUILD files
 	// and print conversion metrics to the user.
 	codegenContext := bp2build.NewCodegenContext(configuration, *bp2buildCtx, bp2build.Bp2Build)
-	metrics := bp2build.Codegen(codegenContext)
+	if bp2buildOutputDir != "" {
+		err := codegenContext.SetOutputDir(shared.JoinPath(topDir, bp2buildOutputDir), topDir,
+			shared.JoinPath(topDir, outDir))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+	}
+	metrics, err := bp2build.Codegen(codegenContext)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
 
 	generatedRoot := shared.JoinPath(configuration.SoongOutDir(), "bp2build")
 	workspaceRoot := shared.JoinPath(configuration.SoongOutDir(), "workspace")
@@ -570,8 +621,12 @@ func runBp2Build(configuration android.Config, extraNinjaDeps []string) {
 
 	writeDepFile(bp2buildMarker, eventHandler, ninjaDeps)
 
-	// Create an empty bp2build marker file.
-	touch(shared.JoinPath(topDir, bp2buildMarker))
+	// Write the bp2build marker file with a summary of the conversion. Codegen exits on errors, so
+	// the marker is only written after a successful conversion.
+	if err := metrics.WriteMarker(shared.JoinPath(topDir, bp2buildMarker)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing bp2build marker '%s': %s\n", bp2buildMarker, err)
+		os.Exit(1)
+	}
 
 	eventHandler.End("bp2build")
 
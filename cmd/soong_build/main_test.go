@@ -0,0 +1,72 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/blueprint/bootstrap"
+)
+
+func TestChooseStopBefore(t *testing.T) {
+	testCases := []struct {
+		description             string
+		checkOnly               bool
+		generateModuleGraphFile bool
+		generateQueryView       bool
+		generateDocFile         bool
+		expected                bootstrap.StopBefore
+	}{
+		{
+			description: "build",
+			expected:    bootstrap.DoEverything,
+		},
+		{
+			description: "check only",
+			checkOnly:   true,
+			expected:    bootstrap.StopBeforePrepareBuildActions,
+		},
+		{
+			description:             "check only takes precedence over module graph",
+			checkOnly:               true,
+			generateModuleGraphFile: true,
+			expected:                bootstrap.StopBeforePrepareBuildActions,
+		},
+		{
+			description:             "module graph",
+			generateModuleGraphFile: true,
+			expected:                bootstrap.StopBeforeWriteNinja,
+		},
+		{
+			description:       "queryview",
+			generateQueryView: true,
+			expected:          bootstrap.StopBeforePrepareBuildActions,
+		},
+		{
+			description:     "docs",
+			generateDocFile: true,
+			expected:        bootstrap.StopBeforePrepareBuildActions,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			got := chooseStopBefore(tc.checkOnly, tc.generateModuleGraphFile, tc.generateQueryView, tc.generateDocFile)
+			if got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
@@ -254,6 +254,80 @@ func aapt2Link(ctx android.ModuleContext,
 	})
 }
 
+var aapt2LinkResourceTableRule = pctx.AndroidStaticRule("aapt2LinkResourceTable",
+	blueprint.RuleParams{
+		Command:     `${config.Aapt2Cmd} link -o $out $flags $inFlags`,
+		CommandDeps: []string{"${config.Aapt2Cmd}"},
+	},
+	"flags", "inFlags")
+
+// aapt2LinkResourceTable links the compiled resources and overlays into packageRes without
+// generating any of the other outputs of aapt2Link, to inspect the resulting resource table.
+func aapt2LinkResourceTable(ctx android.ModuleContext, packageRes android.WritablePath,
+	flags []string, deps android.Paths, compiledRes, compiledOverlay android.Paths) {
+
+	var inFlags []string
+	for _, list := range []struct {
+		inputs android.Paths
+		ext    string
+		flag   string
+	}{
+		{compiledRes, "res.list", ""},
+		{compiledOverlay, "overlay.list", "-R "},
+	} {
+		if len(list.inputs) == 0 {
+			continue
+		}
+		fileList := packageRes.ReplaceExtension(ctx, list.ext)
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        fileListToFileRule,
+			Description: "resource file list",
+			Inputs:      list.inputs,
+			Output:      fileList,
+		})
+		deps = append(deps, list.inputs...)
+		deps = append(deps, fileList)
+		inFlags = append(inFlags, list.flag+"@"+fileList.String())
+	}
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        aapt2LinkResourceTableRule,
+		Description: "aapt2 link resource table",
+		Implicits:   deps,
+		Output:      packageRes,
+		Args: map[string]string{
+			"flags":   strings.Join(flags, " "),
+			"inFlags": strings.Join(inFlags, " "),
+		},
+	})
+}
+
+var aapt2ResourceTableDiffRule = pctx.AndroidStaticRule("aapt2ResourceTableDiff",
+	blueprint.RuleParams{
+		Command: `${config.Aapt2Cmd} dump resources $base > $out.base && ` +
+			`${config.Aapt2Cmd} dump resources $in > $out.final && ` +
+			// diff exits with 1 when the tables differ, which isn't an error here.
+			`(diff -u $out.base $out.final > $out; [ $$? -le 1 ]) && ` +
+			`rm -f $out.base $out.final`,
+		CommandDeps: []string{"${config.Aapt2Cmd}"},
+	},
+	"base")
+
+// aapt2ResourceTableDiff writes a diff of the text dumps of the resource tables of the two given
+// resource packages to out.
+func aapt2ResourceTableDiff(ctx android.ModuleContext, out android.WritablePath, base, final android.Path) {
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        aapt2ResourceTableDiffRule,
+		Description: "resource table diff",
+		Input:       final,
+		Implicit:    base,
+		Output:      out,
+		Args: map[string]string{
+			"base": base.String(),
+		},
+	})
+}
+
 var aapt2ConvertRule = pctx.AndroidStaticRule("aapt2Convert",
 	blueprint.RuleParams{
 		Command:     `${config.Aapt2Cmd} convert --output-format proto $in -o $out`,
@@ -88,6 +88,11 @@ type aaptProperties struct {
 	// do not include AndroidManifest from dependent libraries
 	Dont_merge_manifests *bool
 
+	// If true, write a diff of the text dumps of the resource table linked without the product
+	// resource overlays and of the final resource table to a file available through the
+	// ".resource-diff" output tag, to review the changes made by the overlays.
+	Write_resource_diff *bool
+
 	// true if RRO is enforced for any of the dependent modules
 	RROEnforcedForDependent bool `blueprint:"mutated"`
 }
@@ -109,15 +114,32 @@ type aapt struct {
 	useEmbeddedDex          bool
 	usesNonSdkApis          bool
 	hasNoCode               bool
+	manifestMergerArgs      []string
 	LoggingParent           string
 	resourceFiles           android.Paths
 
+	// If true, the manifest merger writes its report to manifestMergerReport.
+	writeManifestMergerReport bool
+	manifestMergerReport      android.Path
+
+	// The diff of the resource tables without and with the product resource overlays, if
+	// write_resource_diff is set.
+	resourceDiff android.Path
+
 	splitNames []string
 	splits     []split
 
 	aaptProperties aaptProperties
 }
 
+// resourceDiffOutputFiles returns the resource diff for the ".resource-diff" output tag.
+func (a *aapt) resourceDiffOutputFiles(tag string) (android.Paths, error) {
+	if a.resourceDiff != nil {
+		return android.Paths{a.resourceDiff}, nil
+	}
+	return nil, fmt.Errorf("%q was requested, but the module does not write it, set write_resource_diff: true", tag)
+}
+
 type split struct {
 	name   string
 	suffix string
@@ -296,8 +318,15 @@ func (a *aapt) buildActions(ctx android.ModuleContext, sdkContext android.SdkCon
 	a.transitiveManifestPaths = append(android.Paths{manifestPath}, additionalManifests...)
 	a.transitiveManifestPaths = append(a.transitiveManifestPaths, transitiveStaticLibManifests...)
 
-	if len(a.transitiveManifestPaths) > 1 && !Bool(a.aaptProperties.Dont_merge_manifests) {
-		a.mergedManifestFile = manifestMerger(ctx, a.transitiveManifestPaths[0], a.transitiveManifestPaths[1:], a.isLibrary)
+	needsMerge := len(a.transitiveManifestPaths) > 1 || len(a.manifestMergerArgs) > 0
+	if needsMerge && !Bool(a.aaptProperties.Dont_merge_manifests) {
+		var report android.WritablePath
+		if a.writeManifestMergerReport {
+			report = android.PathForModuleOut(ctx, "manifest_merger", "manifest-merger-report.txt")
+			a.manifestMergerReport = report
+		}
+		a.mergedManifestFile = manifestMerger(ctx, a.transitiveManifestPaths[0], a.transitiveManifestPaths[1:],
+			a.isLibrary, a.manifestMergerArgs, report)
 		if !a.isLibrary {
 			// Only use the merged manifest for applications.  For libraries, the transitive closure of manifests
 			// will be propagated to the final application and merged there.  The merged manifest for libraries is
@@ -362,10 +391,20 @@ func (a *aapt) buildActions(ctx android.ModuleContext, sdkContext android.SdkCon
 		}
 	}
 
+	// The resources without the product resource overlays, which are the base of the resource diff.
+	baseCompiledOverlay := android.CopyOfPaths(compiledOverlay)
+
 	for _, dir := range overlayDirs {
 		compiledOverlay = append(compiledOverlay, aapt2Compile(ctx, dir.dir, dir.files, compileFlags).Paths()...)
 	}
 
+	var baseRes android.WritablePath
+	if Bool(a.aaptProperties.Write_resource_diff) {
+		// Link the base resource table before the split flags are added, it has no splits.
+		baseRes = android.PathForModuleOut(ctx, "resource_diff", "base-res.apk")
+		aapt2LinkResourceTable(ctx, baseRes, linkFlags, linkDeps, compiledRes, baseCompiledOverlay)
+	}
+
 	var splitPackages android.WritablePaths
 	var splits []split
 
@@ -384,6 +423,12 @@ func (a *aapt) buildActions(ctx android.ModuleContext, sdkContext android.SdkCon
 	aapt2Link(ctx, packageRes, srcJar, proguardOptionsFile, rTxt, extraPackages,
 		linkFlags, linkDeps, compiledRes, compiledOverlay, assetPackages, splitPackages)
 
+	if baseRes != nil {
+		resourceDiff := android.PathForModuleOut(ctx, "resource_diff", "resources.diff")
+		aapt2ResourceTableDiff(ctx, resourceDiff, baseRes, packageRes)
+		a.resourceDiff = resourceDiff
+	}
+
 	// Extract assets from the resource package output so that they can be used later in aapt2link
 	// for modules that depend on this one.
 	if android.PrefixInList(linkFlags, "-A ") || len(assetPackages) > 0 {
@@ -504,6 +549,8 @@ func (a *AndroidLibrary) OutputFiles(tag string) (android.Paths, error) {
 	switch tag {
 	case ".aar":
 		return []android.Path{a.aarFile}, nil
+	case ".resource-diff":
+		return a.resourceDiffOutputFiles(tag)
 	default:
 		return a.Library.OutputFiles(tag)
 	}
@@ -560,6 +607,8 @@ func (a *AndroidLibrary) GenerateAndroidBuildActions(ctx android.ModuleContext)
 
 	a.exportedProguardFlagFiles = append(a.exportedProguardFlagFiles,
 		android.PathsForModuleSrc(ctx, a.dexProperties.Optimize.Proguard_flags_files)...)
+	a.exportedProguardFlagFiles = append(a.exportedProguardFlagFiles,
+		android.PathsForModuleSrc(ctx, a.dexProperties.Export_proguard_flags_files)...)
 	ctx.VisitDirectDeps(func(m android.Module) {
 		if lib, ok := m.(AndroidLibraryDependency); ok && ctx.OtherModuleDependencyTag(m) == staticLibTag {
 			a.exportedProguardFlagFiles = append(a.exportedProguardFlagFiles, lib.ExportedProguardFlagFiles()...)
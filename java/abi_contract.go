@@ -0,0 +1,46 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"android/soong/android"
+	"android/soong/java/config"
+)
+
+type abiContractProperties struct {
+	// A file containing the frozen ABI of this library, i.e. the declarations of its public and
+	// protected classes and members. The ABI extracted from the header jar of the library must
+	// match it, unlike the source API checked by droidstubs this also covers changes that are only
+	// visible in the compiled classes.
+	Abi_contract *string `android:"path"`
+}
+
+// buildAbiContractCheck creates a rule that extracts the ABI of the classes in the given header jar
+// and compares it with the given contract, failing if they differ. It returns the path to the
+// extracted ABI, which can be copied over the contract when the change is intended.
+func buildAbiContractCheck(ctx android.ModuleContext, headerJar, contract android.Path) android.WritablePath {
+	abi := android.PathForModuleOut(ctx, "abi_contract", "abi.txt")
+
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().
+		BuiltTool("check_abi_contract").
+		FlagWithInput("--javap ", config.JavapCmd(ctx)).
+		FlagWithInput("--jar ", headerJar).
+		FlagWithInput("--contract ", contract).
+		FlagWithOutput("--output ", abi)
+	rule.Build("abi_contract", "check abi contract")
+
+	return abi
+}
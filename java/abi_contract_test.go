@@ -0,0 +1,57 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestAbiContract(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		android.FixtureMergeMockFs(android.MockFS{
+			"foo/abi.txt": nil,
+		}),
+	).RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			abi_contract: "foo/abi.txt",
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["b.java"],
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	check := foo.Rule("abi_contract")
+	android.AssertStringDoesContain(t, "command", check.RuleParams.Command, "check_abi_contract")
+	android.AssertStringDoesContain(t, "command", check.RuleParams.Command, "--contract foo/abi.txt")
+	android.AssertStringListContains(t, "inputs", check.Implicits.Strings(), "foo/abi.txt")
+	// The ABI is extracted from the turbine header jar.
+	android.AssertStringListContains(t, "inputs", check.Implicits.Strings(),
+		"out/soong/.intermediates/foo/android_common/turbine-combined/foo.jar")
+	android.AssertPathsRelativeToTopEquals(t, "outputs", []string{
+		"out/soong/.intermediates/foo/android_common/abi_contract/abi.txt",
+	}, check.Outputs.Paths())
+
+	if rule := result.ModuleForTests("bar", "android_common").MaybeRule("abi_contract").Rule; rule != nil {
+		t.Errorf("expected no abi contract check for bar")
+	}
+}
@@ -163,25 +163,35 @@ func ManifestFixer(ctx android.ModuleContext, manifest android.Path,
 	return fixedManifest.WithoutRel()
 }
 
+// manifestMerger merges the given manifests. If report is not nil, the merger also writes its
+// report, explaining the merge decisions, to it.
 func manifestMerger(ctx android.ModuleContext, manifest android.Path, staticLibManifests android.Paths,
-	isLibrary bool) android.Path {
+	isLibrary bool, extraArgs []string, report android.WritablePath) android.Path {
 
-	var args string
+	var args []string
 	if !isLibrary {
 		// Follow Gradle's behavior, only pass --remove-tools-declarations when merging app manifests.
-		args = "--remove-tools-declarations"
+		args = append(args, "--remove-tools-declarations")
+	}
+	args = append(args, extraArgs...)
+
+	var implicitOutputs android.WritablePaths
+	if report != nil {
+		args = append(args, "--report", report.String())
+		implicitOutputs = append(implicitOutputs, report)
 	}
 
 	mergedManifest := android.PathForModuleOut(ctx, "manifest_merger", "AndroidManifest.xml")
 	ctx.Build(pctx, android.BuildParams{
-		Rule:        manifestMergerRule,
-		Description: "merge manifest",
-		Input:       manifest,
-		Implicits:   staticLibManifests,
-		Output:      mergedManifest,
+		Rule:            manifestMergerRule,
+		Description:     "merge manifest",
+		Input:           manifest,
+		Implicits:       staticLibManifests,
+		Output:          mergedManifest,
+		ImplicitOutputs: implicitOutputs,
 		Args: map[string]string{
 			"libs": android.JoinWithPrefix(staticLibManifests.Strings(), "--libs "),
-			"args": args,
+			"args": strings.Join(args, " "),
 		},
 	})
 
@@ -95,6 +95,8 @@ func (library *Library) AndroidMkEntries() []android.AndroidMkEntries {
 
 					if library.installFile == nil {
 						entries.SetBoolIfTrue("LOCAL_UNINSTALLABLE_MODULE", true)
+					} else if library.properties.Install_path != nil {
+						entries.SetPath("LOCAL_MODULE_PATH", library.installDir)
 					}
 					if library.dexJarFile.IsSet() {
 						entries.SetPath("LOCAL_SOONG_DEX_JAR", library.dexJarFile.Path())
@@ -147,6 +149,10 @@ func (j *Test) AndroidMkEntries() []android.AndroidMkEntries {
 	entriesList := j.Library.AndroidMkEntries()
 	entries := &entriesList[0]
 	entries.ExtraEntries = append(entries.ExtraEntries, func(ctx android.AndroidMkExtraEntriesContext, entries *android.AndroidMkEntries) {
+		if j.installFile == nil {
+			// The test is built but not installed, so it is not part of any test suite.
+			return
+		}
 		testSuiteComponent(entries, j.testProperties.Test_suites, Bool(j.testProperties.Per_testcase_directory))
 		if j.testConfig != nil {
 			entries.SetPath("LOCAL_FULL_TEST_CONFIG", j.testConfig)
@@ -213,6 +219,25 @@ func (prebuilt *Import) AndroidMkEntries() []android.AndroidMkEntries {
 	}}
 }
 
+func (j *JavaTestImport) AndroidMkEntries() []android.AndroidMkEntries {
+	entriesList := j.Import.AndroidMkEntries()
+	entries := &entriesList[len(entriesList)-1]
+	if entries.Disabled {
+		return entriesList
+	}
+	entries.ExtraEntries = append(entries.ExtraEntries, func(ctx android.AndroidMkExtraEntriesContext, entries *android.AndroidMkEntries) {
+		testSuiteComponent(entries, j.prebuiltTestProperties.Test_suites, false)
+		if j.testConfig != nil {
+			entries.SetPath("LOCAL_FULL_TEST_CONFIG", j.testConfig)
+		}
+		if !BoolDefault(j.prebuiltTestProperties.Auto_gen_config, true) {
+			entries.SetString("LOCAL_DISABLE_AUTO_GENERATE_TEST_CONFIG", "true")
+		}
+	})
+
+	return entriesList
+}
+
 func (prebuilt *DexImport) AndroidMkEntries() []android.AndroidMkEntries {
 	if prebuilt.hideApexVariantFromMake {
 		return []android.AndroidMkEntries{android.AndroidMkEntries{
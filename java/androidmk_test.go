@@ -206,3 +206,60 @@ func TestAndroidTestHelperApp_LocalDisableTestConfig(t *testing.T) {
 		t.Errorf("Unexpected flag value - expected: %q, actual: %q", expected, actual)
 	}
 }
+
+func TestLibraryInstallPath(t *testing.T) {
+	result := PrepareForTestWithJavaDefaultModules.RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			installable: true,
+			install_path: "framework/foo",
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["b.java"],
+			installable: true,
+			vendor: true,
+			install_path: "bar",
+		}
+
+		java_library {
+			name: "baz",
+			srcs: ["c.java"],
+			installable: true,
+		}
+	`)
+
+	for _, tc := range []struct {
+		name         string
+		expectedPath []string
+	}{
+		{"foo", []string{"out/target/product/test_device/system/framework/foo"}},
+		{"bar", []string{"out/target/product/test_device/vendor/bar"}},
+		// The default install location doesn't need to be set.
+		{"baz", nil},
+	} {
+		m := result.ModuleForTests(tc.name, "android_common")
+		entries := android.AndroidMkEntriesForTest(t, result.TestContext, m.Module())[0]
+		android.AssertStringPathsRelativeToTopEquals(t, tc.name+" LOCAL_MODULE_PATH", result.Config,
+			tc.expectedPath, entries.EntryMap["LOCAL_MODULE_PATH"])
+	}
+
+	foo := result.ModuleForTests("foo", "android_common").Module().(*Library)
+	android.AssertPathRelativeToTopEquals(t, "foo install file",
+		"out/target/product/test_device/system/framework/foo/foo.jar", foo.installFile)
+}
+
+func TestLibraryInstallPathErrors(t *testing.T) {
+	for _, installPath := range []string{"/system/framework", "../vendor", "framework/../..", ""} {
+		testJavaError(t, `install_path: must be a clean relative path`, `
+			java_library {
+				name: "foo",
+				srcs: ["a.java"],
+				installable: true,
+				install_path: "`+installPath+`",
+			}
+		`)
+	}
+}
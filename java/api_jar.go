@@ -0,0 +1,83 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+// This file contains support for building API jars, which contain only the public and protected
+// members of a module's classes with stubbed method bodies. Unlike the turbine header jars they
+// do not include package-private or private members, so they can be distributed as compile-only
+// SDK artifacts.
+
+import (
+	"android/soong/android"
+)
+
+// buildApiJar generates stubs for the module's sources with metalava and compiles them into a jar.
+func (j *Module) buildApiJar(ctx android.ModuleContext, jarName string, srcFiles, srcJars android.Paths,
+	flags javaBuilderFlags) android.Path {
+
+	if len(srcFiles.FilterByExt(".kt")) > 0 {
+		ctx.PropertyErrorf("api_jar", "is not supported for modules with kotlin sources")
+		return nil
+	}
+	javaSrcFiles := srcFiles.FilterByExt(".java")
+	if len(javaSrcFiles) == 0 && len(srcJars) == 0 {
+		ctx.PropertyErrorf("api_jar", "requires the module to have java sources")
+		return nil
+	}
+
+	// metalava needs the libraries of the system modules on its bootclasspath.
+	bootClasspath := append(classpath(nil), flags.bootClasspath...)
+	if flags.systemModules != nil {
+		bootClasspath = append(bootClasspath, flags.systemModules.headerJars...)
+	}
+	apiClasspath := append(append(classpath(nil), flags.java9Classpath...), flags.classpath...)
+
+	stubsDir := android.PathForModuleOut(ctx, "api_jar", "stubs")
+	stubsSrcJar := android.PathForModuleOut(ctx, "api_jar", ctx.ModuleName()+"-stubs.srcjar")
+
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().Text("rm -rf").Text(stubsDir.String())
+	rule.Command().Text("mkdir -p").Text(stubsDir.String())
+
+	srcJarList := zipSyncCmd(ctx, rule, android.PathForModuleOut(ctx, "api_jar", "srcjars"), srcJars)
+
+	homeDir := android.PathForModuleOut(ctx, "api_jar", "home")
+	cmd := metalavaCmd(ctx, rule, flags.javaVersion, javaSrcFiles, srcJarList,
+		bootClasspath, apiClasspath, homeDir)
+	// metalava only writes public and protected members into the stubs by default.
+	cmd.FlagWithArg("--stubs ", stubsDir.String()).
+		Flag("--exclude-documentation-from-stubs")
+
+	rule.Command().
+		BuiltTool("soong_zip").
+		Flag("-write_if_changed").
+		Flag("-jar").
+		FlagWithOutput("-o ", stubsSrcJar).
+		FlagWithArg("-C ", stubsDir.String()).
+		FlagWithArg("-D ", stubsDir.String())
+
+	rule.Restat()
+	rule.Build("api_jar_stubs", "api jar stubs")
+
+	// The stubs have already been through annotation processing as part of the module's sources.
+	flags.processorPath = nil
+	flags.processors = nil
+
+	apiJar := android.PathForModuleOut(ctx, "api_jar", jarName)
+	transformJavaToClasses(ctx, apiJar, -1, nil, android.Paths{stubsSrcJar}, flags, nil,
+		"api_jar", "javac api jar")
+
+	return apiJar
+}
@@ -0,0 +1,89 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestApiJar(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		android.FixtureMergeMockFs(android.MockFS{
+			"foo/Foo.java": []byte("package foo; public class Foo { public void api() {} private void impl() {} }"),
+		}),
+	).RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["foo/Foo.java"],
+			api_jar: true,
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+
+	// The full jar is compiled from the sources, including private members.
+	javac := foo.Output("javac/foo.jar")
+	android.AssertPathsRelativeToTopEquals(t, "full jar sources", []string{"foo/Foo.java"}, javac.Inputs)
+
+	// The api jar is compiled only from the metalava stubs, which leave out private members.
+	stubs := foo.Rule("api_jar_stubs")
+	android.AssertStringDoesContain(t, "stubs command", stubs.RuleParams.Command,
+		"--stubs out/soong/.intermediates/foo/android_common/api_jar/stubs")
+	android.AssertStringListContains(t, "stubs inputs", stubs.Inputs.Strings(), "foo/Foo.java")
+
+	apiJavac := foo.Output("api_jar/foo.jar")
+	if len(apiJavac.Inputs) > 0 {
+		t.Errorf("expected the api jar to be compiled only from the stubs, got %q", apiJavac.Inputs)
+	}
+	android.AssertStringEquals(t, "api jar srcjars",
+		"out/soong/.intermediates/foo/android_common/api_jar/foo-stubs.srcjar", apiJavac.Args["srcJars"])
+
+	outputs, err := foo.Module().(*Library).OutputFiles(".apijar")
+	android.AssertDeepEquals(t, "error", nil, err)
+	android.AssertPathsRelativeToTopEquals(t, ".apijar output",
+		[]string{"out/soong/.intermediates/foo/android_common/api_jar/foo.jar"}, outputs)
+}
+
+func TestApiJarErrors(t *testing.T) {
+	prepareForJavaTest.
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`api_jar: requires the module to have java sources`)).
+		RunTestWithBp(t, `
+			java_library {
+				name: "foo",
+				static_libs: ["bar"],
+				api_jar: true,
+			}
+
+			java_library {
+				name: "bar",
+				srcs: ["a.java"],
+			}
+		`)
+
+	result := prepareForJavaTest.RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+		}
+	`)
+	_, err := result.ModuleForTests("foo", "android_common").Module().(*Library).OutputFiles(".apijar")
+	if err == nil {
+		t.Errorf("expected an error requesting .apijar without api_jar: true")
+	}
+}
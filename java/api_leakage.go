@@ -0,0 +1,50 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"android/soong/android"
+)
+
+type apiLeakageProperties struct {
+	// If true, check that no public API member of this library refers to a hidden type in its
+	// signature. Which members and types are hidden is determined from the monolithic
+	// hiddenapi-flags.csv file, so the library must be part of the bootclasspath.
+	Check_api_leakage *bool
+}
+
+// buildApiLeakageCheck creates a rule that checks the public API members of the classes in the
+// given jar against the monolithic hidden API flags, failing if any refers to a hidden type. It
+// returns the path to the report produced by the check.
+func buildApiLeakageCheck(ctx android.ModuleContext, jar android.Path) android.WritablePath {
+	classes := android.PathForModuleOut(ctx, "api_leakage", "classes.txt")
+	report := android.PathForModuleOut(ctx, "api_leakage", "report.txt")
+	flags := hiddenAPISingletonPaths(ctx).flags
+
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().
+		Text("zipinfo -1").Input(jar).Text("'*.class'").
+		Text(">").Output(classes).
+		// zipinfo fails when the jar contains no classes.
+		Text("|| true")
+	rule.Command().
+		BuiltTool("check_api_leakage").
+		FlagWithInput("--flags ", flags).
+		FlagWithInput("--classes ", classes).
+		FlagWithOutput("--output ", report)
+	rule.Build("api_leakage", "check for hidden types in the public API")
+
+	return report
+}
@@ -0,0 +1,58 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestCheckApiLeakage(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		android.FixtureMergeMockFs(android.MockFS{
+			"foo/Public.java": []byte("package foo; public class Public { public void leak(Hidden h) {} }"),
+			"foo/Hidden.java": []byte("package foo; /** @hide */ public class Hidden {}"),
+		}),
+	).RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["foo/Public.java", "foo/Hidden.java"],
+			check_api_leakage: true,
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["a.java"],
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	check := foo.Rule("api_leakage")
+	android.AssertStringDoesContain(t, "command", check.RuleParams.Command, "check_api_leakage")
+	android.AssertStringDoesContain(t, "command", check.RuleParams.Command,
+		"--flags out/soong/hiddenapi/hiddenapi-flags.csv")
+	android.AssertStringListContains(t, "inputs", check.Implicits.Strings(),
+		"out/soong/.intermediates/foo/android_common/javac/foo.jar")
+	android.AssertPathsRelativeToTopEquals(t, "outputs", []string{
+		"out/soong/.intermediates/foo/android_common/api_leakage/classes.txt",
+		"out/soong/.intermediates/foo/android_common/api_leakage/report.txt",
+	}, check.Outputs.Paths())
+
+	if rule := result.ModuleForTests("bar", "android_common").MaybeRule("api_leakage").Rule; rule != nil {
+		t.Errorf("expected no api leakage check for bar")
+	}
+}
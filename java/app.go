@@ -18,7 +18,9 @@ package java
 // related module types, including their override variants.
 
 import (
+	"fmt"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/google/blueprint"
@@ -51,6 +53,20 @@ type appProperties struct {
 	// Names of extra android_app_certificate modules to sign the apk with in the form ":module".
 	Additional_certificates []string
 
+	// If true, write the report of the manifest merger, which explains how the manifests of the app
+	// and of its libraries were merged, to a file available through the ".manifest-report" output
+	// tag. Only applies when manifests are merged.
+	Write_manifest_merger_report *bool
+
+	Zipalign struct {
+		// If true, align the uncompressed entries of the APK with zipalign before signing it.
+		Enabled *bool
+
+		// The page size in bytes that uncompressed native libraries are aligned to, e.g. 4096 or
+		// 16384. Must be a power of two of at least 4096. Defaults to 4096.
+		Page_size *int64
+	}
+
 	// If set, create package-export.apk, which other packages can
 	// use to get PRODUCT-agnostic resource data like IDs and type definitions.
 	Export_package_resources *bool
@@ -114,6 +130,26 @@ type appProperties struct {
 	// Prefer using other specific properties if build behaviour must be changed; avoid using this
 	// flag for anything but neverallow rules (unless the behaviour change is invisible to owners).
 	Updatable *bool
+
+	// Values passed to the manifest merger that override the corresponding attributes in the
+	// merged AndroidManifest.xml. Can't be combined with dont_merge_manifests: true.
+	Manifest_values struct {
+		// the minSdkVersion to set in the merged manifest.
+		Min_sdk_version *string
+
+		// the targetSdkVersion to set in the merged manifest.
+		Target_sdk_version *string
+
+		// the versionCode to set in the merged manifest. Must be an integer.
+		Version_code *string
+
+		// the versionName to set in the merged manifest.
+		Version_name *string
+
+		// the package to set in the merged manifest. Also substituted for ${applicationId}
+		// placeholders in the manifests.
+		Application_id *string
+	}
 }
 
 // android_app properties that can be overridden by override_android_app
@@ -154,6 +190,9 @@ type AndroidApp struct {
 
 	bundleFile android.Path
 
+	// The <uses-library> tags of the libraries used by the app, for the .uses-library-fragment tag.
+	usesLibraryFragment android.Path
+
 	// the install APK name is normally the same as the module name, but can be overridden with PRODUCT_PACKAGE_NAME_OVERRIDES.
 	installApkName string
 
@@ -378,6 +417,39 @@ func (a *AndroidApp) renameResourcesPackage() bool {
 	return proptools.BoolDefault(a.overridableAppProperties.Rename_resources_package, true)
 }
 
+// manifestValuesMergerArgs returns the manifest merger arguments that override attributes of the
+// merged manifest with the values from the manifest_values property.
+func (a *AndroidApp) manifestValuesMergerArgs(ctx android.ModuleContext) []string {
+	values := a.appProperties.Manifest_values
+	var args []string
+	addProperty := func(name string, value *string) {
+		if value != nil {
+			args = append(args, "--property", proptools.ShellEscape(name+"="+*value))
+		}
+	}
+
+	if values.Version_code != nil {
+		if _, err := strconv.Atoi(*values.Version_code); err != nil {
+			ctx.PropertyErrorf("manifest_values.version_code", "must be an integer, got %q", *values.Version_code)
+		}
+	}
+
+	addProperty("MIN_SDK_VERSION", values.Min_sdk_version)
+	addProperty("TARGET_SDK_VERSION", values.Target_sdk_version)
+	addProperty("VERSION_CODE", values.Version_code)
+	addProperty("VERSION_NAME", values.Version_name)
+	addProperty("PACKAGE", values.Application_id)
+	if values.Application_id != nil {
+		args = append(args, "--placeholder", proptools.ShellEscape("applicationId="+*values.Application_id))
+	}
+
+	if len(args) > 0 && Bool(a.aaptProperties.Dont_merge_manifests) {
+		ctx.PropertyErrorf("manifest_values", "cannot be set with dont_merge_manifests: true, "+
+			"as the values are applied by the manifest merger")
+	}
+	return args
+}
+
 func (a *AndroidApp) aaptBuildActions(ctx android.ModuleContext) {
 	usePlatformAPI := proptools.Bool(a.Module.deviceProperties.Platform_apis)
 	if ctx.Module().(android.SdkContext).SdkVersion(ctx).Kind == android.SdkModule {
@@ -388,6 +460,9 @@ func (a *AndroidApp) aaptBuildActions(ctx android.ModuleContext) {
 	// Ask manifest_fixer to add or update the application element indicating this app has no code.
 	a.aapt.hasNoCode = !a.hasCode(ctx)
 
+	a.aapt.manifestMergerArgs = a.manifestValuesMergerArgs(ctx)
+	a.aapt.writeManifestMergerReport = Bool(a.appProperties.Write_manifest_merger_report)
+
 	aaptLinkFlags := []string{}
 
 	// Add TARGET_AAPT_CHARACTERISTICS values to AAPT link flags if they exist and --product flags were not provided.
@@ -432,9 +507,16 @@ func (a *AndroidApp) aaptBuildActions(ctx android.ModuleContext) {
 func (a *AndroidApp) proguardBuildActions(ctx android.ModuleContext) {
 	var staticLibProguardFlagFiles android.Paths
 	ctx.VisitDirectDeps(func(m android.Module) {
-		if lib, ok := m.(AndroidLibraryDependency); ok && ctx.OtherModuleDependencyTag(m) == staticLibTag {
+		if ctx.OtherModuleDependencyTag(m) != staticLibTag {
+			return
+		}
+		if lib, ok := m.(AndroidLibraryDependency); ok {
 			staticLibProguardFlagFiles = append(staticLibProguardFlagFiles, lib.ExportedProguardFlagFiles()...)
 		}
+		if ctx.OtherModuleHasProvider(m, JavaInfoProvider) {
+			dep := ctx.OtherModuleProvider(m, JavaInfoProvider).(JavaInfo)
+			staticLibProguardFlagFiles = append(staticLibProguardFlagFiles, dep.ExportedProguardFlagFiles...)
+		}
 	})
 
 	staticLibProguardFlagFiles = android.FirstUniquePaths(staticLibProguardFlagFiles)
@@ -561,6 +643,20 @@ func (a *AndroidApp) InstallApkName() string {
 	return a.installApkName
 }
 
+// zipalignPageSize returns the page size to zipalign the APK with, or 0 if it shouldn't be
+// zipaligned.
+func (a *AndroidApp) zipalignPageSize(ctx android.ModuleContext) int64 {
+	if !Bool(a.appProperties.Zipalign.Enabled) {
+		return 0
+	}
+	pageSize := proptools.Int64Default(a.appProperties.Zipalign.Page_size, 4096)
+	if pageSize < 4096 || pageSize&(pageSize-1) != 0 {
+		ctx.PropertyErrorf("zipalign.page_size", "must be a power of two of at least 4096, got %d", pageSize)
+		return 0
+	}
+	return pageSize
+}
+
 func (a *AndroidApp) generateAndroidBuildActions(ctx android.ModuleContext) {
 	var apkDeps android.Paths
 
@@ -614,6 +710,7 @@ func (a *AndroidApp) generateAndroidBuildActions(ctx android.ModuleContext) {
 	for _, usesLib := range optionalUsesLibs {
 		a.usesLibrary.addLib(usesLib, true)
 	}
+	a.usesLibraryFragment = a.usesLibrary.writeUsesLibraryFragment(ctx)
 
 	// Check that the <uses-library> list is coherent with the manifest.
 	if a.usesLibrary.enforceUsesLibraries() {
@@ -651,7 +748,8 @@ func (a *AndroidApp) generateAndroidBuildActions(ctx android.ModuleContext) {
 	if lineage := String(a.overridableAppProperties.Lineage); lineage != "" {
 		lineageFile = android.PathForModuleSrc(ctx, lineage)
 	}
-	CreateAndSignAppPackage(ctx, packageFile, a.exportPackage, jniJarFile, dexJarFile, certificates, apkDeps, v4SignatureFile, lineageFile)
+	zipalignPageSize := a.zipalignPageSize(ctx)
+	CreateAndSignAppPackage(ctx, packageFile, a.exportPackage, jniJarFile, dexJarFile, certificates, apkDeps, v4SignatureFile, lineageFile, zipalignPageSize)
 	a.outputFile = packageFile
 	if v4SigningRequested {
 		a.extraOutputFiles = append(a.extraOutputFiles, v4SignatureFile)
@@ -663,7 +761,7 @@ func (a *AndroidApp) generateAndroidBuildActions(ctx android.ModuleContext) {
 		if v4SigningRequested {
 			v4SignatureFile = android.PathForModuleOut(ctx, a.installApkName+"_"+split.suffix+".apk.idsig")
 		}
-		CreateAndSignAppPackage(ctx, packageFile, split.path, nil, nil, certificates, apkDeps, v4SignatureFile, lineageFile)
+		CreateAndSignAppPackage(ctx, packageFile, split.path, nil, nil, certificates, apkDeps, v4SignatureFile, lineageFile, zipalignPageSize)
 		a.extraOutputFiles = append(a.extraOutputFiles, packageFile)
 		if v4SigningRequested {
 			a.extraOutputFiles = append(a.extraOutputFiles, v4SignatureFile)
@@ -850,6 +948,15 @@ func (a *AndroidApp) OutputFiles(tag string) (android.Paths, error) {
 		return []android.Path{a.aaptSrcJar}, nil
 	case ".export-package.apk":
 		return []android.Path{a.exportPackage}, nil
+	case ".manifest-report":
+		if a.manifestMergerReport != nil {
+			return []android.Path{a.manifestMergerReport}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but the module does not write it, set write_manifest_merger_report: true", tag)
+	case ".resource-diff":
+		return a.resourceDiffOutputFiles(tag)
+	case ".uses-library-fragment":
+		return []android.Path{a.usesLibraryFragment}, nil
 	}
 	return a.Library.OutputFiles(tag)
 }
@@ -1363,6 +1470,21 @@ func (u *usesLibrary) verifyUsesLibraries(ctx android.ModuleContext, inputFile a
 	return outputFile
 }
 
+// writeUsesLibraryFragment writes a manifest fragment with the <uses-library> tags of the libraries
+// known to the build system, to inspect them or merge them into a manifest outside of the build.
+func (u *usesLibrary) writeUsesLibraryFragment(ctx android.ModuleContext) android.Path {
+	var tags []string
+	for _, lib := range u.usesLibraryProperties.Uses_libs {
+		tags = append(tags, fmt.Sprintf(`<uses-library android:name="%s" />`, lib))
+	}
+	for _, lib := range u.usesLibraryProperties.Optional_uses_libs {
+		tags = append(tags, fmt.Sprintf(`<uses-library android:name="%s" android:required="false" />`, lib))
+	}
+	fragment := android.PathForModuleOut(ctx, "uses-library", "uses-library-fragment.xml")
+	android.WriteFileRule(ctx, fragment, strings.Join(tags, "\n"))
+	return fragment
+}
+
 // verifyUsesLibrariesManifest checks the <uses-library> tags in an AndroidManifest.xml against
 // the build system and returns the path to a copy of the manifest.
 func (u *usesLibrary) verifyUsesLibrariesManifest(ctx android.ModuleContext, manifest android.Path) android.Path {
@@ -1406,6 +1528,22 @@ func androidAppCertificateBp2Build(ctx android.TopDownMutatorContext, module *An
 	ctx.CreateBazelTargetModule(props, android.CommonAttributes{Name: module.Name()}, attrs)
 }
 
+// bp2buildCertificate returns the label of the android_app_certificate module referenced by the
+// certificate property of an app, or otherwise the name of the certificate in the default
+// certificate directory, e.g. "platform". Neither is set when the certificate property is empty,
+// in which case the app is signed with the default certificate of the product.
+func bp2buildCertificate(ctx android.TopDownMutatorContext, certificate *string) (*bazel.Label, *string) {
+	name := proptools.String(certificate)
+	if name == "" {
+		return nil, nil
+	}
+	if android.SrcIsModule(name) != "" {
+		label := android.BazelLabelForModuleDepSingle(ctx, name)
+		return &label, nil
+	}
+	return nil, &name
+}
+
 type bazelAndroidAppAttributes struct {
 	*javaCommonAttributes
 	Deps             bazel.LabelListAttribute
@@ -1439,15 +1577,7 @@ func (a *AndroidApp) ConvertWithBp2build(ctx android.TopDownMutatorContext) {
 		resourceFiles.Includes = append(resourceFiles.Includes, files...)
 	}
 
-	var certificate *bazel.Label
-	certificateNamePtr := a.overridableAppProperties.Certificate
-	certificateName := proptools.StringDefault(certificateNamePtr, "")
-	certModule := android.SrcIsModule(certificateName)
-	if certModule != "" {
-		c := android.BazelLabelForModuleDepSingle(ctx, certificateName)
-		certificate = &c
-		certificateNamePtr = nil
-	}
+	certificate, certificateName := bp2buildCertificate(ctx, a.overridableAppProperties.Certificate)
 
 	attrs := &bazelAndroidAppAttributes{
 		commonAttrs,
@@ -1457,7 +1587,7 @@ func (a *AndroidApp) ConvertWithBp2build(ctx android.TopDownMutatorContext) {
 		a.overridableAppProperties.Package_name,
 		bazel.MakeLabelListAttribute(resourceFiles),
 		certificate,
-		certificateNamePtr,
+		certificateName,
 	}
 
 	props := bazel.BazelTargetModuleProperties{
@@ -20,6 +20,7 @@ package java
 
 import (
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/google/blueprint"
@@ -51,8 +52,18 @@ var combineApk = pctx.AndroidStaticRule("combineApk",
 		CommandDeps: []string{"${config.MergeZipsCmd}"},
 	})
 
+var zipalignApk = pctx.AndroidStaticRule("zipalignApk",
+	blueprint.RuleParams{
+		Command:     `${config.ZipAlign} -f -P $pageSizeKb 4 $in $out`,
+		CommandDeps: []string{"${config.ZipAlign}"},
+	},
+	"pageSizeKb")
+
+// CreateAndSignAppPackage combines the given files into an APK and signs it. If zipalignPageSize
+// is not 0, the APK is zipaligned before signing, with the uncompressed native libraries aligned
+// to pages of that size.
 func CreateAndSignAppPackage(ctx android.ModuleContext, outputFile android.WritablePath,
-	packageFile, jniJarFile, dexJarFile android.Path, certificates []Certificate, deps android.Paths, v4SignatureFile android.WritablePath, lineageFile android.Path) {
+	packageFile, jniJarFile, dexJarFile android.Path, certificates []Certificate, deps android.Paths, v4SignatureFile android.WritablePath, lineageFile android.Path, zipalignPageSize int64) {
 
 	unsignedApkName := strings.TrimSuffix(outputFile.Base(), ".apk") + "-unsigned.apk"
 	unsignedApk := android.PathForModuleOut(ctx, unsignedApkName)
@@ -73,10 +84,27 @@ func CreateAndSignAppPackage(ctx android.ModuleContext, outputFile android.Writa
 		Implicits: deps,
 	})
 
-	SignAppPackage(ctx, outputFile, unsignedApk, certificates, v4SignatureFile, lineageFile)
+	var apkToSign android.Path = unsignedApk
+	if zipalignPageSize > 0 {
+		alignedApk := android.PathForModuleOut(ctx, strings.TrimSuffix(unsignedApkName, ".apk")+"-aligned.apk")
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        zipalignApk,
+			Description: "zipalign",
+			Input:       unsignedApk,
+			Output:      alignedApk,
+			Args: map[string]string{
+				"pageSizeKb": strconv.FormatInt(zipalignPageSize/1024, 10),
+			},
+		})
+		apkToSign = alignedApk
+	}
+
+	SignAppPackage(ctx, outputFile, apkToSign, certificates, v4SignatureFile, lineageFile, zipalignPageSize)
 }
 
-func SignAppPackage(ctx android.ModuleContext, signedApk android.WritablePath, unsignedApk android.Path, certificates []Certificate, v4SignatureFile android.WritablePath, lineageFile android.Path) {
+// SignAppPackage signs the given APK. If alignment is not 0, signapk aligns the uncompressed native
+// libraries to pages of that size instead of its default.
+func SignAppPackage(ctx android.ModuleContext, signedApk android.WritablePath, unsignedApk android.Path, certificates []Certificate, v4SignatureFile android.WritablePath, lineageFile android.Path, alignment int64) {
 
 	var certificateArgs []string
 	var deps android.Paths
@@ -97,6 +125,10 @@ func SignAppPackage(ctx android.ModuleContext, signedApk android.WritablePath, u
 		deps = append(deps, lineageFile)
 	}
 
+	if alignment > 0 {
+		flags = append(flags, "-a", strconv.FormatInt(alignment, 10))
+	}
+
 	rule := Signapk
 	args := map[string]string{
 		"certificates": strings.Join(certificateArgs, " "),
@@ -2347,6 +2347,54 @@ func TestStl(t *testing.T) {
 	}
 }
 
+func TestUsesLibraryFragment(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		PrepareForTestWithJavaSdkLibraryFiles,
+		FixtureWithLastReleaseApis("foo", "bar"),
+	).RunTestWithBp(t, `
+		java_sdk_library {
+			name: "foo",
+			srcs: ["a.java"],
+			api_packages: ["foo"],
+			sdk_version: "current",
+		}
+
+		java_sdk_library {
+			name: "bar",
+			srcs: ["a.java"],
+			api_packages: ["bar"],
+			sdk_version: "current",
+		}
+
+		android_app {
+			name: "app",
+			srcs: ["a.java"],
+			libs: ["foo"],
+			optional_uses_libs: ["bar"],
+			sdk_version: "current",
+		}
+
+		java_genrule {
+			name: "fragment",
+			srcs: [":app{.uses-library-fragment}"],
+			out: ["fragment.xml"],
+			cmd: "cp $(in) $(out)",
+		}
+	`)
+
+	app := result.ModuleForTests("app", "android_common")
+	fragment := app.Output("uses-library/uses-library-fragment.xml")
+	android.AssertStringEquals(t, "uses-library fragment",
+		`<uses-library android:name="foo" />`+"\n"+
+			`<uses-library android:name="bar" android:required="false" />`+"\n",
+		android.ContentFromFileRuleForTests(t, fragment))
+
+	genrule := result.ModuleForTests("fragment", "android_common").Output("fragment.xml")
+	android.AssertStringListContains(t, "fragment genrule inputs", genrule.Implicits.Strings(),
+		fragment.Output.String())
+}
+
 func TestUsesLibraries(t *testing.T) {
 	bp := `
 		java_sdk_library {
@@ -2876,6 +2924,272 @@ func TestExportedProguardFlagFiles(t *testing.T) {
 	}
 }
 
+func TestExportedProguardFlagFilesFromJavaLibrary(t *testing.T) {
+	ctx, _ := testJava(t, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			static_libs: ["lib1", "lib3"],
+		}
+
+		java_library {
+			name: "lib1",
+			srcs: ["b.java"],
+			sdk_version: "current",
+			export_proguard_flags_files: ["lib1consumer.flags"],
+			static_libs: ["lib2"],
+		}
+
+		java_library {
+			name: "lib2",
+			srcs: ["c.java"],
+			sdk_version: "current",
+			export_proguard_flags_files: ["lib2consumer.flags"],
+		}
+
+		android_library {
+			name: "lib3",
+			srcs: ["d.java"],
+			sdk_version: "current",
+			export_proguard_flags_files: ["lib3consumer.flags"],
+		}
+
+		java_library {
+			name: "lib4",
+			srcs: ["e.java"],
+			sdk_version: "current",
+			export_proguard_flags_files: ["lib4consumer.flags"],
+		}
+
+		android_app {
+			name: "bar",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			libs: ["lib4"],
+		}
+	`)
+
+	r8 := ctx.ModuleForTests("foo", "android_common").Rule("java.r8")
+	for _, flags := range []string{"lib1consumer.flags", "lib2consumer.flags", "lib3consumer.flags"} {
+		android.AssertStringListContains(t, "r8 implicits", r8.Implicits.Strings(), flags)
+		android.AssertStringDoesContain(t, "r8 flags", r8.Args["r8Flags"], "-include "+flags)
+	}
+
+	// Only the static dependencies of an app are optimized along with it.
+	barR8 := ctx.ModuleForTests("bar", "android_common").Rule("java.r8")
+	android.AssertStringListDoesNotContain(t, "r8 implicits", barR8.Implicits.Strings(), "lib4consumer.flags")
+
+	// Make-built apps get the flags of an android_library through its androidmk entries.
+	lib3 := ctx.ModuleForTests("lib3", "android_common").Module()
+	entries := android.AndroidMkEntriesForTest(t, ctx, lib3)[0]
+	android.AssertStringListContains(t, "LOCAL_SOONG_EXPORT_PROGUARD_FLAGS",
+		entries.EntryMap["LOCAL_SOONG_EXPORT_PROGUARD_FLAGS"], "lib3consumer.flags")
+}
+
+func TestAppManifestMergerReport(t *testing.T) {
+	ctx := testApp(t, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			static_libs: ["lib"],
+			write_manifest_merger_report: true,
+		}
+
+		android_library {
+			name: "lib",
+			srcs: ["b.java"],
+			sdk_version: "current",
+		}
+	`)
+
+	foo := ctx.ModuleForTests("foo", "android_common")
+	merger := foo.Output("manifest_merger/AndroidManifest.xml")
+	report := foo.Output("manifest_merger/manifest-merger-report.txt")
+	if report.Rule != merger.Rule {
+		t.Errorf("expected the report to be written by the manifest merger, got rule %q", report.Rule)
+	}
+	android.AssertStringDoesContain(t, "manifest_merger args",
+		android.StringRelativeToTop(ctx.Config(), merger.Args["args"]),
+		"--report out/soong/.intermediates/foo/android_common/manifest_merger/manifest-merger-report.txt")
+	android.AssertStringDoesContain(t, "manifest_merger libs", merger.Args["libs"], "--libs ")
+
+	outputs, err := foo.Module().(*AndroidApp).OutputFiles(".manifest-report")
+	android.AssertDeepEquals(t, "error", nil, err)
+	android.AssertPathsRelativeToTopEquals(t, ".manifest-report output",
+		[]string{"out/soong/.intermediates/foo/android_common/manifest_merger/manifest-merger-report.txt"}, outputs)
+}
+
+func TestAppResourceDiff(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+		PrepareForTestWithOverlayBuildComponents,
+		android.FixtureMergeMockFs(android.MockFS{
+			"foo/res/values/strings.xml":                            nil,
+			"lib/res/values/strings.xml":                            nil,
+			"device/vendor/blah/overlay/foo/res/values/strings.xml": nil,
+		}),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.DeviceResourceOverlays = []string{"device/vendor/blah/overlay"}
+		}),
+	).RunTestWithBp(t, `
+		android_app {
+			name: "foo",
+			sdk_version: "current",
+			resource_dirs: ["foo/res"],
+			static_libs: ["lib"],
+			write_resource_diff: true,
+		}
+
+		android_library {
+			name: "lib",
+			sdk_version: "current",
+			resource_dirs: ["lib/res"],
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+
+	// The base resource table is linked from the resources of the app and of its libraries, without
+	// the product resource overlays.
+	baseOverlays := foo.Output("resource_diff/base-res.overlay.list").Inputs.Strings()
+	finalOverlays := foo.Output("aapt2/overlay.list").Inputs.Strings()
+	android.AssertStringListContains(t, "base resources", baseOverlays,
+		"out/soong/.intermediates/lib/android_common/package-res.apk")
+	android.AssertIntEquals(t, "number of product resource overlays", 1, len(finalOverlays)-len(baseOverlays))
+	android.AssertDeepEquals(t, "base resources", finalOverlays[:len(baseOverlays)], baseOverlays)
+	productOverlay := foo.Output(finalOverlays[len(finalOverlays)-1])
+	android.AssertPathsRelativeToTopEquals(t, "product resource overlay",
+		[]string{"device/vendor/blah/overlay/foo/res/values/strings.xml"}, productOverlay.Inputs)
+
+	baseRes := foo.Rule("aapt2LinkResourceTable")
+	android.AssertPathRelativeToTopEquals(t, "base resource table",
+		"out/soong/.intermediates/foo/android_common/resource_diff/base-res.apk", baseRes.Output)
+
+	// The diff consumes both the base and the final resource tables.
+	diff := foo.Rule("aapt2ResourceTableDiff")
+	android.AssertPathRelativeToTopEquals(t, "final resource table",
+		"out/soong/.intermediates/foo/android_common/package-res.apk", diff.Input)
+	android.AssertPathRelativeToTopEquals(t, "base resource table",
+		baseRes.Output.String(), diff.Implicit)
+
+	outputs, err := foo.Module().(*AndroidApp).OutputFiles(".resource-diff")
+	android.AssertDeepEquals(t, "error", nil, err)
+	android.AssertPathsRelativeToTopEquals(t, ".resource-diff output",
+		[]string{"out/soong/.intermediates/foo/android_common/resource_diff/resources.diff"}, outputs)
+
+	lib := result.ModuleForTests("lib", "android_common")
+	if rule := lib.MaybeRule("aapt2ResourceTableDiff").Rule; rule != nil {
+		t.Errorf("expected no resource diff without write_resource_diff, got %q", rule)
+	}
+}
+
+func TestAppZipalign(t *testing.T) {
+	ctx := testApp(t, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			zipalign: {
+				enabled: true,
+				page_size: 16384,
+			},
+		}
+
+		android_app {
+			name: "bar",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			zipalign: {
+				page_size: 16384,
+			},
+		}
+	`)
+
+	foo := ctx.ModuleForTests("foo", "android_common")
+	zipalign := foo.Output("foo-unsigned-aligned.apk")
+	android.AssertStringEquals(t, "zipalign page size", "16", zipalign.Args["pageSizeKb"])
+	android.AssertPathRelativeToTopEquals(t, "zipalign input",
+		"out/soong/.intermediates/foo/android_common/foo-unsigned.apk", zipalign.Input)
+
+	signapk := foo.Output("foo.apk")
+	android.AssertPathRelativeToTopEquals(t, "signapk input",
+		"out/soong/.intermediates/foo/android_common/foo-unsigned-aligned.apk", signapk.Input)
+	android.AssertStringDoesContain(t, "signapk flags", signapk.Args["flags"], "-a 16384")
+
+	bar := ctx.ModuleForTests("bar", "android_common")
+	if rule := bar.MaybeOutput("bar-unsigned-aligned.apk").Rule; rule != nil {
+		t.Errorf("expected no zipalign rule when zipalign is not enabled, got %q", rule)
+	}
+	android.AssertPathRelativeToTopEquals(t, "signapk input",
+		"out/soong/.intermediates/bar/android_common/bar-unsigned.apk", bar.Output("bar.apk").Input)
+	android.AssertStringDoesNotContain(t, "signapk flags", bar.Output("bar.apk").Args["flags"], "-a ")
+}
+
+func TestAppZipalignPageSizeError(t *testing.T) {
+	testJavaError(t, `zipalign.page_size: must be a power of two of at least 4096, got 12288`, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			zipalign: {
+				enabled: true,
+				page_size: 12288,
+			},
+		}
+	`)
+}
+
+func TestAppManifestValues(t *testing.T) {
+	ctx := testApp(t, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			manifest_values: {
+				application_id: "com.android.foo.overridden",
+				version_code: "42",
+				version_name: "4.2 beta",
+			},
+		}
+	`)
+
+	foo := ctx.ModuleForTests("foo", "android_common")
+	mergerArgs := foo.Output("manifest_merger/AndroidManifest.xml").Args["args"]
+	for _, expected := range []string{
+		"--property PACKAGE=com.android.foo.overridden",
+		"--placeholder applicationId=com.android.foo.overridden",
+		"--property VERSION_CODE=42",
+		"--property 'VERSION_NAME=4.2 beta'",
+	} {
+		android.AssertStringDoesContain(t, "manifest_merger args", mergerArgs, expected)
+	}
+
+	testJavaError(t, `manifest_values.version_code: must be an integer, got "forty-two"`, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			manifest_values: {
+				version_code: "forty-two",
+			},
+		}
+	`)
+
+	testJavaError(t, `manifest_values: cannot be set with dont_merge_manifests: true`, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			dont_merge_manifests: true,
+			manifest_values: {
+				version_name: "1.0",
+			},
+		}
+	`)
+}
+
 func TestTargetSdkVersionManifestFixer(t *testing.T) {
 	platform_sdk_codename := "Tiramisu"
 	testCases := []struct {
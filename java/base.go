@@ -79,6 +79,15 @@ type CommonProperties struct {
 	// list of java libraries that will be compiled into the resulting jar
 	Static_libs []string `android:"arch_variant"`
 
+	// list of java libraries that will be compiled into the resulting jar only when a release flag
+	// is enabled, in the form "<release flag>:<module>", e.g. "RELEASE_FOO:libfoo".
+	Flagged_deps []string
+
+	// list of java libraries that this module needs at runtime but not at compile time, e.g.
+	// because they are only accessed through reflection. They are not added to the classpath, but
+	// are propagated as <uses-library> dependencies like libs are.
+	Runtime_libs []string `android:"arch_variant"`
+
 	// manifest file to be included in resulting jar
 	Manifest *string `android:"path"`
 
@@ -88,10 +97,26 @@ type CommonProperties struct {
 	// If not blank, set the java version passed to javac as -source and -target
 	Java_version *string
 
+	// If set, pass --release with this version to javac instead of -source and -target, which
+	// also compiles against the platform API of that Java release. Cannot be combined with
+	// java_version, with -source or -target in javacflags, or with a bootclasspath or system
+	// modules, as --release selects the platform API itself.
+	Java_release *int64
+
+	// If set, compile with the javac of the prebuilt JDK of this version instead of the default
+	// JDK, e.g. "17". Can be "11" or "17".
+	Jdk_version *string
+
 	// If set to true, allow this module to be dexed and installed on devices.  Has no
-	// effect on host modules, which are always considered installable.
+	// effect on host modules, which are always considered installable, except for tests,
+	// which can set it to false to be built without being installed.
 	Installable *bool
 
+	// If set, install the jar to this directory relative to the root of the partition of the
+	// module, e.g. "framework/foo" installs system/framework/foo/<module>.jar for a system module.
+	// Defaults to "framework".
+	Install_path *string
+
 	// If set to true, include sources used to compile the module in to the final jar
 	Include_srcs *bool
 
@@ -108,9 +133,18 @@ type CommonProperties struct {
 	// more recompilation.
 	Exported_plugins []string
 
+	// If true, the plugins exported by this library are also exported to the libraries that
+	// depend on it indirectly, through libraries that depend on it directly.  Defaults to false.
+	Transitive_exported_plugins *bool
+
 	// The number of Java source entries each Javac instance can process
 	Javac_shard_size *int64
 
+	// Name of a group of modules whose header jars may be shared. Modules in the same group whose
+	// turbine invocations have identical sources, classpaths and flags share a single header jar
+	// compilation instead of each running turbine.
+	Javac_group *string
+
 	// Add host jdk tools.jar to bootclasspath
 	Use_tools_jar *bool
 
@@ -122,6 +156,71 @@ type CommonProperties struct {
 		Javacflags []string
 	}
 
+	Multi_release struct {
+		// The Java versions of the classes in the multi-release jar, e.g. ["8", "11"]. The first
+		// one is the version the module's own sources target and must match its Java version,
+		// each later one must have version-specific sources, of which only srcs_11 is supported.
+		// Defaults to the Java version of the module followed by 11 if srcs_11 is set.
+		Versions []string
+
+		// List of source files that are compiled with -source 11 and packaged under
+		// META-INF/versions/11/ in a multi-release jar. The module's own sources must target an
+		// earlier Java version.
+		Srcs_11 []string `android:"path"`
+	}
+
+	// If true, build a jar containing only the public and protected API of this module with
+	// stubbed method bodies, for distribution as a compile-only artifact. The jar is available
+	// through the ".apijar" output tag. Requires the module to have .java sources.
+	Api_jar *bool
+
+	// If true, build a srcjar containing the sources of this module and of all of its transitive
+	// static_libs, including generated sources. The srcjar is available through the
+	// ".transitive-srcjar" output tag.
+	Write_transitive_srcs *bool
+
+	// If true, write a bill of materials listing the jars of all the java_import modules this
+	// module transitively depends on, with their SHA-256 hashes, for supply chain verification.
+	// The file is available through the ".prebuilt-bom" output tag.
+	Write_prebuilt_bom *bool
+
+	// If true, write the properties of this module as JSON, after the values of its defaults, arch
+	// variants and product variables have been merged in, for debugging. The file is available
+	// through the ".effective-props" output tag.
+	Dump_effective_properties *bool
+
+	// If true, write a file listing the paths relative to the top of the tree of the sources and
+	// srcjars passed to the compiler, one per line, in the order they are compiled. This includes
+	// sources produced by globs, filegroups and generators. The file is available through the
+	// ".sources-list" output tag.
+	Write_sources_list *bool
+
+	// If true, write the javac command lines compiling this module, one per line, to a file
+	// available through the ".compile-command" output tag, for auditing the reproducibility of the
+	// build.
+	Emit_compile_command *bool
+
+	// If true, compile the java sources a second time and fail the build if the two compilations
+	// produce different classes jars, to verify that the build of the module is reproducible.
+	// Doubles the cost of compiling the module.
+	Verify_reproducible *bool
+
+	// Constant fields to verify in the compiled classes, in the form
+	// "<fully qualified class name>.<field>=<value>", e.g. "com.foo.Const.VALUE=expected".  The
+	// build fails if the value inlined in the class file for the field is not the given value.
+	// String values are given without quotes.
+	Verify_constants []string
+
+	// If true, build a single self-contained jar with the classes and resources of this module and
+	// all of its transitive static_libs, with sorted entries, exposed through the ".fatjar" output
+	// tag.  Useful for distributing standalone host tools.
+	Flatten_static_libs *bool
+
+	// How to handle entries that are present in more than one of the jars flattened by
+	// flatten_static_libs: "first" keeps the entry from the first jar, "error" fails the build if
+	// the jars contain different versions of the entry.  Defaults to "first".
+	Flatten_static_libs_duplicates *string
+
 	// When compiling language level 9+ .java code in packages that are part of
 	// a system module, patch_module names the module that your sources and
 	// dependencies should be patched into. The Android runtime currently
@@ -162,6 +261,12 @@ type CommonProperties struct {
 		// environment variable is true. Setting this to false will improve build
 		// performance more than adding -XepDisableAllChecks in javacflags.
 		Enabled *bool
+
+		// If true, errorprone is run in a separate javac pass instead of the regular one, and
+		// its diagnostics are written to errorprone/errorprone-report.txt in the module's output
+		// directory.  The pass is a validation of the module's jar, so it is not on the critical
+		// path of the module's dependents.  Defaults to false.
+		Report *bool
 	}
 
 	Proto struct {
@@ -179,6 +284,11 @@ type CommonProperties struct {
 
 	// A list of java_library instances that provide additional hiddenapi annotations for the library.
 	Hiddenapi_additional_annotations []string
+
+	// List of host tool modules that are run on the output of this module at a later packaging
+	// stage. They are built before the output of this module, which has an order-only dependency
+	// on them.
+	Required_host_tools []string
 }
 
 // Properties that are specific to device modules. Host module factories should not add these when
@@ -193,6 +303,12 @@ type DeviceProperties struct {
 	// SDK kinds. If the SDK kind value is empty, it will be set to public.
 	Sdk_version *string
 
+	// If set, build a variant of the module for each of the listed sdk versions, each compiling
+	// against that version. The first variant is the one used by modules depending on this one and
+	// the only one installed. Can't be combined with sdk_version. Only supported by java_library.
+	// See sdk_version for possible values.
+	Sdk_versions []string
+
 	// if not blank, set the minimum version of the sdk that the compiled artifacts will run against.
 	// Defaults to sdk_version if not set. See sdk_version for possible values.
 	Min_sdk_version *string
@@ -205,6 +321,17 @@ type DeviceProperties struct {
 	// Defaults to sdk_version if not set. See sdk_version for possible values.
 	Target_sdk_version *string
 
+	// if not blank, the path relative to the top of the source tree of a jar to compile against
+	// instead of the one selected by sdk_version, e.g. "prebuilts/custom/android.jar". The jar is
+	// used as the bootclasspath, or as the classpath along with no system modules when targeting
+	// Java 9 or higher. Intended for experimenting with SDKs that aren't checked in as a prebuilt
+	// SDK version.
+	Custom_sdk_jar *string
+
+	// If true, the module must never be configured as a boot jar, e.g. through PRODUCT_BOOT_JARS or
+	// PRODUCT_APEX_BOOT_JARS. platform_bootclasspath reports an error if it is.
+	Never_bootclasspath *bool
+
 	// Whether to compile against the platform APIs instead of an SDK.
 	// If true, then sdk_version must be empty. The value of this field
 	// is ignored when module's type isn't android_app.
@@ -399,6 +526,10 @@ type Module struct {
 	// inserting into the bootclasspath/classpath of another compile
 	headerJarFile android.Path
 
+	// turbine build statement shared with the other modules of the same javac_group, emitted by
+	// javacGroupSingleton.
+	javacGroupTurbineParams *android.BuildParams
+
 	// jar file containing implementation classes including static library dependencies but no
 	// resources
 	implementationJarFile android.Path
@@ -406,6 +537,17 @@ type Module struct {
 	// jar file containing only resources including from static library dependencies
 	resourceJar android.Path
 
+	// report of the diagnostics of the separate errorprone pass, if errorprone.report is set
+	errorproneReport android.WritablePath
+
+	// stamp files of the comparisons of the two compilations of the classes jars, if
+	// verify_reproducible is set
+	reproducibleChecks android.Paths
+
+	// jar file containing the classes and resources of the module and its transitive static
+	// dependencies, if flatten_static_libs is set
+	fatJar android.Path
+
 	// args and dependencies to package source files into a srcjar
 	srcJarArgs []string
 	srcJarDeps android.Paths
@@ -420,6 +562,32 @@ type Module struct {
 	// output file containing uninstrumented classes that will be instrumented by jacoco
 	jacocoReportClassesFile android.Path
 
+	// jar file containing only the public and protected API of the module, if api_jar is set
+	apiJar android.Path
+
+	// The sources and srcjars compiled into this module and its transitive static dependencies.
+	transitiveSrcFiles *android.DepSet
+
+	// srcjar containing transitiveSrcFiles, built if write_transitive_srcs is set.
+	transitiveSrcJar android.Path
+
+	// bill of materials of the transitive java_import dependencies, built if write_prebuilt_bom
+	// is set.
+	prebuiltBom android.Path
+
+	// JSON file containing the merged properties of the module, written if
+	// dump_effective_properties is set.
+	effectiveProps android.Path
+
+	// file listing the compiled sources and srcjars, written if write_sources_list is set.
+	sourcesList android.Path
+
+	// javac command lines compiling the module, collected if emit_compile_command is set.
+	javacCommands []string
+
+	// file containing javacCommands.
+	compileCommand android.Path
+
 	// output file of the module, which may be a classes jar or a dex jar
 	outputFile       android.Path
 	extraOutputFiles android.Paths
@@ -454,6 +622,10 @@ type Module struct {
 	// if true, the exported plugins generate API and require disabling turbine.
 	exportedDisableTurbine bool
 
+	// if true, the exported plugins are also exported to the modules that depend on this module
+	// indirectly.
+	exportedPluginsTransitive bool
+
 	// list of source files, collected from srcFiles with unique java and all kt files,
 	// will be used by android.IDEInfo struct
 	expandIDEInfoCompiledSrcs []string
@@ -573,6 +745,55 @@ func (j *Module) OutputFiles(tag string) (android.Paths, error) {
 			return android.Paths{j.dexer.proguardDictionary.Path()}, nil
 		}
 		return nil, fmt.Errorf("%q was requested, but no output file was found.", tag)
+	case ".apijar":
+		if j.apiJar != nil {
+			return android.Paths{j.apiJar}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but the module does not build an API jar, set api_jar: true", tag)
+	case ".transitive-srcjar":
+		if j.transitiveSrcJar != nil {
+			return android.Paths{j.transitiveSrcJar}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but the module does not build it, set write_transitive_srcs: true", tag)
+	case ".effective-props":
+		if j.effectiveProps != nil {
+			return android.Paths{j.effectiveProps}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but the module does not write it, set dump_effective_properties: true", tag)
+	case ".prebuilt-bom":
+		if j.prebuiltBom != nil {
+			return android.Paths{j.prebuiltBom}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but the module does not write it, set write_prebuilt_bom: true", tag)
+	case ".sources-list":
+		if j.sourcesList != nil {
+			return android.Paths{j.sourcesList}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but the module does not write it, set write_sources_list: true", tag)
+	case ".compile-command":
+		if j.compileCommand != nil {
+			return android.Paths{j.compileCommand}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but the module does not write it, set emit_compile_command: true", tag)
+	case ".fatjar":
+		if j.fatJar != nil {
+			return android.Paths{j.fatJar}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but the module does not build it, set flatten_static_libs: true", tag)
+	case ".dex.debug", ".dex.release":
+		dexJar := j.dexer.releaseDexJar
+		if tag == ".dex.debug" {
+			dexJar = j.dexer.debugDexJar
+		}
+		if dexJar.Valid() {
+			return android.Paths{dexJar.Path()}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but the module does not build it, set dual_dex: true", tag)
+	case ".dex-size-report":
+		if j.dexer.dexSizeReport.Valid() {
+			return android.Paths{j.dexer.dexSizeReport.Path()}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but the module does not write it, set write_dex_size_report: true", tag)
 	default:
 		return nil, fmt.Errorf("unsupported module reference tag %q", tag)
 	}
@@ -669,11 +890,29 @@ func (j *Module) AvailableFor(what string) bool {
 	return j.ApexModuleBase.AvailableFor(what)
 }
 
+// enabledFlaggedDeps returns the modules listed in flagged_deps whose release flag is enabled.
+func enabledFlaggedDeps(ctx android.BottomUpMutatorContext, flaggedDeps []string) []string {
+	var deps []string
+	for _, flaggedDep := range flaggedDeps {
+		i := strings.Index(flaggedDep, ":")
+		if i <= 0 || i == len(flaggedDep)-1 {
+			ctx.PropertyErrorf("flagged_deps", "%q is not in the form \"<release flag>:<module>\"", flaggedDep)
+			continue
+		}
+		if ctx.Config().ReleaseFlagEnabled(flaggedDep[:i]) {
+			deps = append(deps, flaggedDep[i+1:])
+		}
+	}
+	return deps
+}
+
 func (j *Module) deps(ctx android.BottomUpMutatorContext) {
 	if ctx.Device() {
 		j.linter.deps(ctx)
 
-		sdkDeps(ctx, android.SdkContext(j), j.dexer)
+		if j.deviceProperties.Custom_sdk_jar == nil {
+			sdkDeps(ctx, android.SdkContext(j), j.dexer)
+		}
 
 		if j.deviceProperties.SyspropPublicStub != "" {
 			// This is a sysprop implementation library that has a corresponding sysprop public
@@ -685,10 +924,15 @@ func (j *Module) deps(ctx android.BottomUpMutatorContext) {
 
 	libDeps := ctx.AddVariationDependencies(nil, libTag, j.properties.Libs...)
 	ctx.AddVariationDependencies(nil, staticLibTag, j.properties.Static_libs...)
+	ctx.AddVariationDependencies(nil, staticLibTag, enabledFlaggedDeps(ctx, j.properties.Flagged_deps)...)
+	ctx.AddVariationDependencies(nil, runtimeLibTag, j.properties.Runtime_libs...)
 
 	// Add dependency on libraries that provide additional hidden api annotations.
 	ctx.AddVariationDependencies(nil, hiddenApiAnnotationsTag, j.properties.Hiddenapi_additional_annotations...)
 
+	ctx.AddFarVariationDependencies(ctx.Config().BuildOSTarget.Variations(), requiredHostToolTag,
+		j.properties.Required_host_tools...)
+
 	if ctx.DeviceConfig().VndkVersion() != "" && ctx.Config().EnforceInterPartitionJavaSdkLibrary() {
 		// Require java_sdk_library at inter-partition java dependency to ensure stable
 		// interface between partitions. If inter-partition java_library dependency is detected,
@@ -851,6 +1095,17 @@ func (j *Module) collectBuilderFlags(ctx android.ModuleContext, deps deps) javaB
 
 	// javaVersion flag.
 	flags.javaVersion = getJavaVersion(ctx, String(j.properties.Java_version), android.SdkContext(j))
+	if j.properties.Java_release != nil {
+		flags = j.javaReleaseFlags(ctx, flags)
+	}
+	if jdkVersion := String(j.properties.Jdk_version); jdkVersion != "" {
+		if android.InList(jdkVersion, config.JdkVersions()) {
+			flags.javacCmd = config.JdkTool(ctx, jdkVersion, "javac")
+		} else {
+			ctx.PropertyErrorf("jdk_version", "unsupported JDK version %q, must be one of %q",
+				jdkVersion, config.JdkVersions())
+		}
+	}
 
 	epEnabled := j.properties.Errorprone.Enabled
 	if (ctx.Config().RunErrorProne() && epEnabled == nil) || Bool(epEnabled) {
@@ -881,7 +1136,7 @@ func (j *Module) collectBuilderFlags(ctx android.ModuleContext, deps deps) javaB
 	flags.processors = android.FirstUniqueStrings(flags.processors)
 
 	if len(flags.bootClasspath) == 0 && ctx.Host() && !flags.javaVersion.usesJavaModules() &&
-		decodeSdkDep(ctx, android.SdkContext(j)).hasStandardLibs() {
+		flags.javaRelease == "" && decodeSdkDep(ctx, android.SdkContext(j)).hasStandardLibs() {
 		// Give host-side tools a version of OpenJDK's standard libraries
 		// close to what they're targeting. As of Dec 2017, AOSP is only
 		// bundling OpenJDK 8 and 9, so nothing < 8 is available.
@@ -908,6 +1163,11 @@ func (j *Module) collectBuilderFlags(ctx android.ModuleContext, deps deps) javaB
 	// systemModules
 	flags.systemModules = deps.systemModules
 
+	if flags.javaRelease != "" && (len(flags.bootClasspath) > 0 || flags.systemModules != nil) {
+		ctx.PropertyErrorf("java_release",
+			"cannot be combined with a bootclasspath or system modules, --release selects the platform API")
+	}
+
 	// aidl flags.
 	flags.aidlFlags, flags.aidlDeps = j.aidlFlags(ctx, deps.aidlPreprocess, deps.aidlIncludeDirs)
 
@@ -1167,11 +1427,19 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 	}
 	if len(uniqueSrcFiles) > 0 || len(srcJars) > 0 {
 		var extraJarDeps android.Paths
-		if Bool(j.properties.Errorprone.Enabled) {
+		epEnabled := j.properties.Errorprone.Enabled
+		if Bool(j.properties.Errorprone.Report) &&
+			(Bool(epEnabled) || (ctx.Config().RunErrorProne() && epEnabled == nil)) {
+			// If an errorprone report is requested, run errorprone in a separate pass that
+			// validates the output jar instead of adding it to the regular build.
+			j.errorproneReport = android.PathForModuleOut(ctx, "errorprone", "errorprone-report.txt")
+			TransformJavaToErrorproneReport(ctx, j.errorproneReport, uniqueSrcFiles, srcJars,
+				enableErrorproneFlags(flags))
+		} else if Bool(epEnabled) {
 			// If error-prone is enabled, enable errorprone flags on the regular
 			// build.
 			flags = enableErrorproneFlags(flags)
-		} else if ctx.Config().RunErrorProne() && j.properties.Errorprone.Enabled == nil {
+		} else if ctx.Config().RunErrorProne() && epEnabled == nil {
 			// Otherwise, if the RUN_ERROR_PRONE environment variable is set, create
 			// a new jar file just for compiling with the errorprone compiler to.
 			// This is because we don't want to cause the java files to get completely
@@ -1215,6 +1483,22 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 		}
 	}
 
+	if Bool(j.properties.Api_jar) {
+		j.apiJar = j.buildApiJar(ctx, jarName, srcFiles, srcJars, flags)
+		if ctx.Failed() {
+			return
+		}
+	}
+
+	multiRelease := len(j.properties.Multi_release.Srcs_11) > 0 ||
+		len(j.properties.Multi_release.Versions) > 0
+	if multiRelease {
+		jars = append(jars, j.compileMultiReleaseClasses(ctx, jarName, jars, flags))
+		if ctx.Failed() {
+			return
+		}
+	}
+
 	j.srcJarArgs, j.srcJarDeps = resourcePathsToJarArgs(srcFiles), srcFiles
 
 	var includeSrcJar android.WritablePath
@@ -1275,6 +1559,9 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 	if !manifest.Valid() && j.properties.Manifest != nil {
 		manifest = android.OptionalPathForPath(android.PathForModuleSrc(ctx, *j.properties.Manifest))
 	}
+	if multiRelease {
+		manifest = multiReleaseManifest(ctx, manifest)
+	}
 
 	services := android.PathsForModuleSrc(ctx, j.properties.Services)
 	if len(services) > 0 {
@@ -1301,11 +1588,20 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 		jars = append(jars, servicesJar)
 	}
 
+	if Bool(j.properties.Flatten_static_libs) {
+		fatJarInputs := append(android.Paths{}, jars...)
+		fatJarInputs = append(fatJarInputs, resourceJars...)
+		j.fatJar = j.buildFatJar(ctx, jarName, fatJarInputs, manifest)
+		if ctx.Failed() {
+			return
+		}
+	}
+
 	// Combine the classes built from sources, any manifests, and any static libraries into
 	// classes.jar. If there is only one input jar this step will be skipped.
 	var outputFile android.OutputPath
 
-	if len(jars) == 1 && !manifest.Valid() {
+	if len(jars) == 1 && !manifest.Valid() && len(deps.requiredHostTools) == 0 {
 		// Optimization: skip the combine step as there is nothing to do
 		// TODO(ccross): this leaves any module-info.class files, but those should only come from
 		// prebuilt dependencies until we support modules in the platform build, so there shouldn't be
@@ -1332,8 +1628,12 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 		}
 	} else {
 		combinedJar := android.PathForModuleOut(ctx, "combined", jarName)
-		TransformJarsToJar(ctx, combinedJar, "for javac", jars, manifest,
+		combineParams := jarsToJarBuildParams(combinedJar, "for javac", jars, manifest,
 			false, nil, nil)
+		// The host tools are run on the output at a later packaging stage, so make sure they are
+		// built whenever the output is.
+		combineParams.OrderOnly = deps.requiredHostTools
+		ctx.Build(pctx, combineParams)
 		outputFile = combinedJar.OutputPath
 	}
 
@@ -1356,6 +1656,29 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 		}
 	}
 
+	var validations android.Paths
+	if len(j.properties.Verify_constants) > 0 {
+		validations = append(validations, j.verifyConstants(ctx, outputFile)...)
+	}
+	validations = append(validations, j.reproducibleChecks...)
+	if j.errorproneReport != nil {
+		validations = append(validations, j.errorproneReport)
+	}
+
+	if len(validations) > 0 {
+		// Copy the output jar to another path with validation dependencies on the checks of the
+		// module, so that building anything that depends on the module runs them without waiting
+		// for them.
+		inputFile := outputFile
+		outputFile = android.PathForModuleOut(ctx, "checked", jarName).OutputPath
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        android.Cp,
+			Input:       inputFile,
+			Output:      outputFile,
+			Validations: validations,
+		})
+	}
+
 	// Check package restrictions if necessary.
 	if len(j.properties.Permitted_packages) > 0 {
 		// Time stamp file created by the package check rule.
@@ -1506,8 +1829,37 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 		j.linter.lint(ctx)
 	}
 
+	j.transitiveSrcFiles = android.NewDepSet(android.POSTORDER,
+		append(append(android.Paths(nil), j.compiledJavaSrcs...), j.compiledSrcJars...),
+		deps.transitiveStaticSrcFiles)
+	if Bool(j.properties.Write_transitive_srcs) {
+		j.transitiveSrcJar = buildTransitiveSrcJar(ctx, j.transitiveSrcFiles.ToList())
+	}
+	if Bool(j.properties.Write_prebuilt_bom) {
+		j.prebuiltBom = buildPrebuiltBom(ctx)
+	}
+	if Bool(j.properties.Dump_effective_properties) {
+		j.effectiveProps = writeEffectiveProperties(ctx, ctx.Module())
+	}
+	if Bool(j.properties.Write_sources_list) {
+		sourcesList := android.PathForModuleOut(ctx, "sources.txt")
+		var srcs []string
+		srcs = append(srcs, j.compiledJavaSrcs.Strings()...)
+		srcs = append(srcs, j.compiledSrcJars.Strings()...)
+		android.WriteFileRule(ctx, sourcesList, strings.Join(srcs, "\n"))
+		j.sourcesList = sourcesList
+	}
+	if Bool(j.properties.Emit_compile_command) {
+		compileCommand := android.PathForModuleOut(ctx, "compile-command.txt")
+		android.WriteFileRule(ctx, compileCommand, strings.Join(j.javacCommands, "\n"))
+		j.compileCommand = compileCommand
+	}
+
 	ctx.CheckbuildFile(outputFile)
 
+	exportedProguardFlagFiles := append(android.PathsForModuleSrc(ctx, j.dexProperties.Export_proguard_flags_files),
+		deps.exportedProguardFlagFiles...)
+
 	ctx.SetProvider(JavaInfoProvider, JavaInfo{
 		HeaderJars:                     android.PathsIfNonNil(j.headerJarFile),
 		ImplementationAndResourcesJars: android.PathsIfNonNil(j.implementationAndResourcesJar),
@@ -1519,13 +1871,42 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 		ExportedPlugins:                j.exportedPluginJars,
 		ExportedPluginClasses:          j.exportedPluginClasses,
 		ExportedPluginDisableTurbine:   j.exportedDisableTurbine,
+		ExportedPluginsTransitive:      j.exportedPluginsTransitive,
 		JacocoReportClassesFile:        j.jacocoReportClassesFile,
+		TransitiveSrcFiles:             j.transitiveSrcFiles,
+		ExportedProguardFlagFiles:      android.FirstUniquePaths(exportedProguardFlagFiles),
 	})
 
 	// Save the output file with no relative path so that it doesn't end up in a subdirectory when used as a resource
 	j.outputFile = outputFile.WithoutRel()
 }
 
+// buildFatJar merges the classes and resources of the module and its static dependencies into a
+// single jar with sorted entries, handling duplicate entries according to
+// flatten_static_libs_duplicates.
+func (j *Module) buildFatJar(ctx android.ModuleContext, jarName string, jars android.Paths,
+	manifest android.OptionalPath) android.Path {
+
+	ignoreDuplicates := true
+	switch policy := String(j.properties.Flatten_static_libs_duplicates); policy {
+	case "", "first":
+	case "error":
+		ignoreDuplicates = false
+	default:
+		ctx.PropertyErrorf("flatten_static_libs_duplicates", `must be "first" or "error", got %q`, policy)
+		return nil
+	}
+
+	fatJar := android.PathForModuleOut(ctx, "fatjar", jarName).OutputPath
+	TransformJarsToFatJar(ctx, fatJar, jars, manifest, ignoreDuplicates)
+	if j.expandJarjarRules != nil {
+		jarjarFile := android.PathForModuleOut(ctx, "fatjar-jarjar", jarName).OutputPath
+		TransformJarJar(ctx, jarjarFile, fatJar, j.expandJarjarRules)
+		fatJar = jarjarFile
+	}
+	return fatJar
+}
+
 func (j *Module) useCompose() bool {
 	return android.InList("androidx.compose.runtime_runtime", j.properties.Static_libs)
 }
@@ -1556,6 +1937,17 @@ func (j *Module) compileJavaClasses(ctx android.ModuleContext, jarName string, i
 
 	classes := android.PathForModuleOut(ctx, "javac", jarName).OutputPath
 	TransformJavaToClasses(ctx, classes, idx, srcFiles, srcJars, flags, extraJarDeps)
+	if Bool(j.properties.Verify_reproducible) {
+		rebuiltClasses := android.PathForModuleOut(ctx, "reproducible", "javac", jarName)
+		transformJavaToClasses(ctx, rebuiltClasses, idx, srcFiles, srcJars, flags, extraJarDeps,
+			"reproducible", "javac reproducibility check")
+		check := android.PathForModuleOut(ctx, "reproducible", jarName+".stamp")
+		TransformVerifyReproducible(ctx, check, classes, rebuiltClasses)
+		j.reproducibleChecks = append(j.reproducibleChecks, check)
+	}
+	if Bool(j.properties.Emit_compile_command) {
+		j.javacCommands = append(j.javacCommands, javacCommandLine(ctx, idx, srcFiles, srcJars, flags))
+	}
 
 	if ctx.Config().EmitXrefRules() {
 		extractionFile := android.PathForModuleOut(ctx, kzipName)
@@ -1598,8 +1990,13 @@ func (j *Module) compileJavaHeader(ctx android.ModuleContext, srcFiles, srcJars
 	var jars android.Paths
 	if len(srcFiles) > 0 || len(srcJars) > 0 {
 		// Compile java sources into turbine.jar.
-		turbineJar := android.PathForModuleOut(ctx, "turbine", jarName)
-		TransformJavaToHeaderClasses(ctx, turbineJar, srcFiles, srcJars, flags)
+		var turbineJar android.WritablePath
+		if group := String(j.properties.Javac_group); group != "" {
+			turbineJar = j.compileJavacGroupHeader(ctx, group, srcFiles, srcJars, flags)
+		} else {
+			turbineJar = android.PathForModuleOut(ctx, "turbine", jarName)
+			TransformJavaToHeaderClasses(ctx, turbineJar, srcFiles, srcJars, flags)
+		}
 		if ctx.Failed() {
 			return nil, nil
 		}
@@ -1740,6 +2137,10 @@ func (j *Module) IsInstallable() bool {
 	return Bool(j.properties.Installable)
 }
 
+func (j *Module) neverBootclasspath() bool {
+	return Bool(j.deviceProperties.Never_bootclasspath)
+}
+
 type sdkLinkType int
 
 const (
@@ -1855,7 +2256,16 @@ func (j *Module) checkSdkLinkType(
 func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 	var deps deps
 
-	if ctx.Device() {
+	if customSdkJar := j.deviceProperties.Custom_sdk_jar; ctx.Device() && customSdkJar != nil {
+		jarPath := android.ExistentPathForSource(ctx, *customSdkJar)
+		if !jarPath.Valid() {
+			ctx.PropertyErrorf("custom_sdk_jar", "%q does not exist", *customSdkJar)
+		} else {
+			deps.bootClasspath = append(deps.bootClasspath, jarPath.Path())
+			deps.java9Classpath = append(deps.java9Classpath, jarPath.Path())
+			deps.dexClasspath = append(deps.dexClasspath, jarPath.Path())
+		}
+	} else if ctx.Device() {
 		sdkDep := decodeSdkDep(ctx, android.SdkContext(j))
 		if sdkDep.invalidVersion {
 			ctx.AddMissingDependencies(sdkDep.bootclasspath)
@@ -1872,6 +2282,22 @@ func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 
 	sdkLinkType, _ := j.getSdkLinkType(ctx, ctx.ModuleName())
 
+	// The modules that provide each prebuilt AIDL interface, to make sure that the module is
+	// compiled against a single version of each of them.
+	type aidlImport struct{ module, version string }
+	aidlImports := make(map[string]aidlImport)
+	checkAidlImport := func(otherName string, dep JavaInfo) {
+		if dep.AidlInterface == "" {
+			return
+		}
+		if prev, ok := aidlImports[dep.AidlInterface]; !ok {
+			aidlImports[dep.AidlInterface] = aidlImport{otherName, dep.AidlVersion}
+		} else if prev.version != dep.AidlVersion {
+			ctx.ModuleErrorf("depends on version %s of AIDL interface %q through %q and on version %s through %q",
+				prev.version, dep.AidlInterface, prev.module, dep.AidlVersion, otherName)
+		}
+	}
+
 	ctx.VisitDirectDeps(func(module android.Module) {
 		otherName := ctx.OtherModuleName(module)
 		tag := ctx.OtherModuleDependencyTag(module)
@@ -1884,6 +2310,14 @@ func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 			// Handled by AndroidApp.collectAppDeps
 			return
 		}
+		if tag == requiredHostToolTag {
+			if t, ok := module.(android.HostToolProvider); ok && t.HostToolPath().Valid() {
+				deps.requiredHostTools = append(deps.requiredHostTools, t.HostToolPath().Path())
+			} else {
+				ctx.PropertyErrorf("required_host_tools", "%q is not a host tool", otherName)
+			}
+			return
+		}
 
 		if dep, ok := module.(SdkLibraryDependency); ok {
 			switch tag {
@@ -1911,8 +2345,10 @@ func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 				deps.classpath = append(deps.classpath, dep.HeaderJars...)
 				deps.dexClasspath = append(deps.dexClasspath, dep.HeaderJars...)
 				deps.aidlIncludeDirs = append(deps.aidlIncludeDirs, dep.AidlIncludeDirs...)
+				checkAidlImport(otherName, dep)
 				addPlugins(&deps, dep.ExportedPlugins, dep.ExportedPluginClasses...)
 				deps.disableTurbine = deps.disableTurbine || dep.ExportedPluginDisableTurbine
+				j.reexportTransitivePlugins(dep)
 			case java9LibTag:
 				deps.java9Classpath = append(deps.java9Classpath, dep.HeaderJars...)
 			case staticLibTag:
@@ -1921,11 +2357,17 @@ func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 				deps.staticHeaderJars = append(deps.staticHeaderJars, dep.HeaderJars...)
 				deps.staticResourceJars = append(deps.staticResourceJars, dep.ResourceJars...)
 				deps.aidlIncludeDirs = append(deps.aidlIncludeDirs, dep.AidlIncludeDirs...)
+				checkAidlImport(otherName, dep)
 				addPlugins(&deps, dep.ExportedPlugins, dep.ExportedPluginClasses...)
 				// Turbine doesn't run annotation processors, so any module that uses an
 				// annotation processor that generates API is incompatible with the turbine
 				// optimization.
 				deps.disableTurbine = deps.disableTurbine || dep.ExportedPluginDisableTurbine
+				j.reexportTransitivePlugins(dep)
+				if dep.TransitiveSrcFiles != nil {
+					deps.transitiveStaticSrcFiles = append(deps.transitiveStaticSrcFiles, dep.TransitiveSrcFiles)
+				}
+				deps.exportedProguardFlagFiles = append(deps.exportedProguardFlagFiles, dep.ExportedProguardFlagFiles...)
 			case pluginTag:
 				if plugin, ok := module.(*Plugin); ok {
 					if plugin.pluginProperties.Processor_class != nil {
@@ -1948,6 +2390,8 @@ func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 				}
 			case exportedPluginTag:
 				if plugin, ok := module.(*Plugin); ok {
+					j.exportedPluginsTransitive = j.exportedPluginsTransitive ||
+						Bool(j.properties.Transitive_exported_plugins)
 					j.exportedPluginJars = append(j.exportedPluginJars, dep.ImplementationAndResourcesJars...)
 					if plugin.pluginProperties.Processor_class != nil {
 						j.exportedPluginClasses = append(j.exportedPluginClasses, *plugin.pluginProperties.Processor_class)
@@ -1955,7 +2399,8 @@ func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 					// Turbine doesn't run annotation processors, so any module that uses an
 					// annotation processor that generates API is incompatible with the turbine
 					// optimization.
-					j.exportedDisableTurbine = Bool(plugin.pluginProperties.Generates_api)
+					j.exportedDisableTurbine = j.exportedDisableTurbine ||
+						Bool(plugin.pluginProperties.Generates_api)
 				} else {
 					ctx.PropertyErrorf("exported_plugins", "%q is not a java_plugin module", otherName)
 				}
@@ -1998,7 +2443,7 @@ func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 				}
 				sm := module.(SystemModulesProvider)
 				outputDir, outputDeps := sm.OutputDirAndDeps()
-				deps.systemModules = &systemModules{outputDir, outputDeps}
+				deps.systemModules = &systemModules{outputDir, outputDeps, sm.HeaderJars()}
 
 			case instrumentationForTag:
 				ctx.PropertyErrorf("instrumentation_for", "dependency %q of type %q does not provide JavaInfo so is unsuitable for use with this property", ctx.OtherModuleName(module), ctx.OtherModuleType(module))
@@ -2016,6 +2461,18 @@ func addPlugins(deps *deps, pluginJars android.Paths, pluginClasses ...string) {
 	deps.processorClasses = append(deps.processorClasses, pluginClasses...)
 }
 
+// reexportTransitivePlugins exports the plugins exported by a dependency to the modules that
+// depend on this module, if the dependency exports its plugins transitively.
+func (j *Module) reexportTransitivePlugins(dep JavaInfo) {
+	if !dep.ExportedPluginsTransitive {
+		return
+	}
+	j.exportedPluginJars = append(j.exportedPluginJars, dep.ExportedPlugins...)
+	j.exportedPluginClasses = append(j.exportedPluginClasses, dep.ExportedPluginClasses...)
+	j.exportedDisableTurbine = j.exportedDisableTurbine || dep.ExportedPluginDisableTurbine
+	j.exportedPluginsTransitive = true
+}
+
 // TODO(b/132357300) Generalize SdkLibrarComponentDependency to non-SDK libraries and merge with
 // this interface.
 type ProvidesUsesLib interface {
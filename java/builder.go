@@ -45,10 +45,10 @@ var (
 			Command: `rm -rf "$outDir" "$annoDir" "$srcJarDir" "$out" && mkdir -p "$outDir" "$annoDir" "$srcJarDir" && ` +
 				`${config.ZipSyncCmd} -d $srcJarDir -l $srcJarDir/list -f "*.java" $srcJars && ` +
 				`(if [ -s $srcJarDir/list ] || [ -s $out.rsp ] ; then ` +
-				`${config.SoongJavacWrapper} $javaTemplate${config.JavacCmd} ` +
-				`${config.JavacHeapFlags} ${config.JavacVmFlags} ${config.CommonJdkFlags} ` +
+				`${config.SoongJavacWrapper} $javaTemplate$javacCmd ` +
+				`${config.JavacHeapFlags} ${config.JavacVmFlags} $jvmFlags ${config.CommonJdkFlags} ` +
 				`$processorpath $processor $javacFlags $bootClasspath $classpath ` +
-				`-source $javaVersion -target $javaVersion ` +
+				`$javaVersionFlags ` +
 				`-d $outDir -s $annoDir @$out.rsp @$srcJarDir/list ; fi ) && ` +
 				`$zipTemplate${config.SoongZipCmd} -jar -o $out -C $outDir -D $outDir && ` +
 				`rm -rf "$srcJarDir"`,
@@ -74,7 +74,42 @@ var (
 				Platform:     map[string]string{remoteexec.PoolKey: "${config.REJavaPool}"},
 			},
 		}, []string{"javacFlags", "bootClasspath", "classpath", "processorpath", "processor", "srcJars", "srcJarDir",
-			"outDir", "annoDir", "javaVersion"}, nil)
+			"outDir", "annoDir", "javaVersionFlags", "jvmFlags", "javacCmd"}, nil)
+
+	// Compiles the sources with errorprone only to check them, and writes the diagnostics to a
+	// report. The compilation fails if errorprone reports errors, with the report printed.
+	errorproneReport = pctx.AndroidStaticRule("errorproneReport",
+		blueprint.RuleParams{
+			Command: `rm -rf "$outDir" "$annoDir" "$srcJarDir" "$out" && mkdir -p "$outDir" "$annoDir" "$srcJarDir" && ` +
+				`${config.ZipSyncCmd} -d $srcJarDir -l $srcJarDir/list -f "*.java" $srcJars && ` +
+				`(if [ -s $srcJarDir/list ] || [ -s $out.rsp ] ; then ` +
+				`${config.SoongJavacWrapper} $javacCmd ` +
+				`${config.JavacHeapFlags} ${config.JavacVmFlags} $jvmFlags ${config.CommonJdkFlags} ` +
+				`$processorpath $processor $javacFlags $bootClasspath $classpath ` +
+				`$javaVersionFlags ` +
+				`-d $outDir -s $annoDir @$out.rsp @$srcJarDir/list > $out.tmp 2>&1 || ` +
+				`(cat $out.tmp && exit 1) ; fi ) && ` +
+				`touch $out.tmp && mv $out.tmp $out && ` +
+				`rm -rf "$outDir" "$annoDir" "$srcJarDir"`,
+			CommandDeps: []string{
+				"${config.JavacCmd}",
+				"${config.ZipSyncCmd}",
+			},
+			CommandOrderOnly: []string{"${config.SoongJavacWrapper}"},
+			Rspfile:          "$out.rsp",
+			RspfileContent:   "$in",
+		},
+		"javacFlags", "bootClasspath", "classpath", "processorpath", "processor", "srcJars", "srcJarDir",
+		"outDir", "annoDir", "javaVersionFlags", "jvmFlags", "javacCmd")
+
+	// Fails if the two builds of a jar differ, which means that the build isn't reproducible.
+	verifyReproducible = pctx.AndroidStaticRule("verifyReproducible",
+		blueprint.RuleParams{
+			Command: `if ! cmp -s $in $rebuilt; then ` +
+				`echo "$in and $rebuilt differ, the build is not reproducible" >&2; exit 1; fi && ` +
+				`touch $out`,
+		},
+		"rebuilt")
 
 	_ = pctx.VariableFunc("kytheCorpus",
 		func(ctx android.PackageVarContext) string { return ctx.Config().XrefCorpusName() })
@@ -101,7 +136,7 @@ var (
 				`-jar ${config.JavaKytheExtractorJar} ` +
 				`${config.JavacHeapFlags} ${config.CommonJdkFlags} ` +
 				`$processorpath $processor $javacFlags $bootClasspath $classpath ` +
-				`-source $javaVersion -target $javaVersion ` +
+				`$javaVersionFlags ` +
 				`-d $outDir -s $annoDir @$out.rsp @$srcJarDir/list)`,
 			CommandDeps: []string{
 				"${config.JavaCmd}",
@@ -114,7 +149,7 @@ var (
 			RspfileContent:   "$in",
 		},
 		"javacFlags", "bootClasspath", "classpath", "processorpath", "processor", "srcJars", "srcJarDir",
-		"outDir", "annoDir", "javaVersion")
+		"outDir", "annoDir", "javaVersionFlags")
 
 	extractMatchingApks = pctx.StaticRule(
 		"extractMatchingApks",
@@ -131,10 +166,10 @@ var (
 
 	turbine, turbineRE = pctx.RemoteStaticRules("turbine",
 		blueprint.RuleParams{
-			Command: `$reTemplate${config.JavaCmd} ${config.JavaVmFlags} -jar ${config.TurbineJar} $outputFlags ` +
+			Command: `$reTemplate${config.JavaCmd} ${config.JavaVmFlags} $jvmFlags -jar ${config.TurbineJar} $outputFlags ` +
 				`--sources @$out.rsp  --source_jars $srcJars ` +
 				`--javacopts ${config.CommonJdkFlags} ` +
-				`$javacFlags -source $javaVersion -target $javaVersion -- $turbineFlags && ` +
+				`$javacFlags $javaVersionFlags -- $turbineFlags && ` +
 				`(for o in $outputs; do if cmp -s $${o}.tmp $${o} ; then rm $${o}.tmp ; else mv $${o}.tmp $${o} ; fi; done )`,
 			CommandDeps: []string{
 				"${config.TurbineJar}",
@@ -152,7 +187,8 @@ var (
 			ToolchainInputs: []string{"${config.JavaCmd}"},
 			Platform:        map[string]string{remoteexec.PoolKey: "${config.REJavaPool}"},
 		},
-		[]string{"javacFlags", "turbineFlags", "outputFlags", "javaVersion", "outputs", "rbeOutputs", "srcJars"}, []string{"implicits"})
+		[]string{"javacFlags", "turbineFlags", "outputFlags", "javaVersionFlags", "outputs", "rbeOutputs", "srcJars", "jvmFlags"},
+		[]string{"implicits"})
 
 	jar, jarRE = pctx.RemoteStaticRules("jar",
 		blueprint.RuleParams{
@@ -191,6 +227,13 @@ var (
 		},
 		"jarArgs")
 
+	flattenJars = pctx.AndroidStaticRule("flattenJars",
+		blueprint.RuleParams{
+			Command:     `${config.MergeZipsCmd} -j $jarArgs -stripFile module-info.class $out $in`,
+			CommandDeps: []string{"${config.MergeZipsCmd}"},
+		},
+		"jarArgs")
+
 	jarjar = pctx.AndroidStaticRule("jarjar",
 		blueprint.RuleParams{
 			Command: "" +
@@ -267,6 +310,14 @@ type javaBuilderFlags struct {
 
 	processorPath classpath
 	processors    []string
+
+	// javaRelease is the version passed to javac as --release instead of -source and -target, if
+	// java_release is set.
+	javaRelease string
+
+	// javacCmd is the javac of the JDK selected with jdk_version, or nil to use the default JDK.
+	javacCmd android.Path
+
 	systemModules *systemModules
 	aidlFlags     string
 	aidlDeps      android.Paths
@@ -282,6 +333,14 @@ type javaBuilderFlags struct {
 	proto android.ProtoFlags
 }
 
+// javaVersionFlags returns the javac flags that select the Java language level.
+func (flags javaBuilderFlags) javaVersionFlags() string {
+	if flags.javaRelease != "" {
+		return "--release " + flags.javaRelease
+	}
+	return "-source " + flags.javaVersion.String() + " -target " + flags.javaVersion.String()
+}
+
 func TransformJavaToClasses(ctx android.ModuleContext, outputFile android.WritablePath, shardIdx int,
 	srcFiles, srcJars android.Paths, flags javaBuilderFlags, deps android.Paths) {
 
@@ -304,7 +363,11 @@ func emitXrefRule(ctx android.ModuleContext, xrefFile android.WritablePath, idx
 	classpath := flags.classpath
 
 	var bootClasspath string
-	if flags.javaVersion.usesJavaModules() {
+	if flags.javaRelease != "" {
+		// --release selects the platform API itself, and javac rejects it in combination with
+		// -bootclasspath or --system.
+		classpath = append(flags.java9Classpath, classpath...)
+	} else if flags.javaVersion.usesJavaModules() {
 		var systemModuleDeps android.Paths
 		bootClasspath, systemModuleDeps = flags.systemModules.FormJavaSystemModulesPath(ctx.Device())
 		deps = append(deps, systemModuleDeps...)
@@ -341,16 +404,16 @@ func emitXrefRule(ctx android.ModuleContext, xrefFile android.WritablePath, idx
 			Inputs:      srcFiles,
 			Implicits:   deps,
 			Args: map[string]string{
-				"annoDir":       android.PathForModuleOut(ctx, intermediatesDir, "anno").String(),
-				"bootClasspath": bootClasspath,
-				"classpath":     classpath.FormJavaClassPath("-classpath"),
-				"javacFlags":    flags.javacFlags,
-				"javaVersion":   flags.javaVersion.String(),
-				"outDir":        android.PathForModuleOut(ctx, "javac", "classes.xref").String(),
-				"processorpath": flags.processorPath.FormJavaClassPath("-processorpath"),
-				"processor":     processor,
-				"srcJarDir":     android.PathForModuleOut(ctx, intermediatesDir, "srcjars.xref").String(),
-				"srcJars":       strings.Join(srcJars.Strings(), " "),
+				"annoDir":          android.PathForModuleOut(ctx, intermediatesDir, "anno").String(),
+				"bootClasspath":    bootClasspath,
+				"classpath":        classpath.FormJavaClassPath("-classpath"),
+				"javacFlags":       flags.javacFlags,
+				"javaVersionFlags": flags.javaVersionFlags(),
+				"outDir":           android.PathForModuleOut(ctx, "javac", "classes.xref").String(),
+				"processorpath":    flags.processorPath.FormJavaClassPath("-processorpath"),
+				"processor":        processor,
+				"srcJarDir":        android.PathForModuleOut(ctx, intermediatesDir, "srcjars.xref").String(),
+				"srcJars":          strings.Join(srcJars.Strings(), " "),
 			},
 		})
 }
@@ -361,7 +424,11 @@ func turbineFlags(ctx android.ModuleContext, flags javaBuilderFlags) (string, an
 	classpath := flags.classpath
 
 	var bootClasspath string
-	if flags.javaVersion.usesJavaModules() {
+	if flags.javaRelease != "" {
+		// --release in the javac options selects the platform API itself, so turbine must not be
+		// given a bootclasspath or system modules either.
+		classpath = append(flags.java9Classpath, classpath...)
+	} else if flags.javaVersion.usesJavaModules() {
 		var systemModuleDeps android.Paths
 		bootClasspath, systemModuleDeps = flags.systemModules.FormTurbineSystemModulesPath(ctx.Device())
 		deps = append(deps, systemModuleDeps...)
@@ -386,32 +453,41 @@ func turbineFlags(ctx android.ModuleContext, flags javaBuilderFlags) (string, an
 func TransformJavaToHeaderClasses(ctx android.ModuleContext, outputFile android.WritablePath,
 	srcFiles, srcJars android.Paths, flags javaBuilderFlags) {
 
+	ctx.Build(pctx, turbineHeaderBuildParams(ctx, outputFile, srcFiles, srcJars, flags))
+}
+
+// turbineHeaderBuildParams returns the build statement that compiles srcFiles and srcJars into a
+// header jar with turbine.
+func turbineHeaderBuildParams(ctx android.ModuleContext, outputFile android.WritablePath,
+	srcFiles, srcJars android.Paths, flags javaBuilderFlags) android.BuildParams {
+
 	turbineFlags, deps := turbineFlags(ctx, flags)
 
 	deps = append(deps, srcJars...)
 
 	rule := turbine
 	args := map[string]string{
-		"javacFlags":   flags.javacFlags,
-		"srcJars":      strings.Join(srcJars.Strings(), " "),
-		"javaVersion":  flags.javaVersion.String(),
-		"turbineFlags": turbineFlags,
-		"outputFlags":  "--output " + outputFile.String() + ".tmp",
-		"outputs":      outputFile.String(),
+		"javacFlags":       flags.javacFlags,
+		"srcJars":          strings.Join(srcJars.Strings(), " "),
+		"javaVersionFlags": flags.javaVersionFlags(),
+		"turbineFlags":     turbineFlags,
+		"outputFlags":      "--output " + outputFile.String() + ".tmp",
+		"outputs":          outputFile.String(),
+		"jvmFlags":         javaToolJvmFlags(ctx, ""),
 	}
 	if ctx.Config().UseRBE() && ctx.Config().IsEnvTrue("RBE_TURBINE") {
 		rule = turbineRE
 		args["implicits"] = strings.Join(deps.Strings(), ",")
 		args["rbeOutputs"] = outputFile.String() + ".tmp"
 	}
-	ctx.Build(pctx, android.BuildParams{
+	return android.BuildParams{
 		Rule:        rule,
 		Description: "turbine",
 		Output:      outputFile,
 		Inputs:      srcFiles,
 		Implicits:   deps,
 		Args:        args,
-	})
+	}
 }
 
 // TurbineApt produces a rule to run annotation processors using turbine.
@@ -432,12 +508,13 @@ func TurbineApt(ctx android.ModuleContext, outputSrcJar, outputResJar android.Wr
 
 	rule := turbine
 	args := map[string]string{
-		"javacFlags":   flags.javacFlags,
-		"srcJars":      strings.Join(srcJars.Strings(), " "),
-		"javaVersion":  flags.javaVersion.String(),
-		"turbineFlags": turbineFlags,
-		"outputFlags":  outputFlags,
-		"outputs":      strings.Join(outputs.Strings(), " "),
+		"javacFlags":       flags.javacFlags,
+		"srcJars":          strings.Join(srcJars.Strings(), " "),
+		"javaVersionFlags": flags.javaVersionFlags(),
+		"turbineFlags":     turbineFlags,
+		"outputFlags":      outputFlags,
+		"outputs":          strings.Join(outputs.Strings(), " "),
+		"jvmFlags":         javaToolJvmFlags(ctx, ""),
 	}
 	if ctx.Config().UseRBE() && ctx.Config().IsEnvTrue("RBE_TURBINE") {
 		rule = turbineRE
@@ -471,10 +548,72 @@ func transformJavaToClasses(ctx android.ModuleContext, outputFile android.Writab
 
 	deps = append(deps, srcJars...)
 
+	args, argDeps := javacArgs(ctx, shardIdx, srcJars, flags, intermediatesDir)
+	deps = append(deps, argDeps...)
+
+	rule := javac
+	if ctx.Config().UseRBE() && ctx.Config().IsEnvTrue("RBE_JAVAC") {
+		rule = javacRE
+	}
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        rule,
+		Description: desc,
+		Output:      outputFile,
+		Inputs:      srcFiles,
+		Implicits:   deps,
+		Args:        args,
+	})
+}
+
+// TransformVerifyReproducible compares two builds of a jar, failing if they differ, and touches
+// the stamp file outputFile if they don't.
+func TransformVerifyReproducible(ctx android.ModuleContext, outputFile android.WritablePath,
+	jar, rebuiltJar android.Path) {
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        verifyReproducible,
+		Description: "verify reproducible",
+		Input:       jar,
+		Implicit:    rebuiltJar,
+		Output:      outputFile,
+		Args: map[string]string{
+			"rebuilt": rebuiltJar.String(),
+		},
+	})
+}
+
+// TransformJavaToErrorproneReport compiles the sources with the errorprone flags in a separate pass
+// that writes the errorprone diagnostics to outputFile instead of producing classes.
+func TransformJavaToErrorproneReport(ctx android.ModuleContext, outputFile android.WritablePath,
+	srcFiles, srcJars android.Paths, flags javaBuilderFlags) {
+
+	args, deps := javacArgs(ctx, -1, srcJars, flags, "errorprone-report")
+	deps = append(deps, srcJars...)
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        errorproneReport,
+		Description: "errorprone report",
+		Output:      outputFile,
+		Inputs:      srcFiles,
+		Implicits:   deps,
+		Args:        args,
+	})
+}
+
+// javacArgs returns the arguments of the javac rule for the given sources and flags, and the files
+// that they reference.
+func javacArgs(ctx android.ModuleContext, shardIdx int, srcJars android.Paths, flags javaBuilderFlags,
+	intermediatesDir string) (map[string]string, android.Paths) {
+
+	var deps android.Paths
 	classpath := flags.classpath
 
 	var bootClasspath string
-	if flags.javaVersion.usesJavaModules() {
+	if flags.javaRelease != "" {
+		// --release selects the platform API itself, and javac rejects it in combination with
+		// -bootclasspath or --system.
+		classpath = append(flags.java9Classpath, classpath...)
+	} else if flags.javaVersion.usesJavaModules() {
 		var systemModuleDeps android.Paths
 		bootClasspath, systemModuleDeps = flags.systemModules.FormJavaSystemModulesPath(ctx.Device())
 		deps = append(deps, systemModuleDeps...)
@@ -493,6 +632,12 @@ func transformJavaToClasses(ctx android.ModuleContext, outputFile android.Writab
 	deps = append(deps, classpath...)
 	deps = append(deps, flags.processorPath...)
 
+	javacCmd := "${config.JavacCmd}"
+	if flags.javacCmd != nil {
+		javacCmd = flags.javacCmd.String()
+		deps = append(deps, flags.javacCmd)
+	}
+
 	processor := "-proc:none"
 	if len(flags.processors) > 0 {
 		processor = "-processor " + strings.Join(flags.processors, ",")
@@ -507,29 +652,53 @@ func transformJavaToClasses(ctx android.ModuleContext, outputFile android.Writab
 		outDir = filepath.Join(shardDir, outDir)
 		annoDir = filepath.Join(shardDir, annoDir)
 	}
-	rule := javac
-	if ctx.Config().UseRBE() && ctx.Config().IsEnvTrue("RBE_JAVAC") {
-		rule = javacRE
+
+	return map[string]string{
+		"javacFlags":       flags.javacFlags,
+		"bootClasspath":    bootClasspath,
+		"classpath":        classpath.FormJavaClassPath("-classpath"),
+		"processorpath":    flags.processorPath.FormJavaClassPath("-processorpath"),
+		"processor":        processor,
+		"srcJars":          strings.Join(srcJars.Strings(), " "),
+		"srcJarDir":        android.PathForModuleOut(ctx, intermediatesDir, srcJarDir).String(),
+		"outDir":           android.PathForModuleOut(ctx, intermediatesDir, outDir).String(),
+		"annoDir":          android.PathForModuleOut(ctx, intermediatesDir, annoDir).String(),
+		"javaVersionFlags": flags.javaVersionFlags(),
+		"jvmFlags":         javaToolJvmFlags(ctx, "-J"),
+		"javacCmd":         javacCmd,
+	}, deps
+}
+
+// javacCommandLine returns the javac command line compiling the given sources and srcjars with the
+// given flags, as passed to the javac rule. The JDK flags common to all modules and the wrappers
+// around javac are left out, and an absolute output directory is replaced with $OUT_DIR so that
+// the command line doesn't depend on where the tree is checked out.
+func javacCommandLine(ctx android.ModuleContext, shardIdx int, srcFiles, srcJars android.Paths,
+	flags javaBuilderFlags) string {
+
+	args, _ := javacArgs(ctx, shardIdx, srcJars, flags, "javac")
+	var command []string
+	for _, arg := range []string{"javac", args["jvmFlags"], args["processorpath"], args["processor"],
+		args["javacFlags"], args["bootClasspath"], args["classpath"], args["javaVersionFlags"],
+		"-d " + args["outDir"], "-s " + args["annoDir"]} {
+		if arg != "" {
+			command = append(command, arg)
+		}
 	}
-	ctx.Build(pctx, android.BuildParams{
-		Rule:        rule,
-		Description: desc,
-		Output:      outputFile,
-		Inputs:      srcFiles,
-		Implicits:   deps,
-		Args: map[string]string{
-			"javacFlags":    flags.javacFlags,
-			"bootClasspath": bootClasspath,
-			"classpath":     classpath.FormJavaClassPath("-classpath"),
-			"processorpath": flags.processorPath.FormJavaClassPath("-processorpath"),
-			"processor":     processor,
-			"srcJars":       strings.Join(srcJars.Strings(), " "),
-			"srcJarDir":     android.PathForModuleOut(ctx, intermediatesDir, srcJarDir).String(),
-			"outDir":        android.PathForModuleOut(ctx, intermediatesDir, outDir).String(),
-			"annoDir":       android.PathForModuleOut(ctx, intermediatesDir, annoDir).String(),
-			"javaVersion":   flags.javaVersion.String(),
-		},
-	})
+	command = append(command, srcFiles.Strings()...)
+	command = append(command, srcJars.Strings()...)
+
+	commandLine := strings.Join(command, " ")
+	if outDir := ctx.Config().OutDir(); filepath.IsAbs(outDir) {
+		commandLine = strings.ReplaceAll(commandLine, outDir, "$OUT_DIR")
+	}
+	return commandLine
+}
+
+// javaToolJvmFlags returns the extra JVM arguments from the JavaToolJvmArgs product variable, each
+// given the prefix that the tool uses to forward arguments to its JVM.
+func javaToolJvmFlags(ctx android.PathContext, prefix string) string {
+	return android.JoinWithPrefix(ctx.Config().JavaToolJvmArgs(), prefix)
 }
 
 func TransformResourcesToJar(ctx android.ModuleContext, outputFile android.WritablePath,
@@ -554,6 +723,15 @@ func TransformJarsToJar(ctx android.ModuleContext, outputFile android.WritablePa
 	jars android.Paths, manifest android.OptionalPath, stripDirEntries bool, filesToStrip []string,
 	dirsToStrip []string) {
 
+	ctx.Build(pctx, jarsToJarBuildParams(outputFile, desc, jars, manifest, stripDirEntries,
+		filesToStrip, dirsToStrip))
+}
+
+// jarsToJarBuildParams returns the build statement that merges jars into outputFile.
+func jarsToJarBuildParams(outputFile android.WritablePath, desc string, jars android.Paths,
+	manifest android.OptionalPath, stripDirEntries bool, filesToStrip []string,
+	dirsToStrip []string) android.BuildParams {
+
 	var deps android.Paths
 
 	var jarArgs []string
@@ -578,7 +756,7 @@ func TransformJarsToJar(ctx android.ModuleContext, outputFile android.WritablePa
 		jarArgs = append(jarArgs, "-D")
 	}
 
-	ctx.Build(pctx, android.BuildParams{
+	return android.BuildParams{
 		Rule:        combineJar,
 		Description: desc,
 		Output:      outputFile,
@@ -587,6 +765,34 @@ func TransformJarsToJar(ctx android.ModuleContext, outputFile android.WritablePa
 		Args: map[string]string{
 			"jarArgs": strings.Join(jarArgs, " "),
 		},
+	}
+}
+
+// TransformJarsToFatJar merges the given jars into a single jar with the entries sorted in jar
+// order. If ignoreDuplicates is false, the merge fails if two jars contain different versions of
+// the same entry.
+func TransformJarsToFatJar(ctx android.ModuleContext, outputFile android.WritablePath,
+	jars android.Paths, manifest android.OptionalPath, ignoreDuplicates bool) {
+
+	var deps android.Paths
+	var jarArgs []string
+	if manifest.Valid() {
+		jarArgs = append(jarArgs, "-m", manifest.String())
+		deps = append(deps, manifest.Path())
+	}
+	if ignoreDuplicates {
+		jarArgs = append(jarArgs, "--ignore-duplicates")
+	}
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        flattenJars,
+		Description: "flatten static libs",
+		Output:      outputFile,
+		Inputs:      jars,
+		Implicits:   deps,
+		Args: map[string]string{
+			"jarArgs": strings.Join(jarArgs, " "),
+		},
 	})
 }
 
@@ -692,6 +898,9 @@ func (x *classpath) Strings() []string {
 type systemModules struct {
 	dir  android.Path
 	deps android.Paths
+
+	// The header jars of the libraries in the system modules, for tools that take a bootclasspath.
+	headerJars android.Paths
 }
 
 // Returns a --system argument in the form javac expects with -source 1.9 and the list of files to
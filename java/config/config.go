@@ -114,6 +114,7 @@ func init() {
 	pctx.SourcePathVariable("JavaCmd", "${JavaToolchain}/java")
 	pctx.SourcePathVariable("JarCmd", "${JavaToolchain}/jar")
 	pctx.SourcePathVariable("JavadocCmd", "${JavaToolchain}/javadoc")
+	pctx.SourcePathVariable("JavapCmd", "${JavaToolchain}/javap")
 	pctx.SourcePathVariable("JlinkCmd", "${JavaToolchain}/jlink")
 	pctx.SourcePathVariable("JmodCmd", "${JavaToolchain}/jmod")
 	pctx.SourcePathVariable("JrtFsJar", "${JavaHome}/lib/jrt-fs.jar")
@@ -228,6 +229,23 @@ func hostBinToolVariableWithBuildToolsPrebuilt(name, tool string) {
 	})
 }
 
+// The prebuilt JDKs that modules can select with jdk_version, by version.
+var jdkHomes = map[string]string{
+	"11": "prebuilts/jdk/jdk11",
+	"17": "prebuilts/jdk/jdk17",
+}
+
+// JdkVersions returns the JDK versions that modules can select with jdk_version.
+func JdkVersions() []string {
+	return android.SortedStringKeys(jdkHomes)
+}
+
+// JdkTool returns a SourcePath object with the path to a tool of the prebuilt JDK of the given
+// version, which must be one of JdkVersions.
+func JdkTool(ctx android.PathContext, version, tool string) android.SourcePath {
+	return android.PathForSource(ctx, jdkHomes[version], ctx.Config().PrebuiltOS(), "bin", tool)
+}
+
 // JavaCmd returns a SourcePath object with the path to the java command.
 func JavaCmd(ctx android.PathContext) android.SourcePath {
 	return javaTool(ctx, "java")
@@ -238,6 +256,11 @@ func JavadocCmd(ctx android.PathContext) android.SourcePath {
 	return javaTool(ctx, "javadoc")
 }
 
+// JavapCmd returns a SourcePath object with the path to the javap command.
+func JavapCmd(ctx android.PathContext) android.SourcePath {
+	return javaTool(ctx, "javap")
+}
+
 func javaTool(ctx android.PathContext, tool string) android.SourcePath {
 	type javaToolKey string
 
@@ -0,0 +1,129 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+// Rules for aggregating jacoco coverage data from several modules into a single report.
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+var (
+	jacocoReport = pctx.AndroidStaticRule("jacocoReport", blueprint.RuleParams{
+		Command: `rm -rf $htmlDir && mkdir -p $htmlDir && ` +
+			`${config.JavaCmd} ${config.JavaVmFlags} -jar ${config.JacocoCLIJar} ` +
+			`  report --quiet $execFiles $classFiles --name $reportName --xml $xml --html $htmlDir && ` +
+			`${config.SoongZipCmd} -o $out -C $htmlDir -D $htmlDir`,
+		CommandDeps: []string{
+			"${config.JavaCmd}",
+			"${config.JacocoCLIJar}",
+			"${config.SoongZipCmd}",
+		},
+	},
+		"execFiles", "classFiles", "reportName", "xml", "htmlDir")
+)
+
+var coverageReportModuleTag = dependencyTag{name: "coverage-report-module"}
+
+type coverageReportProperties struct {
+	// List of instrumented java modules whose classes are included in the report. Modules that
+	// are not instrumented in the current build are ignored.
+	Modules []string
+
+	// List of jacoco execution data (.exec) files to report on.
+	Exec_files []string `android:"path"`
+}
+
+type CoverageReport struct {
+	android.ModuleBase
+
+	properties coverageReportProperties
+
+	htmlZip android.Path
+	xml     android.Path
+}
+
+// java_coverage_report aggregates the jacoco coverage data of a set of instrumented java modules
+// into a single report, using the class files recorded when the modules were instrumented.  It
+// produces a zip of the HTML report and, with the ".xml" tag, an XML report.  Nothing is built if
+// none of the listed modules are instrumented.
+func CoverageReportFactory() android.Module {
+	module := &CoverageReport{}
+	module.AddProperties(&module.properties)
+	android.InitAndroidArchModule(module, android.HostAndDeviceSupported, android.MultilibCommon)
+	return module
+}
+
+func (r *CoverageReport) DepsMutator(ctx android.BottomUpMutatorContext) {
+	ctx.AddVariationDependencies(nil, coverageReportModuleTag, r.properties.Modules...)
+}
+
+func (r *CoverageReport) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	var classJars android.Paths
+	ctx.VisitDirectDepsWithTag(coverageReportModuleTag, func(module android.Module) {
+		if !ctx.OtherModuleHasProvider(module, JavaInfoProvider) {
+			ctx.PropertyErrorf("modules", "%q is not a java module", ctx.OtherModuleName(module))
+			return
+		}
+		dep := ctx.OtherModuleProvider(module, JavaInfoProvider).(JavaInfo)
+		if dep.JacocoReportClassesFile != nil {
+			classJars = append(classJars, dep.JacocoReportClassesFile)
+		}
+	})
+	if len(classJars) == 0 {
+		return
+	}
+
+	execFiles := android.PathsForModuleSrc(ctx, r.properties.Exec_files)
+	htmlZip := android.PathForModuleOut(ctx, ctx.ModuleName()+"-html.zip")
+	xml := android.PathForModuleOut(ctx, ctx.ModuleName()+".xml")
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:           jacocoReport,
+		Description:    "jacoco report",
+		Output:         htmlZip,
+		ImplicitOutput: xml,
+		Inputs:         execFiles,
+		Implicits:      classJars,
+		Args: map[string]string{
+			"execFiles":  strings.Join(execFiles.Strings(), " "),
+			"classFiles": android.JoinWithPrefix(classJars.Strings(), "--classfiles "),
+			"reportName": ctx.ModuleName(),
+			"xml":        xml.String(),
+			"htmlDir":    android.PathForModuleOut(ctx, "html").String(),
+		},
+	})
+
+	r.htmlZip = htmlZip
+	r.xml = xml
+}
+
+func (r *CoverageReport) OutputFiles(tag string) (android.Paths, error) {
+	switch tag {
+	case "":
+		return android.PathsIfNonNil(r.htmlZip), nil
+	case ".xml":
+		return android.PathsIfNonNil(r.xml), nil
+	default:
+		return nil, fmt.Errorf("unsupported module reference tag %q", tag)
+	}
+}
+
+var _ android.OutputFileProducer = (*CoverageReport)(nil)
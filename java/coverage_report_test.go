@@ -0,0 +1,78 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+const coverageReportBp = `
+	java_coverage_report {
+		name: "report",
+		modules: ["foo", "bar", "baz"],
+		exec_files: ["coverage.exec"],
+	}
+
+	android_app {
+		name: "foo",
+		srcs: ["a.java"],
+		sdk_version: "current",
+	}
+
+	android_app {
+		name: "bar",
+		srcs: ["b.java"],
+		sdk_version: "current",
+	}
+
+	java_library {
+		name: "baz",
+		srcs: ["c.java"],
+	}
+`
+
+func TestCoverageReport(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+		android.FixtureMergeEnv(map[string]string{
+			"EMMA_INSTRUMENT": "true",
+		}),
+	).RunTestWithBp(t, coverageReportBp)
+
+	report := result.ModuleForTests("report", "android_common").Rule("jacocoReport")
+
+	android.AssertPathsRelativeToTopEquals(t, "class jars", []string{
+		"out/soong/.intermediates/foo/android_common/jacoco-report-classes/foo.jar",
+		"out/soong/.intermediates/bar/android_common/jacoco-report-classes/bar.jar",
+	}, report.Implicits)
+	android.AssertPathsRelativeToTopEquals(t, "exec files", []string{"coverage.exec"}, report.Inputs)
+	android.AssertPathRelativeToTopEquals(t, "html report",
+		"out/soong/.intermediates/report/android_common/report-html.zip", report.Output)
+	android.AssertPathRelativeToTopEquals(t, "xml report",
+		"out/soong/.intermediates/report/android_common/report.xml", report.ImplicitOutput)
+	android.AssertStringDoesContain(t, "class files flags", report.Args["classFiles"],
+		"--classfiles out/soong/.intermediates/foo/android_common/jacoco-report-classes/foo.jar")
+}
+
+func TestCoverageReportWithoutInstrumentation(t *testing.T) {
+	result := PrepareForTestWithJavaDefaultModules.RunTestWithBp(t, coverageReportBp)
+
+	report := result.ModuleForTests("report", "android_common")
+	if rule := report.MaybeRule("jacocoReport"); rule.Rule != nil {
+		t.Errorf("expected no report to be built without instrumented modules")
+	}
+}
@@ -15,8 +15,10 @@
 package java
 
 import (
+	"regexp"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/google/blueprint"
 	"github.com/google/blueprint/proptools"
@@ -65,8 +67,33 @@ type DexProperties struct {
 
 		// Specifies the locations of files containing proguard flags.
 		Proguard_flags_files []string `android:"path"`
+
+		// Specifies the location of the mapping file of a previous release, which r8 reuses to
+		// keep the obfuscated names stable across releases.
+		Apply_mapping *string `android:"path"`
+
+		// Specifies the location of a file listing the names to use for obfuscated fields and
+		// methods.
+		Obfuscation_dictionary *string `android:"path"`
+
+		// Specifies the location of a file listing the names to use for obfuscated classes.
+		Class_obfuscation_dictionary *string `android:"path"`
+
+		// Fully qualified names of classes to keep, along with their names, for example because
+		// they are accessed through reflection.  Wildcards are supported as in proguard class
+		// specifications, e.g. "com.example.reflect.**".
+		Keep_classes []string
+
+		// Members to keep in the form "<fully qualified class name>#<member name>", for example
+		// "com.example.Foo#bar".  Both fields and methods with the given name are kept, along
+		// with the class that contains them.
+		Keep_members []string
 	}
 
+	// Specifies the locations of files containing proguard flags that are used when optimizing any
+	// app that statically includes this module, like the consumer proguard rules of an AAR.
+	Export_proguard_flags_files []string `android:"path"`
+
 	// Keep the data uncompressed. We always need uncompressed dex for execution,
 	// so this might actually save space by avoiding storing the same data twice.
 	// This defaults to reasonable value based on module and should not be set.
@@ -75,6 +102,34 @@ type DexProperties struct {
 
 	// Exclude kotlinc generate files: *.kotlin_module, *.kotlin_builtins. Defaults to false.
 	Exclude_kotlinc_generated_files *bool
+
+	// If true, force d8/r8 to produce deterministically ordered output by compiling
+	// single-threaded, for reproducible build verification. Defaults to false.
+	Deterministic_dex *bool
+
+	Dex_options struct {
+		// The maximum heap size of the JVM running d8 or r8, in the format of the -Xmx JVM flag,
+		// e.g. "4g".
+		Max_heap_size *string
+
+		// The number of threads used by d8 or r8.  Defaults to the number of cores.
+		Threads *int64
+	}
+
+	Check_method_count struct {
+		// If set, fail the build when the primary dex file references more methods than this, to
+		// catch modules approaching the 64K method limit early.
+		Max *int64
+	}
+
+	// If true, write a report of the size of the dex code broken down by package, exposed through
+	// the ".dex-size-report" output tag.  Defaults to false.
+	Write_dex_size_report *bool
+
+	// If true, also compile a debuggable dex jar with d8 --debug and no optimization next to the
+	// regular, optimized one.  They are exposed through the ".dex.debug" and ".dex.release" output
+	// tags.  Defaults to false.
+	Dual_dex *bool
 }
 
 type dexer struct {
@@ -84,12 +139,31 @@ type dexer struct {
 	extraProguardFlagFiles android.Paths
 	proguardDictionary     android.OptionalPath
 	proguardUsageZip       android.OptionalPath
+	dexSizeReport          android.OptionalPath
+
+	// the debug and release dex jars, if dual_dex is set
+	debugDexJar   android.OptionalPath
+	releaseDexJar android.OptionalPath
 }
 
 func (d *dexer) effectiveOptimizeEnabled() bool {
 	return BoolDefault(d.dexProperties.Optimize.Enabled, d.dexProperties.Optimize.EnabledByDefault)
 }
 
+// dexHeapSizeRegexp matches the sizes accepted by the -Xmx JVM flag.
+var dexHeapSizeRegexp = regexp.MustCompile(`^[0-9]+[kKmMgG]?$`)
+
+var checkDexMethodCount = pctx.AndroidStaticRule("checkDexMethodCount",
+	blueprint.RuleParams{
+		// The number of method ids is the little endian uint32 at offset 0x58 of the dex header.
+		Command: `count=$$(unzip -p $in classes.dex | od -An -t u4 -j 88 -N 4 | tr -d ' ') && ` +
+			`if [ "$$count" -gt $max ]; then ` +
+			`echo "$in: the primary dex file references $$count methods, more than the maximum of $max set by check_method_count" >&2; ` +
+			`exit 1; ` +
+			`fi && echo $$count > $out`,
+	},
+	"max")
+
 var d8, d8RE = pctx.MultiCommandRemoteStaticRules("d8",
 	blueprint.RuleParams{
 		Command: `rm -rf "$outDir" && mkdir -p "$outDir" && ` +
@@ -197,12 +271,41 @@ func (d *dexer) dexCommonFlags(ctx android.ModuleContext,
 			"--verbose")
 	}
 
+	dexOptions := d.dexProperties.Dex_options
+	if proptools.Bool(d.dexProperties.Deterministic_dex) {
+		if dexOptions.Threads != nil {
+			ctx.PropertyErrorf("dex_options.threads", "cannot be set together with deterministic_dex")
+		}
+		// The order of classes in the output dex files depends on the order in
+		// which the worker threads finish; a single thread makes it stable.
+		flags = append(flags, "--thread-count 1")
+	} else if threads := dexOptions.Threads; threads != nil {
+		if *threads <= 0 {
+			ctx.PropertyErrorf("dex_options.threads", "must be positive, got %d", *threads)
+		}
+		flags = append(flags, "--thread-count "+strconv.FormatInt(*threads, 10))
+	}
+
 	effectiveVersion, err := minSdkVersion.EffectiveVersion(ctx)
 	if err != nil {
 		ctx.PropertyErrorf("min_sdk_version", "%s", err)
 	}
 
 	flags = append(flags, "--min-api "+strconv.Itoa(effectiveVersion.FinalOrFutureInt()))
+
+	// The d8 and r8 wrappers forward -J<arg> to the JVM as -<arg>.
+	for _, arg := range ctx.Config().JavaToolJvmArgs() {
+		flags = append(flags, "-J"+strings.TrimPrefix(arg, "-"))
+	}
+
+	// The heap size comes after the global JVM args so that it takes precedence over them.
+	if heapSize := d.dexProperties.Dex_options.Max_heap_size; heapSize != nil {
+		if !dexHeapSizeRegexp.MatchString(*heapSize) {
+			ctx.PropertyErrorf("dex_options.max_heap_size",
+				"invalid heap size %q, expected a size like \"4g\" or \"512m\"", *heapSize)
+		}
+		flags = append(flags, "-JXmx"+*heapSize)
+	}
 	return flags, deps
 }
 
@@ -216,6 +319,57 @@ func d8Flags(flags javaBuilderFlags) (d8Flags []string, d8Deps android.Paths) {
 	return d8Flags, d8Deps
 }
 
+// keepRules returns the proguard rules that keep the classes and members listed in
+// optimize.keep_classes and optimize.keep_members.
+func (d *dexer) keepRules(ctx android.ModuleContext) []string {
+	opt := d.dexProperties.Optimize
+
+	var rules []string
+	for _, class := range opt.Keep_classes {
+		if !isProguardClassName(class) {
+			ctx.PropertyErrorf("optimize.keep_classes", "invalid class name %q", class)
+			continue
+		}
+		rules = append(rules, "-keep class "+class)
+	}
+	for _, member := range opt.Keep_members {
+		parts := strings.Split(member, "#")
+		if len(parts) != 2 || !isProguardClassName(parts[0]) || !isProguardIdentifier(parts[1]) {
+			ctx.PropertyErrorf("optimize.keep_members",
+				"invalid member %q, expected <fully qualified class name>#<member name>", member)
+			continue
+		}
+		class, name := parts[0], parts[1]
+		rules = append(rules, "-keep class "+class+" {\n    *** "+name+";\n    *** "+name+"(...);\n}")
+	}
+	return rules
+}
+
+// isProguardClassName returns true if s is a fully qualified class name, possibly containing the
+// wildcards supported in proguard class specifications.
+func isProguardClassName(s string) bool {
+	for _, part := range strings.Split(s, ".") {
+		if !isProguardIdentifier(part) {
+			return false
+		}
+	}
+	return true
+}
+
+// isProguardIdentifier returns true if s is a Java identifier, possibly containing the wildcards
+// supported in proguard class specifications.
+func isProguardIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r == '_' || r == '$' || r == '*' || r == '?' || unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
 func (d *dexer) r8Flags(ctx android.ModuleContext, flags javaBuilderFlags) (r8Flags []string, r8Deps android.Paths) {
 	opt := d.dexProperties.Optimize
 
@@ -249,6 +403,12 @@ func (d *dexer) r8Flags(ctx android.ModuleContext, flags javaBuilderFlags) (r8Fl
 
 	flagFiles = append(flagFiles, android.PathsForModuleSrc(ctx, opt.Proguard_flags_files)...)
 
+	if keepRules := d.keepRules(ctx); len(keepRules) > 0 {
+		keepFlags := android.PathForModuleOut(ctx, "proguard", "keep.flags")
+		android.WriteFileRule(ctx, keepFlags, strings.Join(keepRules, "\n"))
+		flagFiles = append(flagFiles, keepFlags)
+	}
+
 	r8Flags = append(r8Flags, android.JoinWithPrefix(flagFiles.Strings(), "-include "))
 	r8Deps = append(r8Deps, flagFiles...)
 
@@ -258,6 +418,21 @@ func (d *dexer) r8Flags(ctx android.ModuleContext, flags javaBuilderFlags) (r8Fl
 
 	r8Flags = append(r8Flags, opt.Proguard_flags...)
 
+	for _, f := range []struct {
+		flag string
+		file *string
+	}{
+		{"-applymapping", opt.Apply_mapping},
+		{"-obfuscationdictionary", opt.Obfuscation_dictionary},
+		{"-classobfuscationdictionary", opt.Class_obfuscation_dictionary},
+	} {
+		if f.file != nil {
+			path := android.PathForModuleSrc(ctx, *f.file)
+			r8Flags = append(r8Flags, f.flag, path.String())
+			r8Deps = append(r8Deps, path)
+		}
+	}
+
 	if BoolDefault(opt.Proguard_compatibility, true) {
 		r8Flags = append(r8Flags, "--force-proguard-compatibility")
 	} else {
@@ -383,5 +558,93 @@ func (d *dexer) compileDex(ctx android.ModuleContext, flags javaBuilderFlags, mi
 		javalibJar = alignedJavalibJar
 	}
 
+	if maxMethods := d.dexProperties.Check_method_count.Max; maxMethods != nil {
+		if *maxMethods <= 0 {
+			ctx.PropertyErrorf("check_method_count.max", "must be positive, got %d", *maxMethods)
+			return javalibJar
+		}
+		methodCountFile := android.PathForModuleOut(ctx, "method-count", "method-count.txt")
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        checkDexMethodCount,
+			Description: "check dex method count",
+			Input:       javalibJar,
+			Output:      methodCountFile,
+			Args: map[string]string{
+				"max": strconv.FormatInt(*maxMethods, 10),
+			},
+		})
+
+		// Copy the dex jar to another path with a validation dependency on the method count check, so
+		// that any dependency on the dex jar causes ninja to run the check.
+		checkedJavalibJar := android.PathForModuleOut(ctx, "method-count", jarName).OutputPath
+		ctx.Build(pctx, android.BuildParams{
+			Rule:       android.Cp,
+			Input:      javalibJar,
+			Output:     checkedJavalibJar,
+			Validation: methodCountFile,
+		})
+		javalibJar = checkedJavalibJar
+	}
+
+	if proptools.Bool(d.dexProperties.Write_dex_size_report) {
+		d.dexSizeReport = android.OptionalPathForPath(buildDexSizeReport(ctx, javalibJar))
+	}
+
+	if proptools.Bool(d.dexProperties.Dual_dex) {
+		debugFlags := append(append([]string{}, commonFlags...), "--debug")
+		d.debugDexJar = android.OptionalPathForPath(d.compileDebugDex(ctx, flags, debugFlags, commonDeps,
+			classesJar, jarName, zipFlags, mergeZipsFlags))
+		d.releaseDexJar = android.OptionalPathForPath(javalibJar)
+	}
+
 	return javalibJar
 }
+
+// compileDebugDex compiles classes.jar with d8 and the given flags, without any optimization, into
+// a separate debug dex jar.
+func (d *dexer) compileDebugDex(ctx android.ModuleContext, flags javaBuilderFlags, commonFlags []string,
+	commonDeps android.Paths, classesJar android.Path, jarName, zipFlags, mergeZipsFlags string) android.Path {
+
+	debugJar := android.PathForModuleOut(ctx, "dex-debug", jarName)
+	outDir := android.PathForModuleOut(ctx, "dex-debug", "dex")
+	tmpJar := android.PathForModuleOut(ctx, "withres-withoutdex-debug", jarName)
+
+	d8Flags, d8Deps := d8Flags(flags)
+	d8Deps = append(d8Deps, commonDeps...)
+	rule := d8
+	if ctx.Config().UseRBE() && ctx.Config().IsEnvTrue("RBE_D8") {
+		rule = d8RE
+	}
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        rule,
+		Description: "d8 debug",
+		Output:      debugJar,
+		Input:       classesJar,
+		Implicits:   d8Deps,
+		Args: map[string]string{
+			"d8Flags":        strings.Join(append(commonFlags, d8Flags...), " "),
+			"zipFlags":       zipFlags,
+			"outDir":         outDir.String(),
+			"tmpJar":         tmpJar.String(),
+			"mergeZipsFlags": mergeZipsFlags,
+		},
+	})
+
+	return debugJar
+}
+
+// buildDexSizeReport writes a report of the size of the dex code in the given jar broken down by
+// package.
+func buildDexSizeReport(ctx android.ModuleContext, dexJar android.Path) android.Path {
+	report := android.PathForModuleOut(ctx, "dex-size-report", ctx.ModuleName()+".txt")
+
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().
+		BuiltTool("dex_size_report").
+		FlagWithInput("--dexdump ", ctx.Config().HostToolPath(ctx, "dexdump")).
+		FlagWithOutput("--output ", report).
+		Input(dexJar)
+	rule.Build("dex_size_report", "dex size report")
+
+	return report
+}
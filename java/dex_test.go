@@ -15,6 +15,7 @@
 package java
 
 import (
+	"strings"
 	"testing"
 
 	"android/soong/android"
@@ -101,3 +102,334 @@ func TestD8(t *testing.T) {
 	android.AssertStringDoesNotContain(t, "expected no  static_lib header jar in foo javac classpath",
 		fooD8.Args["d8Flags"], staticLibHeader.String())
 }
+
+func TestDeterministicDex(t *testing.T) {
+	result := PrepareForTestWithJavaDefaultModulesWithoutFakeDex2oatd.RunTestWithBp(t, `
+		android_app {
+			name: "app",
+			srcs: ["foo.java"],
+			platform_apis: true,
+			deterministic_dex: true,
+		}
+
+		java_library {
+			name: "foo",
+			srcs: ["foo.java"],
+			installable: true,
+			deterministic_dex: true,
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["foo.java"],
+			installable: true,
+		}
+	`)
+
+	appR8 := result.ModuleForTests("app", "android_common").Rule("r8")
+	fooD8 := result.ModuleForTests("foo", "android_common").Rule("d8")
+	barD8 := result.ModuleForTests("bar", "android_common").Rule("d8")
+
+	android.AssertStringDoesContain(t, "expected determinism flag in app r8 flags",
+		appR8.Args["r8Flags"], "--thread-count 1")
+	android.AssertStringDoesContain(t, "expected determinism flag in foo d8 flags",
+		fooD8.Args["d8Flags"], "--thread-count 1")
+	android.AssertStringDoesNotContain(t, "expected no determinism flag in bar d8 flags",
+		barD8.Args["d8Flags"], "--thread-count 1")
+}
+
+func TestDexOptions(t *testing.T) {
+	result := PrepareForTestWithJavaDefaultModulesWithoutFakeDex2oatd.RunTestWithBp(t, `
+		android_app {
+			name: "app",
+			srcs: ["foo.java"],
+			platform_apis: true,
+			dex_options: {
+				max_heap_size: "4g",
+				threads: 8,
+			},
+		}
+
+		java_library {
+			name: "foo",
+			srcs: ["foo.java"],
+			installable: true,
+			dex_options: {
+				max_heap_size: "2048m",
+				threads: 2,
+			},
+		}
+	`)
+
+	appR8 := result.ModuleForTests("app", "android_common").Rule("r8")
+	fooD8 := result.ModuleForTests("foo", "android_common").Rule("d8")
+
+	android.AssertStringDoesContain(t, "app r8 heap size", appR8.Args["r8Flags"], "-JXmx4g")
+	android.AssertStringDoesContain(t, "app r8 threads", appR8.Args["r8Flags"], "--thread-count 8")
+	android.AssertStringDoesContain(t, "foo d8 heap size", fooD8.Args["d8Flags"], "-JXmx2048m")
+	android.AssertStringDoesContain(t, "foo d8 threads", fooD8.Args["d8Flags"], "--thread-count 2")
+}
+
+func TestDexOptionsErrors(t *testing.T) {
+	PrepareForTestWithJavaDefaultModulesWithoutFakeDex2oatd.
+		ExtendWithErrorHandler(android.FixtureExpectsAllErrorsToMatchAPattern([]string{
+			`dex_options.threads: must be positive, got 0`,
+			`dex_options.max_heap_size: invalid heap size "lots"`,
+			`dex_options.threads: cannot be set together with deterministic_dex`,
+		})).
+		RunTestWithBp(t, `
+			java_library {
+				name: "foo",
+				srcs: ["foo.java"],
+				installable: true,
+				dex_options: {
+					max_heap_size: "lots",
+					threads: 0,
+				},
+			}
+
+			java_library {
+				name: "bar",
+				srcs: ["foo.java"],
+				installable: true,
+				deterministic_dex: true,
+				dex_options: {
+					threads: 4,
+				},
+			}
+		`)
+}
+
+func TestR8ObfuscationMappingAndDictionaries(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModulesWithoutFakeDex2oatd,
+		android.FixtureMergeMockFs(android.MockFS{
+			"prev-mapping.txt": nil,
+			"dictionary.txt":   nil,
+			"classes.txt":      nil,
+		}),
+	).RunTestWithBp(t, `
+		android_app {
+			name: "app",
+			srcs: ["foo.java"],
+			platform_apis: true,
+			optimize: {
+				obfuscate: true,
+				apply_mapping: "prev-mapping.txt",
+				obfuscation_dictionary: "dictionary.txt",
+				class_obfuscation_dictionary: "classes.txt",
+			},
+		}
+	`)
+
+	appR8 := result.ModuleForTests("app", "android_common").Rule("r8")
+	for _, expected := range []string{
+		"-applymapping prev-mapping.txt",
+		"-obfuscationdictionary dictionary.txt",
+		"-classobfuscationdictionary classes.txt",
+	} {
+		android.AssertStringDoesContain(t, "r8 flags", appR8.Args["r8Flags"], expected)
+	}
+	for _, input := range []string{"prev-mapping.txt", "dictionary.txt", "classes.txt"} {
+		android.AssertStringListContains(t, "r8 implicits", appR8.Implicits.Strings(), input)
+	}
+}
+
+func TestR8ObfuscationDictionaryMissing(t *testing.T) {
+	android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModulesWithoutFakeDex2oatd,
+		android.PrepareForTestDisallowNonExistentPaths,
+		android.FixtureMergeMockFs(android.MockFS{
+			"foo.java":                                   nil,
+			"AndroidManifest.xml":                        nil,
+			"build/make/core/proguard.flags":             nil,
+			"build/make/core/proguard_basic_keeps.flags": nil,
+		}),
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`module source path "missing.txt" does not exist`)).
+		RunTestWithBp(t, `
+			android_app {
+				name: "app",
+				srcs: ["foo.java"],
+				platform_apis: true,
+				optimize: {
+					obfuscation_dictionary: "missing.txt",
+				},
+			}
+		`)
+}
+
+func TestCheckMethodCount(t *testing.T) {
+	result := PrepareForTestWithJavaDefaultModulesWithoutFakeDex2oatd.RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["foo.java"],
+			installable: true,
+			check_method_count: {
+				max: 60000,
+			},
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["foo.java"],
+			installable: true,
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	check := foo.Rule("checkDexMethodCount")
+	android.AssertStringEquals(t, "max", "60000", check.Args["max"])
+
+	// The checked dex jar is a copy of the dex jar with a validation dependency on the check.
+	checked := foo.Output("method-count/foo.jar")
+	android.AssertPathRelativeToTopEquals(t, "check input", checked.Input.String(), check.Input)
+	android.AssertPathRelativeToTopEquals(t, "validation",
+		"out/soong/.intermediates/foo/android_common/method-count/method-count.txt", checked.Validation)
+
+	bar := result.ModuleForTests("bar", "android_common")
+	if rule := bar.MaybeRule("checkDexMethodCount").Rule; rule != nil {
+		t.Errorf("expected no method count check without check_method_count, got %q", rule)
+	}
+}
+
+func TestCheckMethodCountErrors(t *testing.T) {
+	PrepareForTestWithJavaDefaultModulesWithoutFakeDex2oatd.
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`check_method_count.max: must be positive, got 0`)).
+		RunTestWithBp(t, `
+			java_library {
+				name: "foo",
+				srcs: ["foo.java"],
+				installable: true,
+				check_method_count: {
+					max: 0,
+				},
+			}
+		`)
+}
+
+func TestDexSizeReport(t *testing.T) {
+	result := PrepareForTestWithJavaDefaultModulesWithoutFakeDex2oatd.RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["foo.java"],
+			installable: true,
+			write_dex_size_report: true,
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["foo.java"],
+			installable: true,
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	d8 := foo.Rule("d8")
+	report := foo.Output("dex-size-report/foo.txt")
+	android.AssertStringListContains(t, "report inputs", report.Inputs.Strings(), d8.Output.String())
+	android.AssertStringDoesContain(t, "report command", report.RuleParams.Command, "dex_size_report")
+
+	outputFiles, err := foo.Module().(*Library).OutputFiles(".dex-size-report")
+	if err != nil {
+		t.Fatalf("unexpected error getting .dex-size-report: %s", err)
+	}
+	android.AssertPathsRelativeToTopEquals(t, ".dex-size-report",
+		[]string{"out/soong/.intermediates/foo/android_common/dex-size-report/foo.txt"}, outputFiles)
+
+	bar := result.ModuleForTests("bar", "android_common")
+	if rule := bar.MaybeOutput("dex-size-report/bar.txt").Rule; rule != nil {
+		t.Errorf("expected no dex size report without write_dex_size_report, got %q", rule)
+	}
+	if _, err := bar.Module().(*Library).OutputFiles(".dex-size-report"); err == nil {
+		t.Errorf("expected an error getting .dex-size-report without write_dex_size_report")
+	}
+}
+
+func TestR8KeepClassesAndMembers(t *testing.T) {
+	result := PrepareForTestWithJavaDefaultModulesWithoutFakeDex2oatd.RunTestWithBp(t, `
+		android_app {
+			name: "app",
+			srcs: ["foo.java"],
+			platform_apis: true,
+			optimize: {
+				keep_classes: ["com.example.Reflected", "com.example.plugins.**"],
+				keep_members: ["com.example.Foo#bar", "com.example.Foo$Inner#baz"],
+			},
+		}
+	`)
+
+	app := result.ModuleForTests("app", "android_common")
+	keepFlags := app.Output("proguard/keep.flags")
+	android.AssertStringEquals(t, "keep rules", strings.Join([]string{
+		"-keep class com.example.Reflected",
+		"-keep class com.example.plugins.**",
+		"-keep class com.example.Foo {",
+		"    *** bar;",
+		"    *** bar(...);",
+		"}",
+		"-keep class com.example.Foo$Inner {",
+		"    *** baz;",
+		"    *** baz(...);",
+		"}",
+		"",
+	}, "\n"), android.ContentFromFileRuleForTests(t, keepFlags))
+
+	appR8 := app.Rule("r8")
+	android.AssertStringDoesContain(t, "r8 flags", appR8.Args["r8Flags"],
+		"-include "+keepFlags.Output.String())
+	android.AssertStringListContains(t, "r8 implicits", appR8.Implicits.Strings(), keepFlags.Output.String())
+}
+
+func TestR8KeepMembersErrors(t *testing.T) {
+	PrepareForTestWithJavaDefaultModulesWithoutFakeDex2oatd.
+		ExtendWithErrorHandler(android.FixtureExpectsAllErrorsToMatchAPattern([]string{
+			`optimize.keep_classes: invalid class name "com.example..Foo"`,
+			`optimize.keep_members: invalid member "com.example.Foo", expected`,
+		})).
+		RunTestWithBp(t, `
+			android_app {
+				name: "app",
+				srcs: ["foo.java"],
+				platform_apis: true,
+				optimize: {
+					keep_classes: ["com.example..Foo"],
+					keep_members: ["com.example.Foo"],
+				},
+			}
+		`)
+}
+
+func TestDualDex(t *testing.T) {
+	result := PrepareForTestWithJavaDefaultModulesWithoutFakeDex2oatd.RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["foo.java"],
+			installable: true,
+			optimize: {
+				enabled: true,
+			},
+			dual_dex: true,
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	r8 := foo.Rule("r8")
+	android.AssertStringDoesNotContain(t, "release dex flags", r8.Args["r8Flags"], "--debug")
+
+	debug := foo.Output("dex-debug/foo.jar")
+	android.AssertStringDoesContain(t, "debug dex flags", debug.Args["d8Flags"], "--debug")
+
+	library := foo.Module().(*Library)
+	for tag, expected := range map[string]string{
+		".dex.debug":   "out/soong/.intermediates/foo/android_common/dex-debug/foo.jar",
+		".dex.release": "out/soong/.intermediates/foo/android_common/dex/foo.jar",
+	} {
+		outputFiles, err := library.OutputFiles(tag)
+		if err != nil {
+			t.Fatalf("unexpected error getting %s: %s", tag, err)
+		}
+		android.AssertPathsRelativeToTopEquals(t, tag, []string{expected}, outputFiles)
+	}
+}
@@ -393,7 +393,7 @@ func (j *Javadoc) collectDeps(ctx android.ModuleContext) deps {
 			}
 			sm := module.(SystemModulesProvider)
 			outputDir, outputDeps := sm.OutputDirAndDeps()
-			deps.systemModules = &systemModules{outputDir, outputDeps}
+			deps.systemModules = &systemModules{outputDir, outputDeps, sm.HeaderJars()}
 		}
 	})
 	// do not pass exclude_srcs directly when expanding srcFiles since exclude_srcs
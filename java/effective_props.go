@@ -0,0 +1,46 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"android/soong/android"
+)
+
+// writeEffectiveProperties writes the merged property structs of the module as a JSON object
+// keyed by the type of each struct, e.g. "java.CommonProperties".
+func writeEffectiveProperties(ctx android.ModuleContext, module android.Module) android.Path {
+	output := android.PathForModuleOut(ctx, "effective-props.json")
+
+	props := make(map[string]interface{})
+	for _, p := range android.EffectiveProperties(module) {
+		name := strings.TrimPrefix(fmt.Sprintf("%T", p), "*")
+		if _, exists := props[name]; exists {
+			name = fmt.Sprintf("%s#%d", name, len(props))
+		}
+		props[name] = p
+	}
+
+	content, err := json.MarshalIndent(props, "", "  ")
+	if err != nil {
+		ctx.ModuleErrorf("failed to marshal the effective properties: %s", err)
+		return output
+	}
+	android.WriteFileRule(ctx, output, string(content))
+	return output
+}
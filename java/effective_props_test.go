@@ -0,0 +1,60 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"encoding/json"
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestDumpEffectiveProperties(t *testing.T) {
+	result := prepareForJavaTest.RunTestWithBp(t, `
+		java_defaults {
+			name: "foo_defaults",
+			javacflags: ["-Xlint:all"],
+		}
+
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			defaults: ["foo_defaults"],
+			dump_effective_properties: true,
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	content := android.ContentFromFileRuleForTests(t, foo.Output("effective-props.json"))
+
+	var props map[string]struct {
+		Javacflags []string
+		Srcs       []string
+	}
+	if err := json.Unmarshal([]byte(content), &props); err != nil {
+		t.Fatalf("failed to parse the effective properties: %s\n%s", err, content)
+	}
+	common, ok := props["java.CommonProperties"]
+	if !ok {
+		t.Fatalf("expected java.CommonProperties in the effective properties, got:\n%s", content)
+	}
+	android.AssertDeepEquals(t, "javacflags from defaults", []string{"-Xlint:all"}, common.Javacflags)
+	android.AssertDeepEquals(t, "srcs", []string{"a.java"}, common.Srcs)
+
+	outputs, err := foo.Module().(*Library).OutputFiles(".effective-props")
+	android.AssertDeepEquals(t, "error", nil, err)
+	android.AssertPathsRelativeToTopEquals(t, ".effective-props output",
+		[]string{"out/soong/.intermediates/foo/android_common/effective-props.json"}, outputs)
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+	"android/soong/cc"
+)
+
+type embedJniLibsProperties struct {
+	// List of shared JNI libraries to package into the jar under lib/<os>/, for host tools that
+	// load their native code from the jar rather than from java.library.path. The libraries are
+	// built for the primary architecture of the host.
+	Embed_jni_libs []string
+}
+
+var embedJniLibTag = dependencyTag{name: "embed-jni-lib", runtimeLinked: true}
+
+// embedJniLibsDeps adds dependencies on the shared variants of the JNI libraries to embed.
+func embedJniLibsDeps(ctx android.BottomUpMutatorContext, props *embedJniLibsProperties) {
+	if len(props.Embed_jni_libs) == 0 || !ctx.Host() {
+		return
+	}
+	target := ctx.Config().Targets[ctx.Os()][0]
+	variations := append(target.Variations(), blueprint.Variation{Mutator: "link", Variation: "shared"})
+	ctx.AddFarVariationDependencies(variations, embedJniLibTag, props.Embed_jni_libs...)
+}
+
+// embeddedJniLibs returns copies of the JNI libraries to embed, whose relative paths are their
+// paths inside the jar.
+func embeddedJniLibs(ctx android.ModuleContext) android.Paths {
+	var libs android.Paths
+	ctx.VisitDirectDepsWithTag(embedJniLibTag, func(dep android.Module) {
+		sharedLibInfo := ctx.OtherModuleProvider(dep, cc.SharedLibraryInfoProvider).(cc.SharedLibraryInfo)
+		if sharedLibInfo.SharedLibrary == nil {
+			ctx.PropertyErrorf("embed_jni_libs", "%q of type %q is not supported", dep.Name(), ctx.OtherModuleType(dep))
+			return
+		}
+		embeddedLib := android.PathForModuleOut(ctx, "embedded_jni").Join(ctx,
+			"lib", ctx.Os().Name, sharedLibInfo.SharedLibrary.Base())
+		ctx.Build(pctx, android.BuildParams{
+			Rule:   android.Cp,
+			Input:  sharedLibInfo.SharedLibrary,
+			Output: embeddedLib,
+		})
+		libs = append(libs, embeddedLib)
+	})
+	return libs
+}
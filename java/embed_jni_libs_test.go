@@ -0,0 +1,54 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestEmbedJniLibs(t *testing.T) {
+	ctx, _ := testJava(t, `
+		java_library_host {
+			name: "foo",
+			srcs: ["a.java"],
+			embed_jni_libs: ["libjni"],
+		}
+
+		cc_library_shared {
+			name: "libjni",
+			host_supported: true,
+			device_supported: false,
+			stl: "none",
+		}
+	`)
+
+	buildOS := ctx.Config().BuildOS.String()
+	foo := ctx.ModuleForTests("foo", buildOS+"_common")
+	fooOut := "out/soong/.intermediates/foo/" + buildOS + "_common"
+
+	// The shared library is copied to its path inside the jar.
+	embeddedLib := foo.Output("embedded_jni/lib/" + buildOS + "/libjni.so")
+	android.AssertStringDoesContain(t, "embedded lib input", android.PathRelativeToTop(embeddedLib.Input),
+		"out/soong/.intermediates/libjni/"+buildOS+"_x86_64_shared/")
+
+	res := foo.Output("res/foo.jar")
+	android.AssertPathsRelativeToTopEquals(t, "resource jar inputs",
+		[]string{fooOut + "/embedded_jni/lib/" + buildOS + "/libjni.so"}, res.Implicits)
+	android.AssertStringDoesContain(t, "resource jar args",
+		android.StringRelativeToTop(ctx.Config(), res.Args["jarArgs"]),
+		"-C "+fooOut+"/embedded_jni -f "+fooOut+"/embedded_jni/lib/"+buildOS+"/libjni.so")
+}
@@ -21,6 +21,8 @@ package java
 import (
 	"fmt"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"android/soong/bazel"
@@ -58,6 +60,11 @@ func registerJavaBuildComponents(ctx android.RegistrationContext) {
 	ctx.RegisterModuleType("java_device_for_host", DeviceForHostFactory)
 	ctx.RegisterModuleType("java_host_for_device", HostForDeviceFactory)
 	ctx.RegisterModuleType("dex_import", DexImportFactory)
+	ctx.RegisterModuleType("java_coverage_report", CoverageReportFactory)
+
+	ctx.PreDepsMutators(func(ctx android.RegisterMutatorsContext) {
+		ctx.BottomUp("java_sdk_versions", sdkVersionsMutator).Parallel()
+	})
 
 	// This mutator registers dependencies on dex2oat for modules that should be
 	// dexpreopted. This is done late when the final variants have been
@@ -69,6 +76,7 @@ func registerJavaBuildComponents(ctx android.RegistrationContext) {
 
 	ctx.RegisterSingletonType("logtags", LogtagsSingleton)
 	ctx.RegisterSingletonType("kythe_java_extract", kytheExtractJavaFactory)
+	ctx.RegisterSingletonType("javac_group", javacGroupSingletonFactory)
 }
 
 func RegisterJavaSdkMemberTypes() {
@@ -210,6 +218,13 @@ type JavaInfo struct {
 	// depending on this module.
 	AidlIncludeDirs android.Paths
 
+	// AidlInterface is the name of the frozen AIDL interface that this module is a prebuilt of, or
+	// an empty string if it isn't one.
+	AidlInterface string
+
+	// AidlVersion is the version of AidlInterface that this module is a prebuilt of.
+	AidlVersion string
+
 	// SrcJarArgs is a list of arguments to pass to soong_zip to package the sources of this
 	// module.
 	SrcJarArgs []string
@@ -229,9 +244,22 @@ type JavaInfo struct {
 	// requiring disbling turbine for any modules that depend on it.
 	ExportedPluginDisableTurbine bool
 
+	// ExportedPluginsTransitive is true if this module's exported annotation processors should also
+	// be exported by the modules that depend on it.
+	ExportedPluginsTransitive bool
+
 	// JacocoReportClassesFile is the path to a jar containing uninstrumented classes that will be
 	// instrumented by jacoco.
 	JacocoReportClassesFile android.Path
+
+	// TransitiveSrcFiles contains the .java sources and srcjars compiled into this module and its
+	// transitive static dependencies.
+	TransitiveSrcFiles *android.DepSet
+
+	// ExportedProguardFlagFiles is a list of proguard flag files that should be used when optimizing
+	// any app that includes this module, exported by this module and its transitive static
+	// dependencies.
+	ExportedProguardFlagFiles android.Paths
 }
 
 var JavaInfoProvider = blueprint.NewProvider(JavaInfo{})
@@ -333,6 +361,7 @@ var (
 	dataDeviceBinsTag       = dependencyTag{name: "dataDeviceBins"}
 	staticLibTag            = dependencyTag{name: "staticlib"}
 	libTag                  = dependencyTag{name: "javalib", runtimeLinked: true}
+	runtimeLibTag           = dependencyTag{name: "runtimelib", runtimeLinked: true}
 	java9LibTag             = dependencyTag{name: "java9lib", runtimeLinked: true}
 	pluginTag               = dependencyTag{name: "plugin", toolchain: true}
 	errorpronePluginTag     = dependencyTag{name: "errorprone-plugin", toolchain: true}
@@ -349,6 +378,7 @@ var (
 	extraLintCheckTag       = dependencyTag{name: "extra-lint-check", toolchain: true}
 	jniLibTag               = dependencyTag{name: "jnilib", runtimeLinked: true}
 	syspropPublicStubDepTag = dependencyTag{name: "sysprop public stub"}
+	requiredHostToolTag     = dependencyTag{name: "required-host-tool", toolchain: true}
 	jniInstallTag           = installDependencyTag{name: "jni install"}
 	binaryInstallTag        = installDependencyTag{name: "binary install"}
 )
@@ -450,12 +480,19 @@ type deps struct {
 	srcs                    android.Paths
 	srcJars                 android.Paths
 	systemModules           *systemModules
+	requiredHostTools       android.Paths
 	aidlPreprocess          android.OptionalPath
 	kotlinStdlib            android.Paths
 	kotlinAnnotations       android.Paths
 	kotlinPlugins           android.Paths
 
 	disableTurbine bool
+
+	// sources of the transitive static dependencies.
+	transitiveStaticSrcFiles []*android.DepSet
+
+	// proguard flag files exported by the static dependencies.
+	exportedProguardFlagFiles android.Paths
 }
 
 func checkProducesJars(ctx android.ModuleContext, dep android.SourceFileProducer) {
@@ -505,12 +542,37 @@ func (v javaVersion) String() string {
 	}
 }
 
+// javaReleaseFlags validates the java_release property and sets the java version and release of
+// the builder flags from it.
+func (j *Module) javaReleaseFlags(ctx android.ModuleContext, flags javaBuilderFlags) javaBuilderFlags {
+	if j.properties.Java_version != nil {
+		ctx.PropertyErrorf("java_release", "cannot be combined with java_version")
+	}
+	for _, flag := range j.properties.Javacflags {
+		switch flag {
+		case "-source", "--source", "-target", "--target", "--release":
+			ctx.PropertyErrorf("java_release", "cannot be combined with %q in javacflags", flag)
+		}
+	}
+
+	release := strconv.FormatInt(*j.properties.Java_release, 10)
+	flags.javaVersion = normalizeJavaVersionForProperty(ctx, "java_release", release)
+	flags.javaRelease = release
+	return flags
+}
+
 // Returns true if javac targeting this version uses system modules instead of a bootclasspath.
 func (v javaVersion) usesJavaModules() bool {
 	return v >= 9
 }
 
 func normalizeJavaVersion(ctx android.BaseModuleContext, javaVersion string) javaVersion {
+	return normalizeJavaVersionForProperty(ctx, "java_version", javaVersion)
+}
+
+// normalizeJavaVersionForProperty is like normalizeJavaVersion, but reports errors against the
+// given property.
+func normalizeJavaVersionForProperty(ctx android.BaseModuleContext, property, javaVersion string) javaVersion {
 	switch javaVersion {
 	case "1.6", "6":
 		return JAVA_VERSION_6
@@ -523,10 +585,10 @@ func normalizeJavaVersion(ctx android.BaseModuleContext, javaVersion string) jav
 	case "11":
 		return JAVA_VERSION_11
 	case "10":
-		ctx.PropertyErrorf("java_version", "Java language levels 10 is not supported")
+		ctx.PropertyErrorf(property, "Java language levels 10 is not supported")
 		return JAVA_VERSION_UNSUPPORTED
 	default:
-		ctx.PropertyErrorf("java_version", "Unrecognized Java language level")
+		ctx.PropertyErrorf(property, "Unrecognized Java language level")
 		return JAVA_VERSION_UNSUPPORTED
 	}
 }
@@ -538,6 +600,30 @@ func normalizeJavaVersion(ctx android.BaseModuleContext, javaVersion string) jav
 type Library struct {
 	Module
 
+	mavenProperties mavenProperties
+
+	// pom.xml describing the artifact, generated when the maven properties are set.
+	pomFile android.Path
+
+	licenseManifestProperties licenseManifestProperties
+
+	// The license manifest of the module and its static libraries, generated when
+	// license_manifest is set.
+	licenseManifest android.Path
+
+	apiLeakageProperties apiLeakageProperties
+
+	// The directory the jar is installed to, if installable.
+	installDir android.InstallPath
+
+	abiContractProperties abiContractProperties
+
+	embedJniLibsProperties embedJniLibsProperties
+
+	// If true, the installable property is also honored by host variants, which are otherwise
+	// always installed. Set for test modules so that they can be built without being installed.
+	honorInstallableOnHost bool
+
 	InstallMixin func(ctx android.ModuleContext, installPath android.Path) (extraInstallDeps android.Paths)
 }
 
@@ -596,19 +682,37 @@ func (j *Library) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	}
 
 	j.checkSdkVersions(ctx)
+	installDirName := j.installDirName(ctx)
 	j.dexpreopter.installPath = j.dexpreopter.getInstallPath(
-		ctx, android.PathForModuleInstall(ctx, "framework", j.Stem()+".jar"))
+		ctx, android.PathForModuleInstall(ctx, installDirName, j.Stem()+".jar"))
 	j.dexpreopter.isSDKLibrary = j.deviceProperties.IsSDKLibrary
 	setUncompressDex(ctx, &j.dexpreopter, &j.dexer)
 	j.dexpreopter.uncompressedDex = *j.dexProperties.Uncompress_dex
 	j.classLoaderContexts = j.usesLibrary.classLoaderContextForUsesLibDeps(ctx)
+	j.extraResources = append(j.extraResources, embeddedJniLibs(ctx)...)
 	j.compile(ctx, nil)
 
 	// Collect the module directory for IDE info in java/jdeps.go.
 	j.modulePaths = append(j.modulePaths, ctx.ModuleDir())
 
+	j.pomFile = buildPom(ctx, &j.mavenProperties)
+
+	if Bool(j.licenseManifestProperties.License_manifest) {
+		j.licenseManifest = buildLicenseManifest(ctx, j.licenseManifestEntries(ctx))
+	}
+
+	if Bool(j.apiLeakageProperties.Check_api_leakage) && ctx.Device() {
+		ctx.CheckbuildFile(buildApiLeakageCheck(ctx, j.implementationJarFile))
+	}
+
+	if contract := j.abiContractProperties.Abi_contract; contract != nil {
+		ctx.CheckbuildFile(buildAbiContractCheck(ctx, j.headerJarFile,
+			android.PathForModuleSrc(ctx, *contract)))
+	}
+
 	exclusivelyForApex := !apexInfo.IsForPlatform()
-	if (Bool(j.properties.Installable) || ctx.Host()) && !exclusivelyForApex {
+	installable := Bool(j.properties.Installable) || (ctx.Host() && !j.honorInstallableOnHost)
+	if installable && !exclusivelyForApex {
 		var extraInstallDeps android.Paths
 		if j.InstallMixin != nil {
 			extraInstallDeps = j.InstallMixin(ctx, j.outputFile)
@@ -627,15 +731,69 @@ func (j *Library) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 			}
 			installDir = android.PathForModuleInstall(ctx, ctx.ModuleName(), archDir)
 		} else {
-			installDir = android.PathForModuleInstall(ctx, "framework")
+			installDir = android.PathForModuleInstall(ctx, installDirName)
 		}
+		j.installDir = installDir
 		j.installFile = ctx.InstallFile(installDir, j.Stem()+".jar", j.outputFile, extraInstallDeps...)
 	}
 }
 
+// installDirName returns the directory relative to the root of the partition of the module that
+// the jar is installed to, which is install_path if set.
+func (j *Library) installDirName(ctx android.ModuleContext) string {
+	installPath := j.properties.Install_path
+	if installPath == nil {
+		return "framework"
+	}
+	if ctx.InstallInTestcases() {
+		ctx.PropertyErrorf("install_path", "cannot be set for modules installed in testcases")
+		return "framework"
+	}
+	if *installPath == "" || filepath.IsAbs(*installPath) || filepath.Clean(*installPath) != *installPath ||
+		*installPath == ".." || strings.HasPrefix(*installPath, "../") {
+		ctx.PropertyErrorf("install_path",
+			"must be a clean relative path within the partition of the module, got %q", *installPath)
+		return "framework"
+	}
+	return *installPath
+}
+
+func (j *Library) OutputFiles(tag string) (android.Paths, error) {
+	if tag == ".pom" {
+		if j.pomFile != nil {
+			return android.Paths{j.pomFile}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but no output file was found.", tag)
+	}
+	if tag == ".licenses" {
+		if j.licenseManifest != nil {
+			return android.Paths{j.licenseManifest}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but no output file was found.", tag)
+	}
+	return j.Module.OutputFiles(tag)
+}
+
+// licenseManifestEntries returns the license manifest entries for the library and for all the
+// static libraries that are included in its jar.
+func (j *Library) licenseManifestEntries(ctx android.ModuleContext) []licenseManifestEntry {
+	entries := []licenseManifestEntry{licenseManifestEntryForCurrentModule(ctx)}
+	ctx.WalkDeps(func(child, parent android.Module) bool {
+		if ctx.OtherModuleDependencyTag(child) != staticLibTag {
+			return false
+		}
+		entries = append(entries, licenseManifestEntryForModule(ctx, child))
+		return true
+	})
+	return entries
+}
+
+var _ android.OutputFileProducer = (*Library)(nil)
+
 func (j *Library) DepsMutator(ctx android.BottomUpMutatorContext) {
 	j.deps(ctx)
 	j.usesLibrary.deps(ctx, false)
+	embedJniLibsDeps(ctx, &j.embedJniLibsProperties)
 }
 
 const (
@@ -768,6 +926,8 @@ func LibraryFactory() android.Module {
 	module := &Library{}
 
 	module.addHostAndDeviceProperties()
+	module.AddProperties(&module.mavenProperties, &module.licenseManifestProperties,
+		&module.apiLeakageProperties, &module.abiContractProperties)
 
 	module.initModuleAndImport(module)
 
@@ -791,6 +951,8 @@ func LibraryHostFactory() android.Module {
 	module := &Library{}
 
 	module.addHostProperties()
+	module.AddProperties(&module.mavenProperties, &module.licenseManifestProperties,
+		&module.apiLeakageProperties, &module.abiContractProperties, &module.embedJniLibsProperties)
 
 	module.Module.properties.Installable = proptools.BoolPtr(true)
 
@@ -875,6 +1037,15 @@ type prebuiltTestProperties struct {
 	// the name of the test configuration (for example "AndroidTest.xml") that should be
 	// installed with the module.
 	Test_config *string `android:"path,arch_variant"`
+
+	// the name of the test configuration template (for example "AndroidTestTemplate.xml") that
+	// should be used to generate the test configuration when test_config is not set.
+	Test_config_template *string `android:"path,arch_variant"`
+
+	// Flag to indicate whether or not to create test config automatically from the module name
+	// and test_suites when test_config is not set. Defaults to true, except for modules in the
+	// "cts" test suite without a test_config_template.
+	Auto_gen_config *bool
 }
 
 type Test struct {
@@ -1021,8 +1192,9 @@ func (j *TestHelperLibrary) GenerateAndroidBuildActions(ctx android.ModuleContex
 }
 
 func (j *JavaTestImport) GenerateAndroidBuildActions(ctx android.ModuleContext) {
-	j.testConfig = tradefed.AutoGenJavaTestConfig(ctx, j.prebuiltTestProperties.Test_config, nil,
-		j.prebuiltTestProperties.Test_suites, nil, nil, nil)
+	j.testConfig = tradefed.AutoGenJavaTestConfig(ctx, j.prebuiltTestProperties.Test_config,
+		j.prebuiltTestProperties.Test_config_template, j.prebuiltTestProperties.Test_suites, nil,
+		j.prebuiltTestProperties.Auto_gen_config, nil)
 
 	j.Import.GenerateAndroidBuildActions(ctx)
 }
@@ -1103,6 +1275,7 @@ func TestFactory() android.Module {
 	module.Module.properties.Installable = proptools.BoolPtr(true)
 	module.Module.dexpreopter.isTest = true
 	module.Module.linter.test = true
+	module.honorInstallableOnHost = true
 
 	android.InitSdkAwareModule(module)
 	InitJavaModule(module, android.HostAndDeviceSupported)
@@ -1119,6 +1292,7 @@ func TestHelperLibraryFactory() android.Module {
 	module.Module.properties.Installable = proptools.BoolPtr(true)
 	module.Module.dexpreopter.isTest = true
 	module.Module.linter.test = true
+	module.honorInstallableOnHost = true
 
 	InitJavaModule(module, android.HostAndDeviceSupported)
 	return module
@@ -1173,6 +1347,7 @@ func TestHostFactory() android.Module {
 
 func InitTestHost(th *TestHost, installable *bool, testSuites []string, autoGenConfig *bool) {
 	th.properties.Installable = installable
+	th.honorInstallableOnHost = true
 	th.testProperties.Auto_gen_config = autoGenConfig
 	th.testProperties.Test_suites = testSuites
 }
@@ -1335,6 +1510,16 @@ type ImportProperties struct {
 	// if set to true, run Jetifier against .jar file. Defaults to false.
 	Jetifier *bool
 
+	// Rewrites the references to the classes of a package and its sub-packages in the jar file(s)
+	// to another package, for prebuilt jars that reference an outdated package of a dependency.
+	Rewrite_deps struct {
+		// The package to rewrite the references to, e.g. "com.old".
+		From *string
+
+		// The package to rewrite the references to "from" to, e.g. "com.new".
+		To *string
+	}
+
 	// set the name of the output
 	Stem *string
 
@@ -1342,6 +1527,16 @@ type ImportProperties struct {
 		// directories that should be added as include directories for any aidl sources of modules
 		// that depend on this module, as well as to aidl for this module.
 		Export_include_dirs []string
+
+		// the version of the frozen AIDL interface that the jars contain the compiled stubs of, and
+		// that export_include_dirs contain the .aidl files of. Modules that depend on this module
+		// compile against that version of the interface.
+		Version *string
+
+		// the name of the AIDL interface that the jars contain the compiled stubs of.  Required
+		// when version is set.  A module cannot depend on two different versions of the same
+		// interface.
+		Interface *string
 	}
 }
 
@@ -1366,6 +1561,9 @@ type Import struct {
 	dexJarFile        OptionalDexJarPath
 	dexJarInstallFile android.Path
 
+	// the jars listed in the jars property
+	prebuiltJars android.Paths
+
 	combinedClasspathFile android.Path
 	classLoaderContexts   dexpreopt.ClassLoaderContextMap
 	exportAidlIncludeDirs android.Paths
@@ -1440,6 +1638,31 @@ func (j *Import) DepsMutator(ctx android.BottomUpMutatorContext) {
 	}
 }
 
+// javaPackageRegexp matches Java package names.
+var javaPackageRegexp = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+
+// rewriteDepsJarjarRules writes the jarjar rules rewriting the package set in rewrite_deps, or
+// returns nil if rewrite_deps isn't set.
+func (j *Import) rewriteDepsJarjarRules(ctx android.ModuleContext) android.Path {
+	from, to := j.properties.Rewrite_deps.From, j.properties.Rewrite_deps.To
+	if from == nil && to == nil {
+		return nil
+	}
+	if from == nil || to == nil {
+		ctx.PropertyErrorf("rewrite_deps", "from and to must both be set")
+		return nil
+	}
+	for _, pkg := range []string{*from, *to} {
+		if !javaPackageRegexp.MatchString(pkg) {
+			ctx.PropertyErrorf("rewrite_deps", "invalid package name %q", pkg)
+			return nil
+		}
+	}
+	rulesFile := android.PathForModuleOut(ctx, "rewrite_deps", "jarjar-rules.txt")
+	android.WriteFileRule(ctx, rulesFile, fmt.Sprintf("rule %s.** %s.@1", *from, *to))
+	return rulesFile
+}
+
 func (j *Import) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	j.sdkVersion = j.SdkVersion(ctx)
 	j.minSdkVersion = j.MinSdkVersion(ctx)
@@ -1453,6 +1676,7 @@ func (j *Import) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	}
 
 	jars := android.PathsForModuleSrc(ctx, j.properties.Jars)
+	j.prebuiltJars = jars
 
 	jarName := j.Stem() + ".jar"
 	outputFile := android.PathForModuleOut(ctx, "combined", jarName)
@@ -1463,6 +1687,11 @@ func (j *Import) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		outputFile = android.PathForModuleOut(ctx, "jetifier", jarName)
 		TransformJetifier(ctx, outputFile, inputFile)
 	}
+	if rulesFile := j.rewriteDepsJarjarRules(ctx); rulesFile != nil {
+		inputFile := outputFile
+		outputFile = android.PathForModuleOut(ctx, "rewrite_deps", jarName)
+		TransformJarJar(ctx, outputFile, inputFile, rulesFile)
+	}
 	j.combinedClasspathFile = outputFile
 	j.classLoaderContexts = make(dexpreopt.ClassLoaderContextMap)
 
@@ -1507,6 +1736,17 @@ func (j *Import) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	}
 
 	j.exportAidlIncludeDirs = android.PathsForModuleSrc(ctx, j.properties.Aidl.Export_include_dirs)
+	if version := j.properties.Aidl.Version; version != nil {
+		if v, err := strconv.Atoi(*version); err != nil || v < 1 {
+			ctx.PropertyErrorf("aidl.version", "must be a positive integer, got %q", *version)
+		} else if len(j.exportAidlIncludeDirs) == 0 {
+			ctx.PropertyErrorf("aidl.version", "requires aidl.export_include_dirs with the .aidl files of the interface")
+		} else if String(j.properties.Aidl.Interface) == "" {
+			ctx.PropertyErrorf("aidl.version", "requires aidl.interface with the name of the interface")
+		}
+	} else if j.properties.Aidl.Interface != nil {
+		ctx.PropertyErrorf("aidl.interface", "requires aidl.version")
+	}
 
 	if ctx.Device() {
 		// If this is a variant created for a prebuilt_apex then use the dex implementation jar
@@ -1575,9 +1815,17 @@ func (j *Import) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		ImplementationAndResourcesJars: android.PathsIfNonNil(j.combinedClasspathFile),
 		ImplementationJars:             android.PathsIfNonNil(j.combinedClasspathFile),
 		AidlIncludeDirs:                j.exportAidlIncludeDirs,
+		AidlInterface:                  String(j.properties.Aidl.Interface),
+		AidlVersion:                    String(j.properties.Aidl.Version),
 	})
 }
 
+// AidlVersion returns the version of the frozen AIDL interface that the module is a prebuilt of, or
+// an empty string if it isn't one.
+func (j *Import) AidlVersion() string {
+	return String(j.properties.Aidl.Version)
+}
+
 func (j *Import) OutputFiles(tag string) (android.Paths, error) {
 	switch tag {
 	case "", ".jar":
@@ -1994,8 +2242,8 @@ func addCLCFromDep(ctx android.ModuleContext, depModule android.Module,
 	}
 
 	depTag := ctx.OtherModuleDependencyTag(depModule)
-	if depTag == libTag {
-		// Ok, propagate <uses-library> through non-static library dependencies.
+	if depTag == libTag || depTag == runtimeLibTag {
+		// Ok, propagate <uses-library> through non-static and runtime-only library dependencies.
 	} else if tag, ok := depTag.(usesLibraryDependencyTag); ok &&
 		tag.sdkVersion == dexpreopt.AnySdkVersion && tag.implicit {
 		// Ok, propagate <uses-library> through non-compatibility implicit <uses-library>
@@ -24,6 +24,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/google/blueprint"
 	"github.com/google/blueprint/proptools"
 
 	"android/soong/android"
@@ -305,6 +306,20 @@ func TestExportedPlugins(t *testing.T) {
 				{library: "bar", processors: "-proc:none"},
 			},
 		},
+		{
+			name: "Transitive exported plugin is propagated via transitive deps",
+			extra: `
+				java_library{name: "exports", exported_plugins: ["plugin"], transitive_exported_plugins: true}
+				java_library{name: "foo", srcs: ["a.java"], libs: ["exports"]}
+				java_library{name: "bar", srcs: ["a.java"], static_libs: ["foo"]}
+				java_library{name: "baz", srcs: ["a.java"], libs: ["bar"]}
+			`,
+			results: []Result{
+				{library: "foo", processors: "-processor com.android.TestPlugin"},
+				{library: "bar", processors: "-processor com.android.TestPlugin"},
+				{library: "baz", processors: "-processor com.android.TestPlugin"},
+			},
+		},
 		{
 			name: "Exports plugin appends to plugins",
 			extra: `
@@ -328,6 +343,16 @@ func TestExportedPlugins(t *testing.T) {
 				{library: "bar", processors: "-processor com.android.TestPlugin", disableTurbine: true},
 			},
 		},
+		{
+			name: "Exports plugin with generates_api followed by another plugin to dependee",
+			extra: `
+				java_library{name: "exports", exported_plugins: ["plugin_generates_api", "plugin"]}
+				java_library{name: "foo", srcs: ["a.java"], libs: ["exports"]}
+			`,
+			results: []Result{
+				{library: "foo", processors: "-processor com.android.TestPlugin", disableTurbine: true},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -938,6 +963,82 @@ func TestGeneratedSources(t *testing.T) {
 	}
 }
 
+func TestWriteSourcesList(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		android.FixtureMergeMockFs(android.MockFS{
+			"a.java": nil,
+			"b.java": nil,
+		}),
+	).RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: [
+				"a*.java",
+				":gen",
+				"b*.java",
+			],
+			write_sources_list: true,
+		}
+
+		genrule {
+			name: "gen",
+			tool_files: ["java-res/a"],
+			out: ["gen.java"],
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	sourcesList := foo.Output("sources.txt")
+	android.AssertStringEquals(t, "sources list",
+		"a.java\nout/soong/.intermediates/gen/gen/gen.java\nb.java\n",
+		android.StringRelativeToTop(result.Config, android.ContentFromFileRuleForTests(t, sourcesList)))
+
+	outputs, err := foo.Module().(*Library).OutputFiles(".sources-list")
+	android.AssertDeepEquals(t, "error", nil, err)
+	android.AssertPathsRelativeToTopEquals(t, ".sources-list output",
+		[]string{"out/soong/.intermediates/foo/android_common/sources.txt"}, outputs)
+}
+
+func TestEmitCompileCommand(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		android.FixtureMergeMockFs(android.MockFS{
+			"a.java": nil,
+			"b.java": nil,
+		}),
+	).RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java", "b.java"],
+			libs: ["bar"],
+			emit_compile_command: true,
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["c.java"],
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	command := android.StringRelativeToTop(result.Config,
+		android.ContentFromFileRuleForTests(t, foo.Output("compile-command.txt")))
+	javac := foo.Rule("javac")
+
+	android.AssertStringDoesContain(t, "compile command", command,
+		android.StringRelativeToTop(result.Config, javac.Args["classpath"]))
+	android.AssertStringDoesContain(t, "compile command", command, "-classpath ")
+	android.AssertStringDoesContain(t, "compile command", command,
+		"out/soong/.intermediates/bar/android_common/turbine-combined/bar.jar")
+	android.AssertStringDoesContain(t, "compile command", command, " a.java b.java")
+
+	outputs, err := foo.Module().(*Library).OutputFiles(".compile-command")
+	android.AssertDeepEquals(t, "error", nil, err)
+	android.AssertPathsRelativeToTopEquals(t, ".compile-command output",
+		[]string{"out/soong/.intermediates/foo/android_common/compile-command.txt"}, outputs)
+}
+
 func TestTurbine(t *testing.T) {
 	result := android.GroupFixturePreparers(
 		prepareForJavaTest, FixtureWithPrebuiltApis(map[string][]string{"14": {"foo"}})).
@@ -979,6 +1080,54 @@ func TestTurbine(t *testing.T) {
 	android.AssertStringDoesContain(t, "baz javac classpath", bazJavac.Args["classpath"], "prebuilts/sdk/14/public/android.jar")
 }
 
+func TestJavaToolJvmArgs(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.JavaToolJvmArgs = []string{"-XX:+UseParallelGC", "-Xss4m"}
+		}),
+	).RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			installable: true,
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	android.AssertStringEquals(t, "javac jvm flags",
+		"-J-XX:+UseParallelGC -J-Xss4m", foo.Rule("javac").Args["jvmFlags"])
+	android.AssertStringEquals(t, "turbine jvm flags",
+		"-XX:+UseParallelGC -Xss4m", foo.Rule("turbine").Args["jvmFlags"])
+	android.AssertStringDoesContain(t, "d8 jvm flags",
+		foo.Rule("d8").Args["d8Flags"], "-JXX:+UseParallelGC -JXss4m")
+}
+
+func TestRequiredHostTools(t *testing.T) {
+	result := PrepareForTestWithJavaDefaultModules.RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			required_host_tools: ["tool"],
+		}
+
+		java_binary_host {
+			name: "tool",
+			srcs: ["b.java"],
+		}
+	`)
+
+	buildOS := result.Config.BuildOS.String()
+	tool := result.ModuleForTests("tool", buildOS+"_x86_64").Module().(*Binary).HostToolPath().Path()
+
+	foo := result.ModuleForTests("foo", "android_common")
+	combined := foo.Output("combined/foo.jar")
+	android.AssertPathsRelativeToTopEquals(t, "order-only deps",
+		[]string{android.PathRelativeToTop(tool)}, combined.OrderOnly)
+	android.AssertPathRelativeToTopEquals(t, "implementation jar",
+		android.PathRelativeToTop(combined.Output), foo.Module().(*Library).implementationJarFile)
+}
+
 func TestSharding(t *testing.T) {
 	ctx, _ := testJava(t, `
 		java_library {
@@ -1056,6 +1205,65 @@ func TestJavaImport(t *testing.T) {
 	})
 }
 
+func TestJavaImportRewriteDeps(t *testing.T) {
+	ctx, _ := testJava(t, `
+		java_import {
+			name: "vendored",
+			jars: ["a.jar"],
+			rewrite_deps: {
+				from: "com.old",
+				to: "com.new",
+			},
+		}
+
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			libs: ["vendored"],
+		}
+	`)
+
+	vendored := ctx.ModuleForTests("vendored", "android_common")
+	rules := vendored.Output("rewrite_deps/jarjar-rules.txt")
+	android.AssertStringEquals(t, "jarjar rules", "rule com.old.** com.new.@1\n",
+		android.ContentFromFileRuleForTests(t, rules))
+
+	jarjar := vendored.Rule("jarjar")
+	android.AssertPathRelativeToTopEquals(t, "jarjar input",
+		"out/soong/.intermediates/vendored/android_common/combined/vendored.jar", jarjar.Input)
+	android.AssertPathRelativeToTopEquals(t, "jarjar rules file", rules.Output.String(), jarjar.Implicit)
+
+	// Consumers compile against the rewritten jar.
+	javac := ctx.ModuleForTests("foo", "android_common").Rule("javac")
+	android.AssertStringDoesContain(t, "foo classpath", javac.Args["classpath"],
+		"out/soong/.intermediates/vendored/android_common/rewrite_deps/vendored.jar")
+	android.AssertStringDoesNotContain(t, "foo classpath", javac.Args["classpath"],
+		"out/soong/.intermediates/vendored/android_common/combined/vendored.jar")
+}
+
+func TestJavaImportRewriteDepsErrors(t *testing.T) {
+	testJavaError(t, `rewrite_deps: from and to must both be set`, `
+		java_import {
+			name: "vendored",
+			jars: ["a.jar"],
+			rewrite_deps: {
+				from: "com.old",
+			},
+		}
+	`)
+
+	testJavaError(t, `rewrite_deps: invalid package name "com.new-pkg"`, `
+		java_import {
+			name: "vendored",
+			jars: ["a.jar"],
+			rewrite_deps: {
+				from: "com.old",
+				to: "com.new-pkg",
+			},
+		}
+	`)
+}
+
 var compilerFlagsTestCases = []struct {
 	in  string
 	out bool
@@ -1287,6 +1495,113 @@ func TestAidlExportIncludeDirsFromImports(t *testing.T) {
 	}
 }
 
+func TestAidlImportVersion(t *testing.T) {
+	ctx, _ := testJava(t, `
+		java_library {
+			name: "foo",
+			srcs: ["aidl/foo/IFoo.aidl"],
+			libs: ["bar-V2"],
+		}
+
+		java_import {
+			name: "bar-V2",
+			jars: ["bar-V2.jar"],
+			aidl: {
+				export_include_dirs: ["aidl/bar/2"],
+				version: "2",
+				interface: "bar",
+			},
+		}
+	`)
+
+	foo := ctx.ModuleForTests("foo", "android_common")
+	bar := ctx.ModuleForTests("bar-V2", "android_common")
+
+	aidlCommand := foo.Rule("aidl").RuleParams.Command
+	android.AssertStringDoesContain(t, "aidl command", aidlCommand, "-Iaidl/bar/2")
+
+	barJar := bar.Output("combined/bar-V2.jar").Output
+	android.AssertStringDoesContain(t, "foo classpath", foo.Rule("javac").Args["classpath"], barJar.String())
+
+	android.AssertStringEquals(t, "aidl version", "2", bar.Module().(*Import).AidlVersion())
+}
+
+func TestAidlImportVersionErrors(t *testing.T) {
+	testJavaError(t, `aidl.version: must be a positive integer, got "current"`, `
+		java_import {
+			name: "bar",
+			jars: ["bar.jar"],
+			aidl: {
+				export_include_dirs: ["aidl/bar"],
+				version: "current",
+				interface: "bar",
+			},
+		}
+	`)
+
+	testJavaError(t, `aidl.version: requires aidl.export_include_dirs`, `
+		java_import {
+			name: "bar",
+			jars: ["bar.jar"],
+			aidl: {
+				version: "1",
+				interface: "bar",
+			},
+		}
+	`)
+
+	testJavaError(t, `aidl.version: requires aidl.interface`, `
+		java_import {
+			name: "bar",
+			jars: ["bar.jar"],
+			aidl: {
+				export_include_dirs: ["aidl/bar"],
+				version: "1",
+			},
+		}
+	`)
+
+	testJavaError(t, `aidl.interface: requires aidl.version`, `
+		java_import {
+			name: "bar",
+			jars: ["bar.jar"],
+			aidl: {
+				export_include_dirs: ["aidl/bar"],
+				interface: "bar",
+			},
+		}
+	`)
+
+	testJavaError(t, `depends on version 1 of AIDL interface "bar" through "bar-V1" and on version 2 through "bar-V2"`, `
+		java_library {
+			name: "foo",
+			srcs: ["aidl/foo/IFoo.aidl"],
+			libs: ["bar-V1"],
+			static_libs: ["bar-V2"],
+		}
+
+		java_import {
+			name: "bar-V1",
+			jars: ["bar-V1.jar"],
+			aidl: {
+				export_include_dirs: ["aidl/bar/1"],
+				version: "1",
+				interface: "bar",
+			},
+		}
+
+		java_import {
+			name: "bar-V2",
+			jars: ["bar-V2.jar"],
+			aidl: {
+				export_include_dirs: ["aidl/bar/2"],
+				version: "2",
+				interface: "bar",
+			},
+		}
+	`)
+}
+
 func TestAidlFlagsArePassedToTheAidlCompiler(t *testing.T) {
 	ctx, _ := testJava(t, `
 		java_library {
@@ -1405,6 +1720,30 @@ func TestDefaultInstallable(t *testing.T) {
 		module.properties.Installable)
 }
 
+func TestTestHostNotInstallable(t *testing.T) {
+	result := prepareForJavaTest.RunTestWithBp(t, `
+		java_test_host {
+			name: "foo",
+			srcs: ["a.java"],
+			installable: false,
+			test_suites: ["general-tests"],
+		}
+	`)
+
+	buildOS := result.Config.BuildOS.String()
+	module := result.ModuleForTests("foo", buildOS+"_common").Module().(*TestHost)
+	if module.installFile != nil {
+		t.Errorf("expected no install file, got %q", module.installFile)
+	}
+
+	entries := android.AndroidMkEntriesForTest(t, result.TestContext, module)[0]
+	android.AssertStringListContains(t, "LOCAL_UNINSTALLABLE_MODULE",
+		entries.EntryMap["LOCAL_UNINSTALLABLE_MODULE"], "true")
+	if suites, ok := entries.EntryMap["LOCAL_COMPATIBILITY_SUITE"]; ok {
+		t.Errorf("expected no test suites for an uninstallable test, got %q", suites)
+	}
+}
+
 func TestErrorproneEnabled(t *testing.T) {
 	ctx, _ := testJava(t, `
 		java_library {
@@ -1467,6 +1806,159 @@ func TestErrorproneDisabled(t *testing.T) {
 	}
 }
 
+func TestErrorproneReport(t *testing.T) {
+	ctx, _ := testJava(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			errorprone: {
+				enabled: true,
+				report: true,
+				javacflags: ["-Xep:SomeCheck:ERROR"],
+				extra_check_modules: ["extra_checks"],
+			},
+		}
+
+		java_plugin {
+			name: "extra_checks",
+			srcs: ["b.java"],
+		}
+	`)
+
+	foo := ctx.ModuleForTests("foo", "android_common")
+	javac := foo.Description("javac")
+	report := foo.Rule("errorproneReport")
+
+	// The errorprone flags are only passed to the separate errorprone pass.
+	if strings.Contains(javac.Args["javacFlags"], "-Xplugin:ErrorProne") {
+		t.Errorf("expected javacFlags to not contain -Xplugin:ErrorProne, got %q", javac.Args["javacFlags"])
+	}
+	for _, flag := range []string{"-Xplugin:ErrorProne", "-Xep:SomeCheck:ERROR"} {
+		android.AssertStringDoesContain(t, "errorprone report javacFlags", report.Args["javacFlags"], flag)
+	}
+
+	// The extra check modules are on the processor path of the errorprone pass.
+	android.AssertStringDoesContain(t, "errorprone report processorpath", report.Args["processorpath"],
+		"/extra_checks/linux_glibc_common/")
+
+	// The report is a validation of the output jar rather than an input of the compilation.
+	android.AssertPathRelativeToTopEquals(t, "report",
+		"out/soong/.intermediates/foo/android_common/errorprone/errorprone-report.txt", report.Output)
+	checked := foo.Output("checked/foo.jar")
+	android.AssertPathsRelativeToTopEquals(t, "validations", []string{report.Output.String()},
+		checked.Validations)
+	android.AssertStringListDoesNotContain(t, "javac implicits", javac.Implicits.Strings(),
+		report.Output.String())
+}
+
+func TestVerifyReproducible(t *testing.T) {
+	ctx, _ := testJava(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			verify_reproducible: true,
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["a.java"],
+		}
+	`)
+
+	foo := ctx.ModuleForTests("foo", "android_common")
+	javac := foo.Output("javac/foo.jar")
+	rebuilt := foo.Output("reproducible/javac/foo.jar")
+	android.AssertStringEquals(t, "rebuilt rule", javac.Rule.String(), rebuilt.Rule.String())
+	android.AssertPathsRelativeToTopEquals(t, "rebuilt inputs", android.PathsRelativeToTop(javac.Inputs),
+		rebuilt.Inputs)
+
+	// The two compilations are compared, and the comparison is a validation of the output jar.
+	check := foo.Rule("verifyReproducible")
+	android.AssertPathRelativeToTopEquals(t, "compared jar", javac.Output.String(), check.Input)
+	android.AssertStringEquals(t, "compared rebuilt jar", rebuilt.Output.String(), check.Args["rebuilt"])
+	checked := foo.Output("checked/foo.jar")
+	android.AssertPathsRelativeToTopEquals(t, "validations", []string{check.Output.String()}, checked.Validations)
+
+	bar := ctx.ModuleForTests("bar", "android_common")
+	if bar.MaybeRule("verifyReproducible").Rule != nil {
+		t.Errorf("Expected no reproducibility check without verify_reproducible")
+	}
+}
+
+func TestFlattenStaticLibs(t *testing.T) {
+	ctx, _ := testJava(t, `
+		java_library_host {
+			name: "foo",
+			srcs: ["a.java"],
+			java_resources: ["foo.txt"],
+			static_libs: ["bar"],
+			flatten_static_libs: true,
+		}
+
+		java_library_host {
+			name: "bar",
+			srcs: ["b.java"],
+			static_libs: ["baz"],
+		}
+
+		java_library_host {
+			name: "baz",
+			srcs: ["c.java"],
+		}
+
+		java_library_host {
+			name: "qux",
+			srcs: ["d.java"],
+			static_libs: ["baz"],
+			flatten_static_libs: true,
+			flatten_static_libs_duplicates: "error",
+		}
+	`)
+
+	foo := ctx.ModuleForTests("foo", "linux_glibc_common")
+	fatJar := foo.Output("fatjar/foo.jar")
+	android.AssertPathsRelativeToTopEquals(t, "fat jar inputs", []string{
+		"out/soong/.intermediates/foo/linux_glibc_common/javac/foo.jar",
+		"out/soong/.intermediates/bar/linux_glibc_common/combined/bar.jar",
+		"out/soong/.intermediates/foo/linux_glibc_common/res/foo.jar",
+	}, fatJar.Inputs)
+	// The entries are sorted, and the first version of duplicate entries is kept by default.
+	android.AssertStringDoesContain(t, "fat jar args", fatJar.Args["jarArgs"], "--ignore-duplicates")
+	android.AssertStringDoesContain(t, "fat jar command", fatJar.RuleParams.Command, " -j ")
+
+	// The classes of the transitive static dependency are in the jar of the direct one.
+	barCombined := ctx.ModuleForTests("bar", "linux_glibc_common").Output("combined/bar.jar")
+	android.AssertPathsRelativeToTopEquals(t, "bar combined inputs", []string{
+		"out/soong/.intermediates/bar/linux_glibc_common/javac/bar.jar",
+		"out/soong/.intermediates/baz/linux_glibc_common/javac/baz.jar",
+	}, barCombined.Inputs)
+
+	outputFiles, err := foo.Module().(*Library).OutputFiles(".fatjar")
+	if err != nil {
+		t.Fatalf("unexpected error getting .fatjar: %s", err)
+	}
+	android.AssertPathsRelativeToTopEquals(t, ".fatjar", []string{fatJar.Output.String()}, outputFiles)
+
+	qux := ctx.ModuleForTests("qux", "linux_glibc_common")
+	android.AssertStringDoesNotContain(t, "qux fat jar args", qux.Output("fatjar/qux.jar").Args["jarArgs"],
+		"--ignore-duplicates")
+
+	if _, err := ctx.ModuleForTests("bar", "linux_glibc_common").Module().(*Library).OutputFiles(".fatjar"); err == nil {
+		t.Errorf("expected an error getting .fatjar without flatten_static_libs")
+	}
+}
+
+func TestFlattenStaticLibsErrors(t *testing.T) {
+	testJavaError(t, `flatten_static_libs_duplicates: must be "first" or "error", got "last"`, `
+		java_library_host {
+			name: "foo",
+			srcs: ["a.java"],
+			flatten_static_libs: true,
+			flatten_static_libs_duplicates: "last",
+		}
+	`)
+}
+
 func TestErrorproneEnabledOnlyByEnvironmentVariable(t *testing.T) {
 	bp := `
 		java_library {
@@ -1557,3 +2049,245 @@ func TestDataDeviceBinsAutogenTradefedConfig(t *testing.T) {
 		t.Errorf("foo extraConfigs %v does not contain %q", autogen.Args["extraConfigs"], expectedAutogenConfig)
 	}
 }
+
+func TestRuntimeLibs(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		PrepareForTestWithJavaSdkLibraryFiles,
+		FixtureWithLastReleaseApis("foo"),
+	).RunTestWithBp(t, `
+		java_sdk_library {
+			name: "foo",
+			srcs: ["a.java"],
+			api_packages: ["foo"],
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["b.java"],
+		}
+
+		java_library {
+			name: "baz",
+			srcs: ["c.java"],
+			sdk_version: "current",
+			runtime_libs: ["foo", "bar"],
+		}
+	`)
+
+	baz := result.ModuleForTests("baz", "android_common")
+	bazJavac := baz.Rule("javac")
+	for _, jar := range []string{"foo.jar", "foo.stubs.jar", "bar.jar"} {
+		android.AssertStringDoesNotContain(t, "baz javac classpath", bazJavac.Args["classpath"], jar)
+	}
+
+	requiredLibs, optionalLibs := baz.Module().(*Library).ClassLoaderContexts().UsesLibs()
+	android.AssertDeepEquals(t, "baz uses libs (required)", []string{"foo"}, requiredLibs)
+	android.AssertDeepEquals(t, "baz uses libs (optional)", []string{}, optionalLibs)
+}
+
+func TestJavaRelease(t *testing.T) {
+	ctx, _ := testJava(t, `
+		java_library_host {
+			name: "foo",
+			srcs: ["a.java"],
+			java_release: 11,
+		}
+
+		java_library_host {
+			name: "bar",
+			srcs: ["a.java"],
+			java_version: "11",
+		}
+	`)
+
+	hostVariant := ctx.Config().BuildOSCommonTarget.String()
+
+	foo := ctx.ModuleForTests("foo", hostVariant).Rule("javac")
+	android.AssertStringEquals(t, "foo javaVersionFlags", "--release 11", foo.Args["javaVersionFlags"])
+	android.AssertStringEquals(t, "foo bootClasspath", "", foo.Args["bootClasspath"])
+
+	fooTurbine := ctx.ModuleForTests("foo", hostVariant).Rule("turbine")
+	android.AssertStringEquals(t, "foo turbine javaVersionFlags", "--release 11", fooTurbine.Args["javaVersionFlags"])
+	android.AssertStringDoesNotContain(t, "foo turbineFlags", fooTurbine.Args["turbineFlags"], "--bootclasspath")
+
+	bar := ctx.ModuleForTests("bar", hostVariant).Rule("javac")
+	android.AssertStringEquals(t, "bar javaVersionFlags", "-source 11 -target 11", bar.Args["javaVersionFlags"])
+
+	barTurbine := ctx.ModuleForTests("bar", hostVariant).Rule("turbine")
+	android.AssertStringEquals(t, "bar turbine javaVersionFlags", "-source 11 -target 11", barTurbine.Args["javaVersionFlags"])
+}
+
+func TestJavaReleaseErrors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		bp            string
+		expectedError string
+	}{
+		{
+			name: "java_version",
+			bp: `
+				java_library_host {
+					name: "foo",
+					srcs: ["a.java"],
+					java_release: 11,
+					java_version: "11",
+				}`,
+			expectedError: `java_release: cannot be combined with java_version`,
+		},
+		{
+			name: "javacflags",
+			bp: `
+				java_library_host {
+					name: "foo",
+					srcs: ["a.java"],
+					java_release: 11,
+					javacflags: ["-source", "11"],
+				}`,
+			expectedError: `java_release: cannot be combined with "-source" in javacflags`,
+		},
+		{
+			name: "bootclasspath",
+			bp: `
+				java_library {
+					name: "foo",
+					srcs: ["a.java"],
+					java_release: 11,
+				}`,
+			expectedError: `java_release: cannot be combined with a bootclasspath or system modules`,
+		},
+		{
+			name: "unsupported version",
+			bp: `
+				java_library_host {
+					name: "foo",
+					srcs: ["a.java"],
+					java_release: 10,
+				}`,
+			expectedError: `java_release: Java language levels 10 is not supported`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testJavaError(t, tc.expectedError, tc.bp)
+		})
+	}
+}
+
+func TestJdkVersion(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+		android.FixtureAddFile("prebuilts/jdk/jdk17/linux-x86/bin/javac", nil),
+	).RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			jdk_version: "17",
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["a.java"],
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common").Rule("javac")
+	android.AssertStringEquals(t, "foo javac", "prebuilts/jdk/jdk17/linux-x86/bin/javac", foo.Args["javacCmd"])
+	android.AssertStringListContains(t, "foo javac dependencies", foo.Implicits.Strings(),
+		"prebuilts/jdk/jdk17/linux-x86/bin/javac")
+
+	bar := result.ModuleForTests("bar", "android_common").Rule("javac")
+	android.AssertStringEquals(t, "bar javac", "${config.JavacCmd}", bar.Args["javacCmd"])
+}
+
+func TestJdkVersionErrors(t *testing.T) {
+	testJavaError(t, `jdk_version: unsupported JDK version "8", must be one of \["11" "17"\]`, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			jdk_version: "8",
+		}
+	`)
+}
+
+func TestFlaggedDeps(t *testing.T) {
+	bp := `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			flagged_deps: ["RELEASE_FOO:bar"],
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["b.java"],
+		}
+	`
+
+	for _, enabled := range []bool{true, false} {
+		t.Run(strconv.FormatBool(enabled), func(t *testing.T) {
+			result := android.GroupFixturePreparers(
+				prepareForJavaTest,
+				android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+					variables.BuildFlags = map[string]string{"RELEASE_FOO": strconv.FormatBool(enabled)}
+				}),
+			).RunTestWithBp(t, bp)
+
+			foo := result.ModuleForTests("foo", "android_common")
+			var deps []string
+			result.VisitDirectDeps(foo.Module(), func(m blueprint.Module) {
+				deps = append(deps, m.Name())
+			})
+			android.AssertBoolEquals(t, "depends on bar", enabled, android.InList("bar", deps))
+
+			barJar := result.ModuleForTests("bar", "android_common").Rule("javac").Output.String()
+			combineJar := foo.MaybeDescription("for javac")
+			android.AssertBoolEquals(t, "bar is linked", enabled,
+				android.InList(barJar, combineJar.Inputs.Strings()))
+		})
+	}
+}
+
+func TestFlaggedDepsErrors(t *testing.T) {
+	testJavaError(t, `flagged_deps: "bar" is not in the form "<release flag>:<module>"`, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			flagged_deps: ["bar"],
+		}
+	`)
+}
+
+func TestJavaTestImportAutoGenConfig(t *testing.T) {
+	result := prepareForJavaTest.RunTestWithBp(t, `
+		java_test_import {
+			name: "foo",
+			jars: ["a.jar"],
+			test_suites: ["general-tests"],
+		}
+
+		java_test_import {
+			name: "bar",
+			jars: ["b.jar"],
+			test_suites: ["general-tests"],
+			auto_gen_config: false,
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	config := foo.Output("foo.config")
+	android.AssertStringEquals(t, "test config name", "foo", config.Args["name"])
+
+	entries := android.AndroidMkEntriesForTest(t, result.TestContext, foo.Module())[0]
+	android.AssertStringPathsRelativeToTopEquals(t, "LOCAL_FULL_TEST_CONFIG", result.Config,
+		[]string{"out/soong/.intermediates/foo/android_common/foo.config"},
+		entries.EntryMap["LOCAL_FULL_TEST_CONFIG"])
+	android.AssertDeepEquals(t, "LOCAL_COMPATIBILITY_SUITE",
+		[]string{"general-tests"}, entries.EntryMap["LOCAL_COMPATIBILITY_SUITE"])
+
+	bar := result.ModuleForTests("bar", "android_common")
+	if config := bar.MaybeOutput("bar.config"); config.Rule != nil {
+		t.Errorf("expected no test config to be generated with auto_gen_config: false")
+	}
+}
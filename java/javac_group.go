@@ -0,0 +1,97 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+// This file contains support for sharing header jar compilations between the modules of a
+// javac_group.
+//
+// A module that sets javac_group does not emit its own turbine build statement. Instead it writes
+// its header jar to a path derived from the group name and a hash of the turbine inputs, so that
+// modules in the same group with identical sources, classpaths and flags refer to the same header
+// jar. The javac_group singleton then emits a single turbine build statement for each such path.
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"android/soong/android"
+)
+
+// compileJavacGroupHeader returns the path of the header jar shared by the modules in the group
+// that compile the same sources with the same flags, and records the build statement producing it
+// for javacGroupSingleton.
+func (j *Module) compileJavacGroupHeader(ctx android.ModuleContext, group string,
+	srcFiles, srcJars android.Paths, flags javaBuilderFlags) android.WritablePath {
+
+	turbineFlags, deps := turbineFlags(ctx, flags)
+
+	h := sha256.New()
+	for _, input := range []string{
+		strings.Join(srcFiles.Strings(), " "),
+		strings.Join(srcJars.Strings(), " "),
+		strings.Join(deps.Strings(), " "),
+		flags.javacFlags,
+		flags.javaVersion.String(),
+		turbineFlags,
+	} {
+		h.Write([]byte(input))
+		h.Write([]byte{0})
+	}
+	key := fmt.Sprintf("%x", h.Sum(nil))
+
+	turbineJar := android.PathForOutput(ctx, "javac_group", group, key, "turbine.jar")
+	params := turbineHeaderBuildParams(ctx, turbineJar, srcFiles, srcJars, flags)
+	j.javacGroupTurbineParams = &params
+	return turbineJar
+}
+
+func javacGroupSingletonFactory() android.Singleton {
+	return &javacGroupSingleton{}
+}
+
+type javacGroupSingleton struct{}
+
+func (s *javacGroupSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	emitted := make(map[string]bool)
+	ctx.VisitAllModules(func(module android.Module) {
+		j, ok := module.(moduleWithJavacGroupTurbine)
+		if !ok {
+			return
+		}
+		params := j.javacGroupTurbine()
+		if params == nil {
+			return
+		}
+		// Modules with identical turbine inputs share the same output path, only emit the build
+		// statement for the first one.
+		output := params.Output.String()
+		if emitted[output] {
+			return
+		}
+		emitted[output] = true
+		ctx.Build(pctx, *params)
+	})
+}
+
+type moduleWithJavacGroupTurbine interface {
+	javacGroupTurbine() *android.BuildParams
+}
+
+func (j *Module) javacGroupTurbine() *android.BuildParams {
+	return j.javacGroupTurbineParams
+}
+
+var _ moduleWithJavacGroupTurbine = (*Module)(nil)
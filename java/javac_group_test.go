@@ -0,0 +1,61 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestJavacGroup(t *testing.T) {
+	result := PrepareForTestWithJavaDefaultModules.RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			javac_group: "group",
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["a.java"],
+			javac_group: "group",
+		}
+
+		java_library {
+			name: "baz",
+			srcs: ["a.java"],
+		}
+	`)
+
+	turbines := result.SingletonForTests("javac_group").AllOutputs()
+	if len(turbines) != 1 {
+		t.Fatalf("expected a single shared turbine output, got %q", turbines)
+	}
+	turbine := result.SingletonForTests("javac_group").Rule("turbine")
+
+	for _, name := range []string{"foo", "bar"} {
+		module := result.ModuleForTests(name, "android_common")
+		if module.MaybeRule("turbine").Rule != nil {
+			t.Errorf("expected %s not to have its own turbine rule", name)
+		}
+		combined := module.Output("turbine-combined/" + name + ".jar")
+		android.AssertPathsRelativeToTopEquals(t, name+" turbine-combined inputs",
+			[]string{turbine.Output.RelativeToTop().String()}, combined.Inputs)
+	}
+
+	// Modules outside the group still run turbine themselves.
+	result.ModuleForTests("baz", "android_common").Rule("turbine")
+}
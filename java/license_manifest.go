@@ -0,0 +1,99 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+)
+
+type licenseManifestProperties struct {
+	// If true, generate a license manifest that aggregates the license notices of this module and
+	// of all the modules that contribute to it. The manifest is available through the {.licenses}
+	// output tag.
+	License_manifest *bool
+}
+
+// licenseManifestEntry describes the licenses of a single module in a license manifest.
+type licenseManifestEntry struct {
+	name     string
+	licenses []string
+	notices  android.Paths
+}
+
+// licenseManifestEntryForCurrentModule returns the license manifest entry for the current module.
+func licenseManifestEntryForCurrentModule(ctx android.ModuleContext) licenseManifestEntry {
+	entry := licenseManifestEntry{
+		name:    ctx.ModuleName(),
+		notices: ctx.Module().EffectiveLicenseFiles(),
+	}
+	if info, ok := ctx.Provider(android.LicenseInfoProvider).(android.LicenseInfo); ok {
+		entry.licenses = info.Licenses
+	}
+	return entry
+}
+
+// licenseManifestEntryForModule returns the license manifest entry for a dependency of the current
+// module.
+func licenseManifestEntryForModule(ctx android.ModuleContext, module android.Module) licenseManifestEntry {
+	entry := licenseManifestEntry{
+		name:    ctx.OtherModuleName(module),
+		notices: module.EffectiveLicenseFiles(),
+	}
+	if ctx.OtherModuleHasProvider(module, android.LicenseInfoProvider) {
+		entry.licenses = ctx.OtherModuleProvider(module, android.LicenseInfoProvider).(android.LicenseInfo).Licenses
+	}
+	return entry
+}
+
+// buildLicenseManifest writes a manifest that lists, for each of the given modules, the license
+// modules that apply to it followed by the contents of its license notice files. Modules are
+// listed once each, sorted by name.
+func buildLicenseManifest(ctx android.ModuleContext, entries []licenseManifestEntry) android.Path {
+	byName := make(map[string]licenseManifestEntry)
+	var names []string
+	for _, entry := range entries {
+		if _, exists := byName[entry.name]; !exists {
+			byName[entry.name] = entry
+			names = append(names, entry.name)
+		}
+	}
+	sort.Strings(names)
+
+	manifest := android.PathForModuleOut(ctx, "licenses", "license_manifest.txt")
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().Text("echo -n >").Output(manifest)
+	for _, name := range names {
+		entry := byName[name]
+		licenses := "none"
+		if len(entry.licenses) > 0 {
+			licenses = strings.Join(android.SortedUniqueStrings(entry.licenses), " ")
+		}
+		rule.Command().
+			Text("echo").Text(proptools.ShellEscape(name + ": " + licenses)).
+			Text(">>").Text(manifest.String())
+		if len(entry.notices) > 0 {
+			rule.Command().
+				Text("cat").Inputs(entry.notices).
+				Text(">>").Text(manifest.String())
+		}
+	}
+	rule.Build("license_manifest", "license manifest")
+	return manifest
+}
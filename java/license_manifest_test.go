@@ -0,0 +1,124 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+var prepareForTestWithLicenseManifest = android.GroupFixturePreparers(
+	android.PrepareForTestWithLicenses,
+	android.FixtureMergeMockFs(android.MockFS{
+		"licenses/foo-LICENSE": nil,
+		"licenses/bar-LICENSE": nil,
+	}),
+	android.FixtureAddTextFile("licenses/Android.bp", `
+		license {
+			name: "foo_license",
+			license_text: ["foo-LICENSE"],
+			visibility: ["//visibility:public"],
+		}
+
+		license {
+			name: "bar_license",
+			license_text: ["bar-LICENSE"],
+			visibility: ["//visibility:public"],
+		}
+	`),
+)
+
+func TestLibraryLicenseManifest(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+		prepareForTestWithLicenseManifest,
+	).RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			static_libs: ["bar"],
+			libs: ["baz"],
+			licenses: ["foo_license"],
+			license_manifest: true,
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["b.java"],
+			licenses: ["bar_license"],
+		}
+
+		java_library {
+			name: "baz",
+			srcs: ["c.java"],
+			licenses: ["bar_license"],
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	manifest := foo.Output("licenses/license_manifest.txt")
+	command := manifest.RuleParams.Command
+	android.AssertStringDoesContain(t, "foo entry", command, "echo 'foo: foo_license'")
+	android.AssertStringDoesContain(t, "bar entry", command, "echo 'bar: bar_license'")
+	android.AssertStringDoesNotContain(t, "baz entry", command, "echo 'baz:")
+	android.AssertPathsRelativeToTopEquals(t, "notices",
+		[]string{"licenses/bar-LICENSE", "licenses/foo-LICENSE"}, manifest.Implicits)
+
+	outputs, err := foo.Module().(*Library).OutputFiles(".licenses")
+	if err != nil {
+		t.Fatalf("unexpected error getting .licenses output: %s", err)
+	}
+	android.AssertPathsRelativeToTopEquals(t, ".licenses", []string{
+		"out/soong/.intermediates/foo/android_common/licenses/license_manifest.txt",
+	}, outputs)
+}
+
+func TestPlatformBootclasspathLicenseManifest(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForTestWithPlatformBootclasspath,
+		prepareForTestWithLicenseManifest,
+		FixtureConfigureBootJars("platform:foo", "platform:bar"),
+	).RunTestWithBp(t, `
+		platform_bootclasspath {
+			name: "platform-bootclasspath",
+			license_manifest: true,
+		}
+
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			system_modules: "none",
+			sdk_version: "none",
+			compile_dex: true,
+			licenses: ["foo_license"],
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["b.java"],
+			system_modules: "none",
+			sdk_version: "none",
+			compile_dex: true,
+			licenses: ["bar_license"],
+		}
+	`)
+
+	manifest := result.ModuleForTests("platform-bootclasspath", "android_common").
+		Output("licenses/license_manifest.txt")
+	command := manifest.RuleParams.Command
+	android.AssertStringDoesContain(t, "foo entry", command, "echo 'foo: foo_license'")
+	android.AssertStringDoesContain(t, "bar entry", command, "echo 'bar: bar_license'")
+}
@@ -0,0 +1,136 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+// This file contains support for emitting Maven pom.xml metadata for java libraries, so that their
+// outputs can be published as Maven artifacts.
+
+import (
+	"encoding/xml"
+	"strings"
+
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+)
+
+type mavenProperties struct {
+	// Maven coordinates of the artifact built by this module. When set, a pom.xml describing the
+	// artifact is generated and made available through the {.pom} output tag.
+	Maven struct {
+		// the Maven groupId of the artifact, e.g. "com.android.foo".
+		Group_id *string
+
+		// the Maven artifactId of the artifact.
+		Artifact_id *string
+
+		// the version of the artifact.
+		Version *string
+
+		// list of Maven coordinates of the artifacts this artifact depends on, each in the form
+		// "<group_id>:<artifact_id>:<version>".
+		Deps []string
+	}
+}
+
+type pomDependency struct {
+	GroupId    string `xml:"groupId"`
+	ArtifactId string `xml:"artifactId"`
+	Version    string `xml:"version"`
+}
+
+type pomDependencies struct {
+	Dependency []pomDependency `xml:"dependency"`
+}
+
+type pomProject struct {
+	XMLName      xml.Name         `xml:"project"`
+	Xmlns        string           `xml:"xmlns,attr"`
+	ModelVersion string           `xml:"modelVersion"`
+	GroupId      string           `xml:"groupId"`
+	ArtifactId   string           `xml:"artifactId"`
+	Version      string           `xml:"version"`
+	Dependencies *pomDependencies `xml:"dependencies,omitempty"`
+}
+
+// isSet returns true if any of the maven properties have been specified.
+func (p *mavenProperties) isSet() bool {
+	m := p.Maven
+	return m.Group_id != nil || m.Artifact_id != nil || m.Version != nil || len(m.Deps) > 0
+}
+
+// buildPom validates the maven properties and generates a pom.xml file from them. It returns nil
+// if the maven properties are not set or are invalid.
+func buildPom(ctx android.ModuleContext, props *mavenProperties) android.Path {
+	if !props.isSet() {
+		return nil
+	}
+	m := props.Maven
+
+	project := pomProject{
+		Xmlns:        "http://maven.apache.org/POM/4.0.0",
+		ModelVersion: "4.0.0",
+		GroupId:      proptools.String(m.Group_id),
+		ArtifactId:   proptools.String(m.Artifact_id),
+		Version:      proptools.String(m.Version),
+	}
+
+	valid := true
+	for _, required := range []struct {
+		name  string
+		value string
+	}{
+		{"maven.group_id", project.GroupId},
+		{"maven.artifact_id", project.ArtifactId},
+		{"maven.version", project.Version},
+	} {
+		if required.value == "" {
+			ctx.PropertyErrorf(required.name, "must be set when any maven property is set")
+			valid = false
+		}
+	}
+
+	var deps []pomDependency
+	for _, dep := range m.Deps {
+		parts := strings.Split(dep, ":")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			ctx.PropertyErrorf("maven.deps", "%q is not of the form <group_id>:<artifact_id>:<version>", dep)
+			valid = false
+			continue
+		}
+		deps = append(deps, pomDependency{
+			GroupId:    parts[0],
+			ArtifactId: parts[1],
+			Version:    parts[2],
+		})
+	}
+
+	if !valid {
+		return nil
+	}
+	if len(deps) > 0 {
+		project.Dependencies = &pomDependencies{Dependency: deps}
+	}
+
+	data, err := xml.MarshalIndent(project, "", "  ")
+	if err != nil {
+		ctx.ModuleErrorf("failed to generate pom.xml: %s", err)
+		return nil
+	}
+
+	pomFile := android.PathForModuleOut(ctx, "pom.xml")
+	android.WriteFileRule(ctx, pomFile, xml.Header+string(data))
+	return pomFile
+}
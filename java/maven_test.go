@@ -0,0 +1,84 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestMavenPom(t *testing.T) {
+	ctx, _ := testJava(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			maven: {
+				group_id: "com.android.foo",
+				artifact_id: "foo",
+				version: "1.0",
+				deps: [
+					"com.android.bar:bar:2.0",
+					"com.android.baz:baz:3.1",
+				],
+			},
+		}
+	`)
+
+	foo := ctx.ModuleForTests("foo", "android_common")
+	pom := android.ContentFromFileRuleForTests(t, foo.Output("pom.xml"))
+
+	for _, expected := range []string{
+		"<groupId>com.android.foo</groupId>",
+		"<artifactId>foo</artifactId>",
+		"<version>1.0</version>",
+		"<dependency>\n      <groupId>com.android.bar</groupId>\n      <artifactId>bar</artifactId>\n      <version>2.0</version>\n    </dependency>",
+		"<dependency>\n      <groupId>com.android.baz</groupId>\n      <artifactId>baz</artifactId>\n      <version>3.1</version>\n    </dependency>",
+	} {
+		android.AssertStringDoesContain(t, "pom.xml", pom, expected)
+	}
+
+	outputs, err := foo.Module().(android.OutputFileProducer).OutputFiles(".pom")
+	if err != nil {
+		t.Fatalf("unexpected error getting {.pom} output: %s", err)
+	}
+	android.AssertPathsRelativeToTopEquals(t, "{.pom} output", []string{"out/soong/.intermediates/foo/android_common/pom.xml"}, outputs)
+}
+
+func TestMavenPomErrors(t *testing.T) {
+	testJavaError(t, `maven.version: must be set`, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			maven: {
+				group_id: "com.android.foo",
+				artifact_id: "foo",
+			},
+		}
+	`)
+
+	testJavaError(t, `maven.deps: "bar" is not of the form`, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			maven: {
+				group_id: "com.android.foo",
+				artifact_id: "foo",
+				version: "1.0",
+				deps: ["bar"],
+			},
+		}
+	`)
+}
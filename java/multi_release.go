@@ -0,0 +1,113 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+// This file contains support for building multi-release jars, which contain classes compiled for
+// newer Java versions under META-INF/versions/<version>/ in addition to the base classes.
+
+import (
+	"android/soong/android"
+)
+
+const multiReleaseVersionsDir = "META-INF/versions/"
+
+// compileMultiReleaseClasses compiles the multi_release.srcs_11 sources against the base classes
+// and returns a jar containing the resulting classes under META-INF/versions/11/.
+func (j *Module) compileMultiReleaseClasses(ctx android.ModuleContext, jarName string,
+	baseJars android.Paths, flags javaBuilderFlags) android.Path {
+
+	if flags.javaVersion >= JAVA_VERSION_11 {
+		ctx.PropertyErrorf("multi_release.srcs_11",
+			"requires the module to target a Java version below 11, got %s", flags.javaVersion)
+		return nil
+	}
+	if versions := j.properties.Multi_release.Versions; len(versions) > 0 &&
+		!checkMultiReleaseVersions(ctx, versions, flags.javaVersion) {
+		return nil
+	}
+	if len(j.properties.Multi_release.Srcs_11) == 0 {
+		ctx.PropertyErrorf("multi_release.srcs_11", "must be set for version 11 of multi_release.versions")
+		return nil
+	}
+
+	srcFiles := android.PathsForModuleSrc(ctx, j.properties.Multi_release.Srcs_11)
+	if len(srcFiles.FilterOutByExt(".java")) > 0 {
+		ctx.PropertyErrorf("multi_release.srcs_11", "must only contain .java files")
+		return nil
+	}
+
+	// The versioned classes may refer to the base classes, but annotation processing has already
+	// been run over the base sources.
+	flags.javaVersion = JAVA_VERSION_11
+	flags.classpath = append(classpath(baseJars), flags.classpath...)
+	flags.processorPath = nil
+	flags.processors = nil
+
+	classes := android.PathForModuleOut(ctx, "multi_release", "11", "javac", jarName)
+	transformJavaToClasses(ctx, classes, -1, srcFiles, nil, flags, nil,
+		"multi_release/11", "javac for java 11")
+
+	versioned := android.PathForModuleOut(ctx, "multi_release", "11", jarName)
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().
+		BuiltTool("zip2zip").
+		FlagWithInput("-i ", classes).
+		FlagWithOutput("-o ", versioned).
+		Textf("'**/*.class:%s11'", multiReleaseVersionsDir)
+	rule.Build("multi_release_11", "multi-release classes for java 11")
+
+	return versioned
+}
+
+// checkMultiReleaseVersions reports an error and returns false if the multi_release.versions of a
+// module targeting the given Java version aren't the module's version followed by 11, the only
+// version that supports version-specific sources.
+func checkMultiReleaseVersions(ctx android.ModuleContext, versions []string, base javaVersion) bool {
+	var parsed []javaVersion
+	for _, v := range versions {
+		version := normalizeJavaVersionForProperty(ctx, "multi_release.versions", v)
+		if version == JAVA_VERSION_UNSUPPORTED {
+			return false
+		}
+		parsed = append(parsed, version)
+	}
+	if parsed[0] != base {
+		ctx.PropertyErrorf("multi_release.versions",
+			"must start with the Java version of the module %s, got %s", base, parsed[0])
+		return false
+	}
+	if len(parsed) != 2 || parsed[1] != JAVA_VERSION_11 {
+		ctx.PropertyErrorf("multi_release.versions",
+			"only version 11 can follow the Java version of the module, got %q", versions)
+		return false
+	}
+	return true
+}
+
+// multiReleaseManifest returns a jar manifest that contains the Multi-Release attribute in
+// addition to the contents of the given manifest, if any.
+func multiReleaseManifest(ctx android.ModuleContext, manifest android.OptionalPath) android.OptionalPath {
+	out := android.PathForModuleOut(ctx, "multi_release", "manifest.txt")
+	rule := android.NewRuleBuilder(pctx, ctx)
+	if manifest.Valid() {
+		// awk 1 copies the manifest while ensuring it ends with a newline.
+		rule.Command().Text("awk 1").Input(manifest.Path()).Text(">").Output(out)
+		rule.Command().Text("echo 'Multi-Release: true' >>").Output(out)
+	} else {
+		rule.Command().Text("echo 'Multi-Release: true' >").Output(out)
+	}
+	rule.Build("multi_release_manifest", "multi-release manifest")
+	return android.OptionalPathForPath(out)
+}
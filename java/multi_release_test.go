@@ -0,0 +1,115 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestMultiRelease(t *testing.T) {
+	result := PrepareForTestWithJavaDefaultModules.RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			java_version: "1.8",
+			multi_release: {
+				versions: ["8", "11"],
+				srcs_11: ["b.java"],
+			},
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+
+	baseJavac := foo.Output("javac/foo.jar")
+	javac11 := foo.Output("multi_release/11/javac/foo.jar")
+	android.AssertStringEquals(t, "java 11 javaVersionFlags", "-source 11 -target 11",
+		javac11.Args["javaVersionFlags"])
+	android.AssertPathsRelativeToTopEquals(t, "java 11 srcs", []string{"b.java"}, javac11.Inputs)
+	android.AssertStringDoesContain(t, "java 11 classpath", javac11.Args["classpath"],
+		baseJavac.Output.RelativeToTop().String())
+
+	repackage := foo.Rule("multi_release_11")
+	android.AssertStringDoesContain(t, "versioned classes", repackage.RuleParams.Command,
+		"'**/*.class:META-INF/versions/11'")
+
+	manifest := foo.Rule("multi_release_manifest")
+	android.AssertStringDoesContain(t, "manifest", manifest.RuleParams.Command, "Multi-Release: true")
+
+	combined := foo.Output("combined/foo.jar")
+	android.AssertPathsRelativeToTopEquals(t, "combined inputs", []string{
+		"out/soong/.intermediates/foo/android_common/javac/foo.jar",
+		"out/soong/.intermediates/foo/android_common/multi_release/11/foo.jar",
+	}, combined.Inputs)
+	android.AssertStringDoesContain(t, "combined manifest", combined.Args["jarArgs"],
+		"multi_release/manifest.txt")
+}
+
+func TestMultiReleaseRequiresOlderJavaVersion(t *testing.T) {
+	testJavaError(t, `multi_release.srcs_11: requires the module to target a Java version below 11`, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			java_version: "11",
+			multi_release: {
+				srcs_11: ["b.java"],
+			},
+		}
+	`)
+}
+
+func TestMultiReleaseVersionsErrors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		multiRelease  string
+		expectedError string
+	}{
+		{
+			name:          "base version mismatch",
+			multiRelease:  `versions: ["1.7", "11"], srcs_11: ["b.java"]`,
+			expectedError: `multi_release.versions: must start with the Java version of the module 1.8, got 1.7`,
+		},
+		{
+			name:          "unsupported version",
+			multiRelease:  `versions: ["8", "9"], srcs_11: ["b.java"]`,
+			expectedError: `multi_release.versions: only version 11 can follow the Java version of the module`,
+		},
+		{
+			name:          "unrecognized version",
+			multiRelease:  `versions: ["8", "eleven"], srcs_11: ["b.java"]`,
+			expectedError: `multi_release.versions: Unrecognized Java language level`,
+		},
+		{
+			name:          "missing srcs",
+			multiRelease:  `versions: ["8", "11"]`,
+			expectedError: `multi_release.srcs_11: must be set for version 11 of multi_release.versions`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testJavaError(t, tc.expectedError, `
+				java_library {
+					name: "foo",
+					srcs: ["a.java"],
+					java_version: "1.8",
+					multi_release: {`+tc.multiRelease+`},
+				}
+			`)
+		})
+	}
+}
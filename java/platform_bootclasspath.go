@@ -57,12 +57,17 @@ type platformBootclasspathModule struct {
 
 	// Path to the monolithic hiddenapi-unsupported.csv file.
 	hiddenAPIMetadataCSV android.OutputPath
+
+	// Path to the license manifest of all the boot jars, generated when license_manifest is set.
+	licenseManifest android.Path
 }
 
 type platformBootclasspathProperties struct {
 	BootclasspathFragmentsDepsProperties
 
 	Hidden_api HiddenAPIFlagFileProperties
+
+	licenseManifestProperties
 }
 
 func platformBootclasspathFactory() android.SingletonModule {
@@ -95,6 +100,11 @@ func (b *platformBootclasspathModule) OutputFiles(tag string) (android.Paths, er
 		return android.Paths{b.hiddenAPIIndexCSV}, nil
 	case "hiddenapi-metadata.csv":
 		return android.Paths{b.hiddenAPIMetadataCSV}, nil
+	case ".licenses":
+		if b.licenseManifest != nil {
+			return android.Paths{b.licenseManifest}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but no output file was found.", tag)
 	}
 
 	return nil, fmt.Errorf("unknown tag %s", tag)
@@ -181,9 +191,18 @@ func (b *platformBootclasspathModule) GenerateAndroidBuildActions(ctx android.Mo
 	// ART modules are checked by the art-bootclasspath-fragment.
 	b.checkPlatformModules(ctx, platformModules)
 	b.checkApexModules(ctx, apexModules)
+	b.checkNeverBootclasspathModules(ctx, allModules)
 
 	b.generateClasspathProtoBuildActions(ctx)
 
+	if Bool(b.properties.License_manifest) {
+		var entries []licenseManifestEntry
+		for _, module := range b.configuredModules {
+			entries = append(entries, licenseManifestEntryForModule(ctx, module))
+		}
+		b.licenseManifest = buildLicenseManifest(ctx, entries)
+	}
+
 	bootDexJarByModule := b.generateHiddenAPIBuildActions(ctx, b.configuredModules, b.fragments)
 	buildRuleForBootJarsPackageCheck(ctx, bootDexJarByModule)
 
@@ -266,6 +285,23 @@ func (b *platformBootclasspathModule) checkApexModules(ctx android.ModuleContext
 	}
 }
 
+// neverBootclasspathModule is implemented by modules that can declare that they must never be
+// configured as a boot jar.
+type neverBootclasspathModule interface {
+	neverBootclasspath() bool
+}
+
+// checkNeverBootclasspathModules ensures that none of the supplied modules has declared that it
+// must never be configured as a boot jar.
+func (b *platformBootclasspathModule) checkNeverBootclasspathModules(ctx android.ModuleContext, modules []android.Module) {
+	for _, m := range modules {
+		if n, ok := m.(neverBootclasspathModule); ok && n.neverBootclasspath() {
+			ctx.ModuleErrorf("module %q sets never_bootclasspath: true but is configured as a boot jar, remove it from the boot jars configuration",
+				ctx.OtherModuleName(m))
+		}
+	}
+}
+
 func (b *platformBootclasspathModule) getImageConfig(ctx android.EarlyModuleContext) *bootImageConfig {
 	return defaultBootImageConfig(ctx)
 }
@@ -61,6 +61,7 @@ type pluginAttributes struct {
 	*javaCommonAttributes
 	Deps            bazel.LabelListAttribute
 	Processor_class *string
+	Generates_api   *bool
 }
 
 // ConvertWithBp2build is used to convert android_app to Bazel.
@@ -80,6 +81,7 @@ func (p *Plugin) ConvertWithBp2build(ctx android.TopDownMutatorContext) {
 		javaCommonAttributes: commonAttrs,
 		Deps:                 deps,
 		Processor_class:      processorClass,
+		Generates_api:        p.pluginProperties.Generates_api,
 	}
 
 	props := bazel.BazelTargetModuleProperties{
@@ -0,0 +1,58 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+)
+
+// buildPrebuiltBom writes a bill of materials of the java_import modules the module transitively
+// depends on, with one "<module> <sha256>  <jar>" line per jar of each java_import.
+func buildPrebuiltBom(ctx android.ModuleContext) android.Path {
+	bom := android.PathForModuleOut(ctx, "prebuilt-bom", ctx.ModuleName()+".txt")
+
+	var imports []*Import
+	seen := make(map[*Import]bool)
+	ctx.WalkDeps(func(child, parent android.Module) bool {
+		if !ctx.OtherModuleHasProvider(child, JavaInfoProvider) {
+			return false
+		}
+		if imp, ok := child.(*Import); ok && !seen[imp] {
+			seen[imp] = true
+			imports = append(imports, imp)
+		}
+		return true
+	})
+
+	if len(imports) == 0 {
+		android.WriteFileRule(ctx, bom, "")
+		return bom
+	}
+
+	rule := android.NewRuleBuilder(pctx, ctx)
+	cmd := rule.Command().Text("(")
+	for _, imp := range imports {
+		name := ctx.OtherModuleName(imp)
+		for _, jar := range imp.prebuiltJars {
+			cmd.Text("echo").Text(proptools.ShellEscape(name)).Text(`"$(sha256sum`).Input(jar).Text(`)";`)
+		}
+	}
+	cmd.Text(")").FlagWithOutput("> ", bom)
+	rule.Build("prebuilt_bom", "prebuilt bill of materials")
+
+	return bom
+}
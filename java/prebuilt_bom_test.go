@@ -0,0 +1,54 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestPrebuiltBom(t *testing.T) {
+	result := prepareForJavaTest.RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			static_libs: ["bar"],
+			write_prebuilt_bom: true,
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["b.java"],
+			libs: ["baz"],
+		}
+
+		java_import {
+			name: "baz",
+			jars: ["baz.jar"],
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	bom := foo.Output("prebuilt-bom/foo.txt")
+	android.AssertStringListContains(t, "transitive prebuilt jar", bom.Inputs.Strings(), "baz.jar")
+	android.AssertStringDoesContain(t, "hash command", bom.RuleParams.Command,
+		"echo baz \"$$(sha256sum baz.jar)\";")
+
+	outputs, err := foo.Module().(*Library).OutputFiles(".prebuilt-bom")
+	android.AssertDeepEquals(t, "error", nil, err)
+	android.AssertPathsRelativeToTopEquals(t, ".prebuilt-bom output",
+		[]string{"out/soong/.intermediates/foo/android_common/prebuilt-bom/foo.txt"}, outputs)
+}
@@ -392,6 +392,10 @@ type sdkLibraryProperties struct {
 	// list of package names that must be hidden from the API
 	Hidden_api_packages []string
 
+	// list of package names whose classes are left out of the public stubs, even if they are in
+	// srcs. Unlike hidden_api_packages, the packages remain part of the other API scopes.
+	Stubs_exclude_packages []string
+
 	// the relative path to the directory containing the api specification files.
 	// Defaults to "api".
 	Api_dir *string
@@ -536,6 +540,10 @@ type scopePaths struct {
 
 	// Extracted annotations.
 	annotationsZip android.OptionalPath
+
+	// A diff of the API specification file of the public scope and of this scope, listing the API
+	// added by this scope. Only set by java_sdk_library for scopes other than public.
+	apiDeltaFilePath android.OptionalPath
 }
 
 func (paths *scopePaths) extractStubsLibraryInfoFromDependency(ctx android.ModuleContext, dep android.Module) error {
@@ -1114,18 +1122,24 @@ func (module *SdkLibrary) getGeneratedApiScopes(ctx android.EarlyModuleContext)
 
 	// Now check to make sure that any scope that is extended by an enabled scope is also
 	// enabled.
+	checkEnabledApiScopesAreSupersets(ctx, enabledScopes)
+
+	return generatedScopes
+}
+
+// checkEnabledApiScopesAreSupersets reports an error for each enabled api scope that extends a
+// scope which is not enabled, e.g. an enabled system scope when the public scope is disabled.
+func checkEnabledApiScopesAreSupersets(ctx android.EarlyModuleContext, enabledScopes map[*apiScope]struct{}) {
 	for _, scope := range allApiScopes {
-		if _, ok := enabledScopes[scope]; ok {
-			extends := scope.extends
-			if extends != nil {
-				if _, ok := enabledScopes[extends]; !ok {
-					ctx.ModuleErrorf("enabled api scope %q depends on disabled scope %q", scope, extends)
-				}
+		if _, ok := enabledScopes[scope]; !ok {
+			continue
+		}
+		if extends := scope.extends; extends != nil {
+			if _, ok := enabledScopes[extends]; !ok {
+				ctx.ModuleErrorf("enabled api scope %q depends on disabled scope %q", scope, extends)
 			}
 		}
 	}
-
-	return generatedScopes
 }
 
 var _ android.ModuleWithMinSdkVersionCheck = (*SdkLibrary)(nil)
@@ -1219,7 +1233,56 @@ func (module *SdkLibrary) DepsMutator(ctx android.BottomUpMutatorContext) {
 	}
 }
 
+// A regular expression to match the tags that reference the API delta file of a scope, e.g.
+// .module-lib-delta.txt.
+var apiDeltaTagRegexp = regexp.MustCompile(`^\.(.+)-delta\.txt$`)
+
+var sdkLibraryApiDeltaRule = pctx.AndroidStaticRule("sdkLibraryApiDelta",
+	blueprint.RuleParams{
+		// diff exits with 1 when the files differ, which isn't an error here.
+		Command: `(diff -u $base $in > $out; [ $$? -le 1 ])`,
+	},
+	"base")
+
+// buildApiDeltaFiles writes, for each scope other than public, a diff of the API specification
+// files of the public scope and of the scope, so the API added by the scope can be reviewed.
+func (module *SdkLibrary) buildApiDeltaFiles(ctx android.ModuleContext) {
+	publicPaths := module.findScopePaths(apiScopePublic)
+	if publicPaths == nil || !publicPaths.currentApiFilePath.Valid() {
+		return
+	}
+	publicApiFile := publicPaths.currentApiFilePath.Path()
+
+	for _, scope := range module.getGeneratedApiScopes(ctx) {
+		paths := module.findScopePaths(scope)
+		if scope == apiScopePublic || paths == nil || !paths.currentApiFilePath.Valid() {
+			continue
+		}
+		deltaFile := android.PathForModuleOut(ctx, "api_delta", scope.name+"-delta.txt")
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        sdkLibraryApiDeltaRule,
+			Description: scope.name + " api delta",
+			Input:       paths.currentApiFilePath.Path(),
+			Implicit:    publicApiFile,
+			Output:      deltaFile,
+			Args: map[string]string{
+				"base": publicApiFile.String(),
+			},
+		})
+		paths.apiDeltaFilePath = android.OptionalPathForPath(deltaFile)
+	}
+}
+
 func (module *SdkLibrary) OutputFiles(tag string) (android.Paths, error) {
+	if groups := apiDeltaTagRegexp.FindStringSubmatch(tag); groups != nil {
+		if scope, ok := scopeByName[groups[1]]; ok && scope != apiScopePublic {
+			if paths := module.findScopePaths(scope); paths != nil && paths.apiDeltaFilePath.Valid() {
+				return android.Paths{paths.apiDeltaFilePath.Path()}, nil
+			}
+			return nil, fmt.Errorf("api delta not available for api scope %s", scope.name)
+		}
+	}
+
 	paths, err := module.commonOutputFiles(tag)
 	if paths != nil || err != nil {
 		return paths, err
@@ -1268,6 +1331,8 @@ func (module *SdkLibrary) GenerateAndroidBuildActions(ctx android.ModuleContext)
 		}
 	})
 
+	module.buildApiDeltaFiles(ctx)
+
 	// Make the set of components exported by this module available for use elsewhere.
 	exportedComponentInfo := android.ExportedComponentsInfo{Components: android.SortedStringKeys(exportedComponents)}
 	ctx.SetProvider(android.ExportedComponentsInfoProvider, exportedComponentInfo)
@@ -1528,6 +1593,11 @@ func (module *SdkLibrary) createStubsSourcesAndApi(mctx android.DefaultableHookC
 		props.Output_javadoc_comments = proptools.BoolPtr(true)
 	}
 
+	if apiScope == apiScopePublic && len(module.sdkLibraryProperties.Stubs_exclude_packages) != 0 {
+		droidstubsArgs = append(droidstubsArgs,
+			android.JoinWithPrefix(module.sdkLibraryProperties.Stubs_exclude_packages, "--hide-package "))
+	}
+
 	// Add in scope specific arguments.
 	droidstubsArgs = append(droidstubsArgs, scopeSpecificDroidstubsArgs...)
 	props.Arg_files = module.sdkLibraryProperties.Droiddoc_option_files
@@ -536,6 +536,41 @@ func TestJavaSdkLibrary_Deps(t *testing.T) {
 	})
 }
 
+func TestJavaSdkLibrary_StubsExcludePackages(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		PrepareForTestWithJavaSdkLibraryFiles,
+		FixtureWithLastReleaseApis("sdklib"),
+	).RunTestWithBp(t, `
+		java_sdk_library {
+			name: "sdklib",
+			srcs: ["a.java"],
+			sdk_version: "none",
+			system_modules: "none",
+			stubs_exclude_packages: ["sdklib.internal", "sdklib.experimental"],
+			public: {
+				enabled: true,
+			},
+			system: {
+				enabled: true,
+			},
+		}
+		`)
+
+	// The excluded packages are hidden from the public stubs, so their classes don't end up in the
+	// public stubs jar.
+	publicCommand := android.RuleBuilderSboxProtoForTests(t,
+		result.ModuleForTests("sdklib.stubs.source", "android_common").Output("metalava.sbox.textproto")).
+		Commands[0].GetCommand()
+	android.AssertStringDoesContain(t, "public stubs command", publicCommand,
+		"--hide-package sdklib.internal --hide-package sdklib.experimental")
+
+	systemCommand := android.RuleBuilderSboxProtoForTests(t,
+		result.ModuleForTests("sdklib.stubs.source.system", "android_common").Output("metalava.sbox.textproto")).
+		Commands[0].GetCommand()
+	android.AssertStringDoesNotContain(t, "system stubs command", systemCommand, "--hide-package sdklib.internal")
+}
+
 func TestJavaSdkLibraryImport_AccessOutputFiles(t *testing.T) {
 	prepareForJavaTest.RunTestWithBp(t, `
 		java_sdk_library_import {
@@ -635,6 +670,62 @@ func TestJavaSdkLibrary_InvalidScopes(t *testing.T) {
 		`)
 }
 
+func TestJavaSdkLibrary_InvalidScopeChain(t *testing.T) {
+	testCases := []struct {
+		name     string
+		scopes   string
+		expected string
+	}{
+		{
+			name: "system without public",
+			scopes: `
+				public: { enabled: false },
+				system: { enabled: true },
+			`,
+			expected: `enabled api scope "system" depends on disabled scope "public"`,
+		},
+		{
+			name: "test without system",
+			scopes: `
+				public: { enabled: true },
+				system: { enabled: false },
+				test: { enabled: true },
+			`,
+			expected: `enabled api scope "test" depends on disabled scope "system"`,
+		},
+		{
+			name: "module-lib without system",
+			scopes: `
+				public: { enabled: true },
+				system: { enabled: false },
+				module_lib: { enabled: true },
+			`,
+			expected: `enabled api scope "module-lib" depends on disabled scope "system"`,
+		},
+		{
+			name: "system-server without public",
+			scopes: `
+				public: { enabled: false },
+				system_server: { enabled: true },
+			`,
+			expected: `enabled api scope "system-server" depends on disabled scope "public"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testJavaError(t, `module "foo": `+tc.expected, `
+				java_sdk_library {
+					name: "foo",
+					srcs: ["a.java", "b.java"],
+					api_packages: ["foo"],
+					`+tc.scopes+`
+				}
+			`)
+		})
+	}
+}
+
 func TestJavaSdkLibrary_SdkVersion_ForScope(t *testing.T) {
 	android.GroupFixturePreparers(
 		prepareForJavaTest,
@@ -673,6 +764,53 @@ func TestJavaSdkLibrary_ModuleLib(t *testing.T) {
 		`)
 }
 
+func TestJavaSdkLibrary_ApiDelta(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		PrepareForTestWithJavaSdkLibraryFiles,
+		FixtureWithLastReleaseApis("foo"),
+	).RunTestWithBp(t, `
+		java_sdk_library {
+			name: "foo",
+			srcs: ["a.java", "b.java"],
+			api_packages: ["foo"],
+			system: {
+				enabled: true,
+			},
+			module_lib: {
+				enabled: true,
+			},
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["c.java"],
+			java_resources: [
+				":foo{.module-lib-delta.txt}",
+				":foo{.system-delta.txt}",
+			],
+		}
+		`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	publicApi := result.ModuleForTests("foo.stubs.source", "android_common").Output("metalava/foo.stubs.source_api.txt").Output
+	for _, scope := range []string{"module-lib", "system"} {
+		delta := foo.Output("api_delta/" + scope + "-delta.txt")
+		android.AssertStringEquals(t, scope+" delta base", publicApi.String(), delta.Args["base"])
+		android.AssertPathsRelativeToTopEquals(t, scope+" delta base dependency",
+			[]string{publicApi.RelativeToTop().String()}, delta.Implicits)
+	}
+
+	moduleLibApi := result.ModuleForTests("foo.stubs.source.module_lib", "android_common").Output("metalava/foo.stubs.source.module_lib_api.txt").Output
+	moduleLibDelta := foo.Output("api_delta/module-lib-delta.txt")
+	android.AssertPathRelativeToTopEquals(t, "module-lib delta input",
+		moduleLibApi.RelativeToTop().String(), moduleLibDelta.Input)
+
+	barResources := result.ModuleForTests("bar", "android_common").Output("res/bar.jar")
+	android.AssertStringListContains(t, "bar resources", barResources.Implicits.Strings(),
+		moduleLibDelta.Output.String())
+}
+
 func TestJavaSdkLibrary_SystemServer(t *testing.T) {
 	android.GroupFixturePreparers(
 		prepareForJavaTest,
@@ -601,3 +601,47 @@ func testClasspathTestCases(t *testing.T, classpathTestcases []classpathTestCase
 		})
 	}
 }
+
+func TestCustomSdkJar(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		android.FixtureAddFile("prebuilts/custom/android.jar", nil),
+	).RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			custom_sdk_jar: "prebuilts/custom/android.jar",
+			java_version: "1.8",
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["b.java"],
+			custom_sdk_jar: "prebuilts/custom/android.jar",
+		}
+	`)
+
+	fooJavac := result.ModuleForTests("foo", "android_common").Rule("javac")
+	android.AssertStringEquals(t, "foo bootClasspath",
+		"-bootclasspath prebuilts/custom/android.jar", fooJavac.Args["bootClasspath"])
+	android.AssertStringEquals(t, "foo classpath", "", fooJavac.Args["classpath"])
+
+	// When targeting Java 9 or higher the jar replaces the system modules of the sdk.
+	barJavac := result.ModuleForTests("bar", "android_common").Rule("javac")
+	android.AssertStringEquals(t, "bar bootClasspath", "--system=none", barJavac.Args["bootClasspath"])
+	android.AssertStringEquals(t, "bar classpath",
+		"-classpath prebuilts/custom/android.jar", barJavac.Args["classpath"])
+}
+
+func TestCustomSdkJarMissing(t *testing.T) {
+	prepareForJavaTest.
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`custom_sdk_jar: "prebuilts/custom/android.jar" does not exist`)).
+		RunTestWithBp(t, `
+			java_library {
+				name: "foo",
+				srcs: ["a.java"],
+				custom_sdk_jar: "prebuilts/custom/android.jar",
+			}
+		`)
+}
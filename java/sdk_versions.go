@@ -0,0 +1,82 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+)
+
+// sdkVersionsVariationName returns the name of the variation of a library compiled against the
+// given sdk version.
+func sdkVersionsVariationName(sdkVersion string) string {
+	return "sdk_" + sdkVersion
+}
+
+// moduleWithSdkVersions is implemented by all the module types that have the sdk_versions property,
+// which is only supported by java_library.
+type moduleWithSdkVersions interface {
+	sdkVersions() []string
+}
+
+func (j *Module) sdkVersions() []string {
+	return j.deviceProperties.Sdk_versions
+}
+
+// sdkVersionsMutator creates a variant of each java_library that sets sdk_versions for each of the
+// listed sdk versions, and sets the sdk_version of the variant accordingly. Dependencies that don't
+// ask for a particular version resolve to the variant of the first one.
+func sdkVersionsMutator(ctx android.BottomUpMutatorContext) {
+	if !ctx.Device() {
+		return
+	}
+	library, ok := ctx.Module().(*Library)
+	if !ok {
+		if m, ok := ctx.Module().(moduleWithSdkVersions); ok && len(m.sdkVersions()) > 0 {
+			ctx.PropertyErrorf("sdk_versions", "is only supported by java_library")
+		}
+		return
+	}
+	sdkVersions := library.deviceProperties.Sdk_versions
+	if len(sdkVersions) == 0 {
+		return
+	}
+	if library.deviceProperties.Sdk_version != nil {
+		ctx.PropertyErrorf("sdk_versions", "cannot be set together with sdk_version")
+		return
+	}
+	if dup := android.FirstUniqueStrings(sdkVersions); len(dup) != len(sdkVersions) {
+		ctx.PropertyErrorf("sdk_versions", "contains duplicate versions: %q", sdkVersions)
+		return
+	}
+
+	variationNames := make([]string, len(sdkVersions))
+	for i, sdkVersion := range sdkVersions {
+		variationNames[i] = sdkVersionsVariationName(sdkVersion)
+	}
+	modules := ctx.CreateVariations(variationNames...)
+	for i, module := range modules {
+		variant := module.(*Library)
+		variant.deviceProperties.Sdk_version = proptools.StringPtr(sdkVersions[i])
+		if i > 0 {
+			// Only the first variant is exported to Make and installed, the others would collide
+			// with it.
+			variant.HideFromMake()
+			variant.SkipInstall()
+		}
+	}
+	ctx.AliasVariation(variationNames[0])
+}
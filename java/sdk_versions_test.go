@@ -0,0 +1,95 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"testing"
+
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+)
+
+func TestSdkVersions(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		FixtureWithPrebuiltApis(map[string][]string{
+			"29":      {},
+			"30":      {},
+			"current": {},
+		}),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.Unbundled_build = proptools.BoolPtr(true)
+			variables.Always_use_prebuilt_sdks = proptools.BoolPtr(true)
+		}),
+	).RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_versions: ["29", "30", "current"],
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["b.java"],
+			sdk_version: "current",
+			static_libs: ["foo"],
+		}
+	`)
+
+	variants := result.ModuleVariantsForTests("foo")
+	android.AssertDeepEquals(t, "foo variants",
+		[]string{"android_common_sdk_29", "android_common_sdk_30", "android_common_sdk_current"}, variants)
+
+	for _, sdkVersion := range []string{"29", "30", "current"} {
+		javac := result.ModuleForTests("foo", "android_common_sdk_"+sdkVersion).Rule("javac")
+		// The prebuilt android.jar is passed on the classpath along with the system modules or an empty
+		// bootclasspath of the sdk version.
+		android.AssertStringDoesContain(t, "sdk "+sdkVersion+" classpath",
+			javac.Args["classpath"], "prebuilts/sdk/"+sdkVersion+"/public/android.jar")
+	}
+
+	// Dependencies use the variant of the first version.
+	fooHeader := result.ModuleForTests("foo", "android_common_sdk_29").Output("turbine-combined/foo.jar").Output
+	barJavac := result.ModuleForTests("bar", "android_common").Rule("javac")
+	android.AssertStringDoesContain(t, "bar classpath", barJavac.Args["classpath"], fooHeader.String())
+}
+
+func TestSdkVersionsErrors(t *testing.T) {
+	testJavaError(t, `sdk_versions: cannot be set together with sdk_version`, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			sdk_versions: ["29", "current"],
+		}
+	`)
+
+	testJavaError(t, `sdk_versions: contains duplicate versions`, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_versions: ["29", "29"],
+		}
+	`)
+
+	testJavaError(t, `sdk_versions: is only supported by java_library`, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_versions: ["29", "current"],
+		}
+	`)
+}
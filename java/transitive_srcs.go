@@ -0,0 +1,47 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"android/soong/android"
+)
+
+// buildTransitiveSrcJar zips the given .java sources and merges them with the given srcjars into a
+// single srcjar, for offline analysis of the sources of a module and its static dependencies.
+func buildTransitiveSrcJar(ctx android.ModuleContext, srcFiles android.Paths) android.Path {
+	javaSrcs := srcFiles.FilterByExt(".java")
+	srcJars := srcFiles.FilterByExt(".srcjar")
+
+	javaSrcJar := android.PathForModuleOut(ctx, "transitive-srcs", "srcs.srcjar")
+	transitiveSrcJar := android.PathForModuleOut(ctx, "transitive-srcs", ctx.ModuleName()+"-transitive.srcjar")
+
+	rule := android.NewRuleBuilder(pctx, ctx)
+	// -srcjar places the .java files in the directories of their packages, like in the srcjars.
+	rule.Command().
+		BuiltTool("soong_zip").
+		Flag("-srcjar").
+		Flag("-write_if_changed").
+		FlagWithOutput("-o ", javaSrcJar).
+		FlagWithRspFileInputList("-r ", javaSrcJar.ReplaceExtension(ctx, "rsp"), javaSrcs)
+	rule.Command().
+		BuiltTool("merge_zips").
+		Flag("-ignore-duplicates").
+		Output(transitiveSrcJar).
+		Input(javaSrcJar).
+		Inputs(srcJars)
+	rule.Build("transitive_srcjar", "transitive srcjar")
+
+	return transitiveSrcJar
+}
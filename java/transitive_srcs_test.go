@@ -0,0 +1,77 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestTransitiveSrcJar(t *testing.T) {
+	result := prepareForJavaTest.RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			static_libs: ["bar"],
+			libs: ["qux"],
+			write_transitive_srcs: true,
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["b.java"],
+			static_libs: ["baz"],
+		}
+
+		java_library {
+			name: "baz",
+			srcs: [
+				"c.java",
+				":gen",
+			],
+		}
+
+		java_library {
+			name: "qux",
+			srcs: ["d.java"],
+		}
+
+		genrule {
+			name: "gen",
+			cmd: "touch $(out)",
+			out: ["Gen.java"],
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	srcJar := foo.Output("transitive-srcs/foo-transitive.srcjar")
+	android.AssertStringDoesContain(t, "merge command", srcJar.RuleParams.Command,
+		"out/soong/.intermediates/foo/android_common/transitive-srcs/foo-transitive.srcjar "+
+			"out/soong/.intermediates/foo/android_common/transitive-srcs/srcs.srcjar")
+
+	srcs := srcJar.Inputs.Strings()
+	android.AssertStringListContains(t, "own sources", srcs, "a.java")
+	android.AssertStringListContains(t, "static dependency sources", srcs, "b.java")
+	android.AssertStringListContains(t, "transitive static dependency sources", srcs, "c.java")
+	android.AssertStringListContains(t, "generated sources", srcs,
+		"out/soong/.intermediates/gen/gen/Gen.java")
+	android.AssertStringListDoesNotContain(t, "libs sources", srcs, "d.java")
+
+	outputs, err := foo.Module().(*Library).OutputFiles(".transitive-srcjar")
+	android.AssertDeepEquals(t, "error", nil, err)
+	android.AssertPathsRelativeToTopEquals(t, ".transitive-srcjar output",
+		[]string{"out/soong/.intermediates/foo/android_common/transitive-srcs/foo-transitive.srcjar"}, outputs)
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+)
+
+// javap -constants prints the value of each constant field in the class file, e.g.
+// "public static final java.lang.String VALUE = "expected";", with strings quoted.
+var verifyConstant = pctx.AndroidStaticRule("verifyConstant",
+	blueprint.RuleParams{
+		Command: `if ! ${config.JavapCmd} -constants -cp $in $class | grep -qF $patterns; then ` +
+			`echo "$in: $class.$field does not have the constant value $expected set by verify_constants" >&2; ` +
+			`exit 1; fi && touch $out`,
+		CommandDeps: []string{"${config.JavapCmd}"},
+	},
+	"class", "field", "expected", "patterns")
+
+// parseVerifyConstant splits an entry of verify_constants in the form
+// "<fully qualified class name>.<field>=<value>" into its parts.
+func parseVerifyConstant(constant string) (class, field, expected string, err error) {
+	i := strings.Index(constant, "=")
+	if i < 0 {
+		return "", "", "", fmt.Errorf("%q is not of the form <class>.<field>=<value>", constant)
+	}
+	name, expected := constant[:i], constant[i+1:]
+	j := strings.LastIndex(name, ".")
+	if j <= 0 || j == len(name)-1 {
+		return "", "", "", fmt.Errorf("%q is not of the form <class>.<field>=<value>", constant)
+	}
+	return name[:j], name[j+1:], expected, nil
+}
+
+// verifyConstants checks that the fields listed in verify_constants have the expected constant
+// values in the given classes jar, and returns the stamp files of the checks.
+func (j *Module) verifyConstants(ctx android.ModuleContext, classesJar android.Path) android.Paths {
+	var checks android.Paths
+	for i, constant := range j.properties.Verify_constants {
+		class, field, expected, err := parseVerifyConstant(constant)
+		if err != nil {
+			ctx.PropertyErrorf("verify_constants", "%s", err)
+			continue
+		}
+		patterns := []string{
+			fmt.Sprintf(" %s = %s;", field, expected),
+			fmt.Sprintf(" %s = %q;", field, expected),
+		}
+		check := android.PathForModuleOut(ctx, "verify-constants", fmt.Sprintf("%d.stamp", i))
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        verifyConstant,
+			Description: "verify constant " + class + "." + field,
+			Input:       classesJar,
+			Output:      check,
+			Args: map[string]string{
+				"class":    proptools.NinjaAndShellEscape(class),
+				"field":    proptools.NinjaAndShellEscape(field),
+				"expected": proptools.NinjaAndShellEscape(expected),
+				"patterns": "-e " + strings.Join(proptools.NinjaAndShellEscapeList(patterns), " -e "),
+			},
+		})
+		checks = append(checks, check)
+	}
+	return checks
+}
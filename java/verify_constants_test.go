@@ -0,0 +1,87 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestVerifyConstants(t *testing.T) {
+	ctx, _ := testJava(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			verify_constants: [
+				"com.foo.Const.VALUE=expected",
+				"com.foo.Const.COUNT=42",
+			],
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["b.java"],
+		}
+	`)
+
+	foo := ctx.ModuleForTests("foo", "android_common")
+	combined := foo.Output("combined/foo.jar")
+
+	value := foo.Output("verify-constants/0.stamp")
+	android.AssertStringDoesContain(t, "rule", value.Rule.String(), "verifyConstant")
+	android.AssertPathRelativeToTopEquals(t, "input", combined.Output.String(), value.Input)
+	android.AssertStringEquals(t, "class", "com.foo.Const", value.Args["class"])
+	android.AssertStringEquals(t, "field", "VALUE", value.Args["field"])
+	android.AssertStringEquals(t, "patterns", `-e ' VALUE = expected;' -e ' VALUE = "expected";'`,
+		value.Args["patterns"])
+	// The check fails the build when javap doesn't print the expected value.
+	android.AssertStringDoesContain(t, "command", value.RuleParams.Command, "exit 1")
+
+	count := foo.Output("verify-constants/1.stamp")
+	android.AssertStringEquals(t, "field", "COUNT", count.Args["field"])
+	android.AssertStringEquals(t, "expected", "42", count.Args["expected"])
+
+	// The checked jar is a copy of the output jar with validation dependencies on the checks.
+	checked := foo.Output("checked/foo.jar")
+	android.AssertPathRelativeToTopEquals(t, "checked input", combined.Output.String(), checked.Input)
+	android.AssertPathsRelativeToTopEquals(t, "validations", []string{
+		"out/soong/.intermediates/foo/android_common/verify-constants/0.stamp",
+		"out/soong/.intermediates/foo/android_common/verify-constants/1.stamp",
+	}, checked.Validations)
+
+	bar := ctx.ModuleForTests("bar", "android_common")
+	if rule := bar.MaybeRule("verifyConstant").Rule; rule != nil {
+		t.Errorf("expected no constant verification without verify_constants, got %q", rule)
+	}
+}
+
+func TestVerifyConstantsErrors(t *testing.T) {
+	testJavaError(t, `verify_constants: "VALUE=expected" is not of the form <class>.<field>=<value>`, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			verify_constants: ["VALUE=expected"],
+		}
+	`)
+
+	testJavaError(t, `verify_constants: "com.foo.Const.VALUE" is not of the form <class>.<field>=<value>`, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			verify_constants: ["com.foo.Const.VALUE"],
+		}
+	`)
+}
@@ -0,0 +1,119 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""Check the ABI of a library against a frozen ABI contract.
+
+The ABI is extracted with javap from the classes of a header jar, and consists
+of the declarations of the public and protected classes and members, one per
+line, sorted so that it doesn't depend on the order of the sources. Any
+difference with the contract, whether a removed or an added declaration, is an
+error.
+"""
+
+import argparse
+import difflib
+import subprocess
+import sys
+import zipfile
+
+
+def class_names(jar):
+    """Return the sorted names of the classes in the given jar."""
+    with zipfile.ZipFile(jar) as z:
+        return sorted(
+            n.removesuffix('.class').replace('/', '.')
+            for n in z.namelist()
+            if n.endswith('.class') and not n.startswith('META-INF/'))
+
+
+def parse_javap_output(stream):
+    """Parse the output of javap into a sorted list of ABI lines.
+
+    Each class declaration is followed by the sorted declarations of its
+    members, indented by two spaces.
+    """
+    classes = {}
+    current = None
+    for line in stream:
+        line = line.strip()
+        if not line or line.startswith('Compiled from'):
+            continue
+        if line.endswith('{'):
+            current = line.removesuffix('{').strip()
+            classes[current] = []
+        elif line == '}':
+            current = None
+        elif current is not None:
+            classes[current].append('  ' + line)
+    abi = []
+    for cls in sorted(classes):
+        abi.append(cls)
+        abi.extend(sorted(classes[cls]))
+    return abi
+
+
+def diff_abi(contract, abi, contract_name='contract', abi_name='abi'):
+    """Return the unified diff from the contract to the ABI, empty if equal."""
+    return list(
+        difflib.unified_diff(
+            contract, abi, fromfile=contract_name, tofile=abi_name,
+            lineterm=''))
+
+
+def main(args):
+    args_parser = argparse.ArgumentParser(
+        description='Check the ABI of a library against a frozen contract.')
+    args_parser.add_argument(
+        '--javap', required=True, help='The javap binary')
+    args_parser.add_argument(
+        '--jar', required=True, help='The header jar of the library')
+    args_parser.add_argument(
+        '--contract', required=True, help='The frozen ABI contract')
+    args_parser.add_argument(
+        '--output',
+        required=True,
+        help='The file to which to write the extracted ABI')
+    args = args_parser.parse_args(args)
+
+    abi = []
+    classes = class_names(args.jar)
+    if classes:
+        javap = subprocess.run(
+            [args.javap, '-protected', '-classpath', args.jar] + classes,
+            stdout=subprocess.PIPE,
+            check=True,
+            universal_newlines=True)
+        abi = parse_javap_output(javap.stdout.splitlines())
+
+    with open(args.output, 'w', encoding='utf8') as f:
+        for line in abi:
+            print(line, file=f)
+
+    with open(args.contract, 'r', encoding='utf8') as f:
+        contract = [l.rstrip('\n') for l in f if l.strip()]
+
+    diff = diff_abi(contract, abi, args.contract, args.output)
+    if diff:
+        print('error: the ABI of %s does not match its contract %s:' %
+              (args.jar, args.contract), file=sys.stderr)
+        for line in diff:
+            print(line, file=sys.stderr)
+        print('If the change is intended, copy %s to %s.' %
+              (args.output, args.contract), file=sys.stderr)
+        sys.exit(1)
+
+
+if __name__ == '__main__':
+    main(sys.argv[1:])
@@ -0,0 +1,74 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""Unit tests for check_abi_contract.py."""
+import io
+import unittest
+
+import check_abi_contract
+
+
+class TestCheckAbiContract(unittest.TestCase):
+
+    javap_output = """Compiled from "Foo.java"
+public class foo.Foo {
+  public void remove();
+  public foo.Foo();
+  protected int get(java.lang.String);
+}
+public interface foo.Bar {
+  public abstract void bar();
+}
+"""
+
+    contract = [
+        'public class foo.Foo',
+        '  protected int get(java.lang.String);',
+        '  public foo.Foo();',
+        '  public void remove();',
+        'public interface foo.Bar',
+        '  public abstract void bar();',
+    ]
+
+    @staticmethod
+    def parse(text):
+        with io.StringIO(text) as f:
+            return check_abi_contract.parse_javap_output(f)
+
+    def test_parse_javap_output(self):
+        self.assertEqual([
+            'public class foo.Foo',
+            '  protected int get(java.lang.String);',
+            '  public foo.Foo();',
+            '  public void remove();',
+            'public interface foo.Bar',
+            '  public abstract void bar();',
+        ], self.parse(TestCheckAbiContract.javap_output))
+
+    def test_diff_abi_matches(self):
+        abi = self.parse(TestCheckAbiContract.javap_output)
+        self.assertEqual(
+            [], check_abi_contract.diff_abi(TestCheckAbiContract.contract, abi))
+
+    def test_diff_abi_removed_method(self):
+        abi = self.parse(TestCheckAbiContract.javap_output.replace(
+            '  public void remove();\n', ''))
+        diff = check_abi_contract.diff_abi(TestCheckAbiContract.contract, abi)
+        self.assertIn('-  public void remove();', diff)
+        self.assertFalse([l for l in diff[2:] if l.startswith('+')])
+
+
+if __name__ == '__main__':
+    unittest.main(verbosity=2)
@@ -0,0 +1,97 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""A tool for breaking down the size of the dex code in a jar or apk by package."""
+
+from __future__ import print_function
+
+import argparse
+import re
+import subprocess
+import sys
+import zipfile
+
+CLASS_DESCRIPTOR_RE = re.compile(r"^\s*Class descriptor\s*:\s*'L([^;]*);'")
+INSNS_SIZE_RE = re.compile(r'^\s*insns size\s*:\s*(\d+) 16-bit code units')
+
+
+def parse_args(args):
+    """Parse commandline arguments."""
+    parser = argparse.ArgumentParser()
+    parser.add_argument(
+        '--dexdump', required=True, help='path to the dexdump tool')
+    parser.add_argument(
+        '--output', required=True, help='file to write the report to')
+    parser.add_argument('input', help='jar or apk containing dex files')
+    return parser.parse_args(args)
+
+
+def package_of(descriptor):
+    """Returns the dotted package name of a class descriptor like com/foo/Bar."""
+    if '/' not in descriptor:
+        return '<default>'
+    return descriptor.rsplit('/', 1)[0].replace('/', '.')
+
+
+def break_down(dexdump_output):
+    """Returns a map from package name to [classes, methods, code bytes]."""
+    packages = {}
+    current = None
+    for line in dexdump_output.splitlines():
+        m = CLASS_DESCRIPTOR_RE.match(line)
+        if m:
+            current = packages.setdefault(package_of(m.group(1)), [0, 0, 0])
+            current[0] += 1
+            continue
+        m = INSNS_SIZE_RE.match(line)
+        if m and current is not None:
+            current[1] += 1
+            current[2] += 2 * int(m.group(1))
+    return packages
+
+
+def write_report(out, dex_sizes, packages):
+    """Writes the report, with the packages sorted by decreasing code size."""
+    print('# %d bytes of dex in %d files' %
+          (sum(dex_sizes.values()), len(dex_sizes)), file=out)
+    for name in sorted(dex_sizes):
+        print('# %s: %d bytes' % (name, dex_sizes[name]), file=out)
+    print('package\tclasses\tmethods\tcode_bytes', file=out)
+    for name, (classes, methods, code_bytes) in sorted(
+            packages.items(), key=lambda item: (-item[1][2], item[0])):
+        print('%s\t%d\t%d\t%d' % (name, classes, methods, code_bytes), file=out)
+
+
+def main():
+    """Program entry point."""
+    args = parse_args(sys.argv[1:])
+
+    with zipfile.ZipFile(args.input) as z:
+        dex_sizes = {
+            info.filename: info.file_size
+            for info in z.infolist()
+            if re.match(r'^classes\d*\.dex$', info.filename)
+        }
+
+    dexdump_output = subprocess.check_output([args.dexdump, args.input])
+    packages = break_down(dexdump_output.decode('utf-8', 'replace'))
+
+    with open(args.output, 'w') as out:
+        write_report(out, dex_sizes, packages)
+
+
+if __name__ == '__main__':
+    main()
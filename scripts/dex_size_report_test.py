@@ -0,0 +1,116 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""Unit tests for dex_size_report.py."""
+import io
+import unittest
+
+import dex_size_report
+
+
+class TestDexSizeReport(unittest.TestCase):
+
+    dexdump_output = """Processing 'foo.jar'...
+Opened 'foo.jar', DEX version '035'
+Class #0            -
+  Class descriptor  : 'Lcom/foo/Foo;'
+  Access flags      : 0x0001 (PUBLIC)
+  Superclass        : 'Ljava/lang/Object;'
+  Direct methods    -
+    #0              : (in Lcom/foo/Foo;)
+      name          : '<init>'
+      type          : '()V'
+      code          -
+      registers     : 1
+      insns size    : 4 16-bit code units
+    #1              : (in Lcom/foo/Foo;)
+      name          : 'foo'
+      type          : '()I'
+      code          -
+      registers     : 1
+      insns size    : 10 16-bit code units
+  Virtual methods   -
+    #0              : (in Lcom/foo/Foo;)
+      name          : 'abstractFoo'
+      type          : '()V'
+      code          : (none)
+
+Class #1            -
+  Class descriptor  : 'Lcom/foo/bar/Bar;'
+  Access flags      : 0x0001 (PUBLIC)
+  Superclass        : 'Ljava/lang/Object;'
+  Direct methods    -
+    #0              : (in Lcom/foo/bar/Bar;)
+      name          : '<init>'
+      type          : '()V'
+      code          -
+      registers     : 1
+      insns size    : 4 16-bit code units
+
+Class #2            -
+  Class descriptor  : 'LDefault;'
+  Access flags      : 0x0001 (PUBLIC)
+  Superclass        : 'Ljava/lang/Object;'
+  Direct methods    -
+    #0              : (in LDefault;)
+      name          : '<init>'
+      type          : '()V'
+      code          -
+      registers     : 1
+      insns size    : 4 16-bit code units
+
+Class #3            -
+  Class descriptor  : 'Lcom/foo/Baz;'
+  Access flags      : 0x0001 (PUBLIC)
+  Superclass        : 'Ljava/lang/Object;'
+"""
+
+    def test_package_of(self):
+        self.assertEqual('com.foo', dex_size_report.package_of('com/foo/Foo'))
+        self.assertEqual('com.foo.bar',
+                         dex_size_report.package_of('com/foo/bar/Bar'))
+        self.assertEqual('<default>', dex_size_report.package_of('Default'))
+
+    def test_break_down(self):
+        self.assertEqual({
+            'com.foo': [2, 2, 28],
+            'com.foo.bar': [1, 1, 8],
+            '<default>': [1, 1, 8],
+        }, dex_size_report.break_down(TestDexSizeReport.dexdump_output))
+
+    def test_break_down_ignores_code_before_first_class(self):
+        self.assertEqual({}, dex_size_report.break_down(
+            '      insns size    : 4 16-bit code units\n'))
+
+    def test_write_report(self):
+        packages = dex_size_report.break_down(
+            TestDexSizeReport.dexdump_output)
+        with io.StringIO() as out:
+            dex_size_report.write_report(out, {
+                'classes.dex': 100,
+                'classes2.dex': 20,
+            }, packages)
+            self.assertEqual(
+                '# 120 bytes of dex in 2 files\n'
+                '# classes.dex: 100 bytes\n'
+                '# classes2.dex: 20 bytes\n'
+                'package\tclasses\tmethods\tcode_bytes\n'
+                'com.foo\t2\t2\t28\n'
+                '<default>\t1\t1\t8\n'
+                'com.foo.bar\t1\t1\t8\n', out.getvalue())
+
+
+if __name__ == '__main__':
+    unittest.main(verbosity=2)
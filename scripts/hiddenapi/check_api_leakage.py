@@ -0,0 +1,124 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""Check that the public API of a library does not leak hidden types.
+
+A member leaks a hidden type if it is part of the public API but its signature
+refers to a class that is not, i.e. a class that has hidden API flags but none
+of whose members are part of the public API. The hidden API flags are read
+from a hiddenapi-flags.csv file.
+"""
+
+import argparse
+import csv
+import re
+import sys
+
+PUBLIC_API_FLAG = 'public-api'
+
+# Matches the class descriptors in a dex member signature.
+CLASS_DESCRIPTOR_RE = re.compile(r'L[^;()]+;')
+
+
+def read_flags_from_stream(stream):
+    """Read the hidden API flags into a dict from signature to set of flags."""
+    flags = {}
+    for row in csv.reader(stream, delimiter=',', quotechar='|'):
+        if not row:
+            continue
+        flags[row[0]] = set(row[1:])
+    return flags
+
+
+def class_of_member(signature):
+    return signature.split('->', maxsplit=1)[0]
+
+
+def hidden_classes(flags):
+    """Return the set of classes none of whose members are public API."""
+    public_classes = set()
+    all_classes = set()
+    for signature, member_flags in flags.items():
+        cls = class_of_member(signature)
+        all_classes.add(cls)
+        if PUBLIC_API_FLAG in member_flags:
+            public_classes.add(cls)
+    return all_classes - public_classes
+
+
+def class_file_to_descriptor(class_file):
+    """Convert a path to a .class file in a jar into a class descriptor."""
+    return 'L' + class_file.strip().removesuffix('.class') + ';'
+
+
+def find_leaks(flags, library_classes):
+    """Find the public API members of the library that refer to hidden types.
+
+    :param flags: the dict from signature to set of flags.
+    :param library_classes: the set of class descriptors in the library.
+    :return: a sorted list of error messages.
+    """
+    hidden = hidden_classes(flags)
+    errors = []
+    for signature, member_flags in flags.items():
+        if PUBLIC_API_FLAG not in member_flags:
+            continue
+        cls = class_of_member(signature)
+        if cls not in library_classes:
+            continue
+        member = signature.split('->', maxsplit=1)[1]
+        leaked = sorted({
+            t for t in CLASS_DESCRIPTOR_RE.findall(member)
+            if t in hidden and t != cls
+        })
+        if leaked:
+            errors.append(f'{signature}: public API refers to hidden '
+                          f'type(s) {", ".join(leaked)}')
+    errors.sort()
+    return errors
+
+
+def main(args):
+    args_parser = argparse.ArgumentParser(
+        description='Check that the public API of a library does not refer '
+        'to hidden types.')
+    args_parser.add_argument(
+        '--flags', required=True, help='The hiddenapi-flags.csv file')
+    args_parser.add_argument(
+        '--classes',
+        required=True,
+        help='A file listing the .class files in the library, one per line')
+    args_parser.add_argument(
+        '--output', required=True, help='The file to which to write the report')
+    args = args_parser.parse_args(args)
+
+    with open(args.flags, 'r', encoding='utf8') as f:
+        flags = read_flags_from_stream(f)
+    with open(args.classes, 'r', encoding='utf8') as f:
+        library_classes = {class_file_to_descriptor(l) for l in f if l.strip()}
+
+    errors = find_leaks(flags, library_classes)
+    with open(args.output, 'w', encoding='utf8') as f:
+        for error in errors:
+            print(error, file=f)
+
+    if errors:
+        for error in errors:
+            print(error, file=sys.stderr)
+        sys.exit(1)
+
+
+if __name__ == '__main__':
+    main(sys.argv[1:])
@@ -0,0 +1,68 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""Unit tests for check_api_leakage.py."""
+import io
+import unittest
+
+import check_api_leakage
+
+
+class TestCheckApiLeakage(unittest.TestCase):
+
+    csv_flags = """
+Lfoo/Public;-><init>()V,public-api
+Lfoo/Public;->leak(Lfoo/Hidden;)V,public-api
+Lfoo/Public;->get()Lfoo/Hidden;,public-api
+Lfoo/Public;->ok(Lfoo/Public;I)Ljava/lang/String;,public-api
+Lfoo/Public;->internal(Lfoo/Hidden;)V,blocked
+Lfoo/Hidden;-><init>()V,blocked
+Lbar/Other;->leak(Lfoo/Hidden;)V,public-api
+Ljava/lang/String;->length()I,public-api
+"""
+
+    @staticmethod
+    def read_flags(text):
+        with io.StringIO(text) as f:
+            return check_api_leakage.read_flags_from_stream(f)
+
+    def test_hidden_classes(self):
+        flags = self.read_flags(TestCheckApiLeakage.csv_flags)
+        self.assertEqual({'Lfoo/Hidden;'},
+                         check_api_leakage.hidden_classes(flags))
+
+    def test_class_file_to_descriptor(self):
+        self.assertEqual(
+            'Lfoo/Public$Inner;',
+            check_api_leakage.class_file_to_descriptor('foo/Public$Inner.class\n'))
+
+    def test_find_leaks(self):
+        flags = self.read_flags(TestCheckApiLeakage.csv_flags)
+        errors = check_api_leakage.find_leaks(flags, {'Lfoo/Public;'})
+        self.assertEqual([
+            'Lfoo/Public;->get()Lfoo/Hidden;: public API refers to hidden '
+            'type(s) Lfoo/Hidden;',
+            'Lfoo/Public;->leak(Lfoo/Hidden;)V: public API refers to hidden '
+            'type(s) Lfoo/Hidden;',
+        ], errors)
+
+    def test_find_leaks_none(self):
+        flags = self.read_flags(TestCheckApiLeakage.csv_flags)
+        errors = check_api_leakage.find_leaks(flags, {'Ljava/lang/String;'})
+        self.assertEqual([], errors)
+
+
+if __name__ == '__main__':
+    unittest.main(verbosity=2)